@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/robertkrimen/gphr/gphr"
+)
+
+// A CHANGELOG.md in the style of http://keepachangelog.com/
+//
+//     # Changelog
+//
+//     ## [Unreleased]
+//
+//     ## [1.1.0] - 2014-05-23
+//     ### Added
+//     - ...
+//
+//     [Unreleased]: https://github.com/alice/example/compare/v1.1.0...HEAD
+//     [1.1.0]: https://github.com/alice/example/releases/tag/v1.1.0
+//
+// (The links are to the pages of the provider, GitHub, GitLab, or Gitea; a
+// directory or S3 store has none, so there are no links.)
+//
+// Sections for versions that are already in the file are left alone (they may
+// have been edited by hand), sections for new versions are generated from the
+// commit subjects between tags, and the [Unreleased] section and links are
+// always regenerated.
+
+var changelogHeader = strings.TrimSpace(`
+# Changelog
+
+All notable changes to this project will be documented in this file.
+
+The format is based on [Keep a Changelog](http://keepachangelog.com/)
+and this project adheres to [Semantic Versioning](http://semver.org/).
+`)
+
+// Conventional Commit type => Keep a Changelog kind
+var changelogKind = map[string]string{
+	"!":      "Changed",
+	"feat":   "Added",
+	"fix":    "Fixed",
+	"perf":   "Changed",
+	"revert": "Changed",
+	"":       "Changed",
+}
+
+var changelogKindOrder = []string{"Added", "Changed", "Deprecated", "Removed", "Fixed", "Security"}
+
+var (
+	matchChangelogSection = regexp.MustCompile(`^## \[([^\]]+)\]`)
+	matchChangelogLink    = regexp.MustCompile(`^\[[^\]]+\]:\s`)
+)
+
+type _changelog struct {
+	header   string
+	sections map[string]string // 1.1.0 => ## [1.1.0] - 2014-05-23 ...
+}
+
+func parseChangelog(input []byte) *_changelog {
+	changelog := &_changelog{
+		sections: map[string]string{},
+	}
+	if len(input) == 0 {
+		changelog.header = changelogHeader
+		return changelog
+	}
+
+	header, version, section := bytes.NewBuffer(nil), "", bytes.NewBuffer(nil)
+	flush := func() {
+		if version != "" {
+			changelog.sections[version] = strings.TrimSpace(section.String())
+		}
+		section.Reset()
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(input))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := matchChangelogSection.FindStringSubmatch(line); match != nil {
+			flush()
+			version = match[1]
+		} else if matchChangelogLink.MatchString(line) {
+			continue
+		}
+		if version == "" {
+			fmt.Fprintln(header, line)
+		} else {
+			fmt.Fprintln(section, line)
+		}
+	}
+	flush()
+
+	changelog.header = strings.TrimSpace(header.String())
+	if changelog.header == "" {
+		changelog.header = changelogHeader
+	}
+	return changelog
+}
+
+func renderChangelogSection(heading string, subjects []string) string {
+	kinds := map[string][]string{}
+	for _, subject := range subjects {
+		commit := newNotesCommit(subject)
+		kind := commit.Type
+		if commit.Breaking {
+			kind = "!"
+		}
+		if _, exists := changelogKind[kind]; !exists {
+			if matchConventionalCommit.MatchString(subject) {
+				continue // docs, test, chore, ... are not notable
+			}
+			kind = ""
+		}
+		summary := commit.Summary
+		if commit.Breaking {
+			summary = "**BREAKING:** " + summary
+		}
+		if commit.Scope != "" {
+			summary = "**" + commit.Scope + ":** " + summary
+		}
+		kinds[changelogKind[kind]] = append(kinds[changelogKind[kind]], summary)
+	}
+
+	section := bytes.NewBuffer(nil)
+	fmt.Fprintln(section, heading)
+	for _, kind := range changelogKindOrder {
+		if len(kinds[kind]) == 0 {
+			continue
+		}
+		fmt.Fprintf(section, "\n### %s\n", kind)
+		for _, summary := range kinds[kind] {
+			fmt.Fprintf(section, "- %s\n", summary)
+		}
+	}
+	return strings.TrimSpace(section.String())
+}
+
+// renderChangelog renders <changelog> for <versions> (in ascending order), with
+// links to the release of each version from <linker> (if any).
+func renderChangelog(changelog *_changelog, versions []*gphr.Version, linker gphr.Linker) (string, error) {
+	output := bytes.NewBuffer(nil)
+	fmt.Fprintf(output, "%s\n\n", changelog.header)
+
+	latest := ""
+	if len(versions) > 0 {
+		latest = versions[len(versions)-1].Tag
+	}
+	subjects, err := gitGetSubjects(latest, "HEAD")
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(output, "%s\n\n", renderChangelogSection("## [Unreleased]", subjects))
+
+	for index := len(versions) - 1; index >= 0; index-- {
+		version := versions[index]
+		section, exists := changelog.sections[version.Number()]
+		if !exists {
+			previous := ""
+			if index > 0 {
+				previous = versions[index-1].Tag
+			}
+			subjects, err := gitGetSubjects(previous, version.Tag)
+			if err != nil {
+				return "", err
+			}
+			date, err := gitGetTagDate(version.Tag)
+			if err != nil {
+				return "", err
+			}
+			section = renderChangelogSection(fmt.Sprintf("## [%s] - %s", version.Number(), date), subjects)
+		}
+		fmt.Fprintf(output, "%s\n\n", section)
+	}
+
+	if linker == nil {
+		return strings.TrimSpace(output.String()) + "\n", nil // Nothing to link to (e.g. a store)
+	}
+	if latest != "" {
+		fmt.Fprintf(output, "[Unreleased]: %s\n", linker.CompareURL(latest, "HEAD"))
+	}
+	for index := len(versions) - 1; index >= 0; index-- {
+		fmt.Fprintf(output, "[%s]: %s\n", versions[index].Number(), linker.ReleaseURL(versions[index].Tag))
+	}
+
+	return output.String(), nil
+}
+
+// changelog writes (or updates) the CHANGELOG.md for the local repository,
+// reporting any (semver) tag that does not have a release in <provider>.
+func changelog(provider gphr.Provider, filename string) error {
+	versions, err := gitGetVersions()
+	if err != nil {
+		return err
+	}
+
+	input, err := ioutil.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	linker, _ := provider.(gphr.Linker)
+	output, err := renderChangelog(parseChangelog(input), versions, linker)
+	if err != nil {
+		return err
+	}
+
+	if *flags.main.dryRun {
+		fmt.Print(output)
+	} else {
+		err = ioutil.WriteFile(filename, []byte(output), 0644)
+		if err != nil {
+			return err
+		}
+		log("Wrote %s (%d versions)", filename, len(versions))
+	}
+
+	releases, err := provider.GetReleases()
+	if err != nil {
+		return err
+	}
+	released := map[string]bool{}
+	for _, release := range releases {
+		released[*release.TagName] = true
+	}
+	for _, version := range versions {
+		if !released[version.Tag] {
+			lg.err("%s: no release in %s", version.Tag, provider.Location())
+		}
+	}
+
+	return nil
+}
+
+// git tag --list
+
+func gitGetVersions() ([]*gphr.Version, error) {
+	var tags []string
+	if useNativeGit() {
+		repo, err := openGitRepository(".")
+		if err != nil {
+			return nil, err
+		}
+		tags, err = repo.versions()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		cmd := exec.Command("git", "tag", "--list")
+		output, err := cmd.CombinedOutput()
+		lg.dbg("git tag --list:\n%s---", string(output))
+		if err != nil {
+			return nil, fmt.Errorf("git: %v: %s", err, firstLine(output))
+		}
+		tags = strings.Fields(string(output))
+	}
+
+	var versions []*gphr.Version
+	for _, tag := range tags {
+		version, err := gphr.ParseVersion(tag)
+		if err != nil {
+			continue // Not a semver tag
+		}
+		versions = append(versions, version)
+	}
+	gphr.SortVersions(versions)
+	return versions, nil
+}
+
+// git log -1 --format=%ad --date=short <tag>
+
+func gitGetTagDate(tag string) (string, error) {
+	if useNativeGit() {
+		repo, err := openGitRepository(".")
+		if err != nil {
+			return "", err
+		}
+		return repo.tagDate(tag)
+	}
+
+	cmd := exec.Command("git", "log", "-1", "--format=%ad", "--date=short", tag)
+	output, err := cmd.CombinedOutput()
+	lg.dbg("git log -1 --format=%%ad --date=short %s:\n%s---", tag, string(output))
+	if err != nil {
+		return "", fmt.Errorf("git: %v: %s", err, firstLine(output))
+	}
+	return firstLine(output), nil
+}
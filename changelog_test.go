@@ -0,0 +1,144 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"./gphr/terst"
+	"github.com/robertkrimen/gphr/gphr"
+)
+
+func TestParseChangelog(t *testing.T) {
+	terst.Terst(t, func() {
+		changelog := parseChangelog(nil)
+		is(changelog.header, changelogHeader)
+		is(len(changelog.sections), 0)
+
+		changelog = parseChangelog([]byte(strings.Join([]string{
+			"# Changelog",
+			"",
+			"Everything, by hand.",
+			"",
+			"## [Unreleased]",
+			"- Something soon",
+			"",
+			"## [1.1.0] - 2014-05-23",
+			"### Added",
+			"- Written by hand",
+			"",
+			"## [1.0.0] - 2014-05-01",
+			"- The first",
+			"",
+			"[Unreleased]: https://github.com/alice/example/compare/v1.1.0...HEAD",
+			"[1.1.0]: https://github.com/alice/example/releases/tag/v1.1.0",
+			"",
+		}, "\n")))
+		is(changelog.header, "# Changelog\n\nEverything, by hand.")
+		is(len(changelog.sections), 3)
+		is(changelog.sections["1.1.0"], "## [1.1.0] - 2014-05-23\n### Added\n- Written by hand")
+		is(changelog.sections["1.0.0"], "## [1.0.0] - 2014-05-01\n- The first") // Without the links
+
+		changelog = parseChangelog([]byte("## [1.0.0] - 2014-05-01\n"))
+		is(changelog.header, changelogHeader)
+		is(changelog.sections["1.0.0"], "## [1.0.0] - 2014-05-01")
+	})
+}
+
+func TestRenderChangelogSection(t *testing.T) {
+	terst.Terst(t, func() {
+		for _, test := range []struct {
+			subjects []string
+			section  string
+		}{
+			{nil, "## [1.0.0]"},
+			{[]string{"docs: Usage", "chore: Bump", "test: More"}, "## [1.0.0]"}, // Not notable
+			{[]string{"feat: Add get", "fix(get): Follow redirects", "feat(list): Add -os"}, "## [1.0.0]\n\n### Added\n- Add get\n- **list:** Add -os\n\n### Fixed\n- **get:** Follow redirects"},
+			{[]string{"revert: feat: Add get", "perf: Faster", "Untyped"}, "## [1.0.0]\n\n### Changed\n- feat: Add get\n- Faster\n- Untyped"},
+			{[]string{"feat(get)!: Download from the latest release"}, "## [1.0.0]\n\n### Changed\n- **get:** **BREAKING:** Download from the latest release"},
+		} {
+			is(renderChangelogSection("## [1.0.0]", test.subjects), test.section)
+		}
+	})
+}
+
+func TestRenderChangelog(t *testing.T) {
+	test := newEndToEnd(t)
+	defer test.close()
+
+	os.Setenv("GIT_AUTHOR_DATE", "2014-05-23T12:00:00Z")
+
+	terst.Terst(t, func() {
+		repository := test.repository()
+		for _, subject := range []string{"feat: Add get", "v1.0.0", "fix: Follow redirects", "v1.1.0", "feat: Add list"} {
+			if strings.HasPrefix(subject, "v") {
+				test.git(repository, "tag", subject)
+				continue
+			}
+			test.git(repository, "commit", "-q", "--allow-empty", "-m", subject)
+		}
+		versions, err := gitGetVersions()
+		is(err, nil)
+		is(len(versions), 2)
+
+		// A section written by hand is left alone, the rest are generated
+		changelog := parseChangelog([]byte("# Changelog\n\n## [1.0.0] - 2014-05-01\n- Written by hand\n\n[1.0.0]: https://example.com/old\n"))
+		github := gphr.NewGitHub("alice", "example", nil, "")
+		output, err := renderChangelog(changelog, versions, github)
+		is(err, nil)
+		is(output, strings.Join([]string{
+			"# Changelog",
+			"",
+			"## [Unreleased]",
+			"",
+			"### Added",
+			"- Add list",
+			"",
+			"## [1.1.0] - 2014-05-23",
+			"",
+			"### Fixed",
+			"- Follow redirects",
+			"",
+			"## [1.0.0] - 2014-05-01",
+			"- Written by hand",
+			"",
+			"[Unreleased]: https://github.com/alice/example/compare/v1.1.0...HEAD",
+			"[1.1.0]: https://github.com/alice/example/releases/tag/v1.1.0",
+			"[1.0.0]: https://github.com/alice/example/releases/tag/v1.0.0",
+			"",
+		}, "\n"))
+
+		// Rendering the output again changes nothing
+		again, err := renderChangelog(parseChangelog([]byte(output)), versions, github)
+		is(err, nil)
+		is(again, output)
+
+		// GitLab (the pages of a release, and of a comparison, are under /-/)
+		gitlab, err := gphr.NewGitLab("gitlab.com", "", "alice", "example", nil, "")
+		is(err, nil)
+		output, err = renderChangelog(parseChangelog(nil), versions, gitlab)
+		is(err, nil)
+		is(strings.HasSuffix(output, strings.Join([]string{
+			"[Unreleased]: https://gitlab.com/alice/example/-/compare/v1.1.0...HEAD",
+			"[1.1.0]: https://gitlab.com/alice/example/-/releases/v1.1.0",
+			"[1.0.0]: https://gitlab.com/alice/example/-/releases/v1.0.0",
+			"",
+		}, "\n")), true)
+		is(strings.Contains(output, "## [1.0.0] - 2014-05-23\n\n### Added\n- Add get\n"), true)
+
+		// A store (no links)
+		output, err = renderChangelog(parseChangelog(nil), versions, nil)
+		is(err, nil)
+		is(strings.HasSuffix(output, "## [1.0.0] - 2014-05-23\n\n### Added\n- Add get\n"), true)
+		is(strings.Contains(output, "]: "), false)
+
+		// changelog (written, with the tags without a release reported)
+		test.server.Repository("alice", "example").CreateRelease("v1.0.0")
+		_, err = test.run("changelog", "-repository="+test.target)
+		is(err, nil)
+		content, err := ioutil.ReadFile("CHANGELOG.md")
+		is(err, nil)
+		is(strings.Contains(string(content), "\n[1.1.0]: https://"+test.target+"/releases/tag/v1.1.0\n"), true)
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The configuration of a repository is .gphr (at the top of the repository,
+// committed with it), in the syntax of git config (like .gitmodules):
+//
+//     [package]
+//         formats = deb,rpm,apk
+//         maintainer = Alice <alice@example.com>
+//         description = An example program
+//         license = MIT
+//
+// A flag (given) overrides the configuration.
+
+type _config map[string]string
+
+// getConfig returns the configuration (.gphr) of the repository containing the
+// working directory, or an empty configuration if there is none.
+func getConfig() (_config, error) {
+	path, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		file, err := os.Open(filepath.Join(path, ".gphr"))
+		if err == nil {
+			defer file.Close()
+			config, err := parseGitConfig(file)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", file.Name(), strings.TrimPrefix(err.Error(), "git: "))
+			}
+			lg.dbg("config = %s", file.Name())
+			return config, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		if _, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+			break // The top of the repository
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			break
+		}
+		path = parent
+	}
+	return _config{}, nil
+}
+
+// get returns <value> (of a flag) if given, otherwise <name> (e.g.
+// "package.maintainer") from the configuration.
+func (config _config) get(value, name string) string {
+	if value != "" {
+		return value
+	}
+	return config[name]
+}
+
+// list returns get(<value>, <name>) as a list (split by commas or spaces).
+func (config _config) list(value, name string) []string {
+	return strings.FieldsFunc(config.get(value, name), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"net/url"
+	"os"
 	"os/exec"
 	"regexp"
 	"strings"
@@ -11,12 +13,71 @@ import (
 
 // https://github.com/example/example.git
 // git@github.com:example/example.git
+// git@github.com-work:example/example.git (A host alias in ~/.ssh/config)
+// ssh://git@github.example.com/example/example.git
+// ssh://git@github.example.com:2222/example/example.git (The port of SSH, not of the API)
+// https://gitlab.com/group/subgroup/example.git (The owner is the group/subgroup)
 
-var matchGitHubURL = regexp.MustCompile(`^(?:git@github.com(?:-[^:]+)?:|https?://github.com/)([^/]+)/(.*)(\.git)$`)
+var matchGitSCPURL = regexp.MustCompile(`^[^@/:]+@([^:/]+):(.+)$`)
+
+// parseGitHubURL returns the host, owner, and repository of a remote URL (or
+// nothing, if the URL is not GitHub-ish). Every path segment but the last is
+// the owner (a GitLab group, and subgroups).
+func parseGitHubURL(remote string) (string, string, string) {
+	host, path := "", ""
+	if strings.Contains(remote, "://") {
+		url_, err := url.Parse(remote)
+		if err != nil {
+			return "", "", ""
+		}
+		switch url_.Scheme {
+		case "http", "https":
+			host = url_.Host
+		case "ssh", "git+ssh", "ssh+git":
+			host = url_.Hostname() // The API is not at the port of SSH
+		default:
+			return "", "", ""
+		}
+		path = url_.Path
+	} else if match := matchGitSCPURL.FindStringSubmatch(remote); match != nil {
+		host, path = match[1], match[2]
+	}
+	if host == "" {
+		return "", "", ""
+	}
+
+	segments := strings.Split(strings.TrimSuffix(strings.Trim(path, "/"), ".git"), "/")
+	for _, segment := range segments {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", "", ""
+		}
+	}
+	if len(segments) < 2 {
+		return "", "", ""
+	}
+	if strings.HasPrefix(host, "github.com-") {
+		host = "github.com"
+	}
+	return host, strings.Join(segments[:len(segments)-1], "/"), segments[len(segments)-1]
+}
 
 // git config --get remote.origin.url
 
-func gitGetGitHubURL() (string, string, error) {
+func gitGetGitHubURL() (string, string, string, error) {
+	_, host, owner, repository, err := gitGetGitHubRemote()
+	return host, owner, repository, err
+}
+
+// gitGetGitHubRemote returns the name of the GitHub remote ("origin" or "github"), along
+// with its host/owner/repository.
+func gitGetGitHubRemote() (string, string, string, string, error) {
+	if useNativeGit() {
+		repo, err := openGitRepository(".")
+		if err != nil {
+			return "", "", "", "", err
+		}
+		return repo.githubURL()
+	}
 
 	try := func(remote string) (string, error) {
 		cmd := exec.Command("git", "config", "--get", fmt.Sprintf("remote.%s.url", remote))
@@ -33,22 +94,31 @@ func gitGetGitHubURL() (string, string, error) {
 		return string(bytes.TrimSpace(output)), nil
 	}
 
-	for _, remote := range []string{"origin", "github"} {
-		remote, err := try(remote)
+	for _, name := range []string{"origin", "github"} {
+		remote, err := try(name)
 		if err != nil {
-			return "", "", err
+			return "", "", "", "", err
 		}
-		if match := matchGitHubURL.FindStringSubmatch(remote); match != nil {
-			return match[1], match[2], nil
+		if host, owner, repository := parseGitHubURL(remote); host != "" {
+			return name, host, owner, repository, nil
 		}
 	}
-	return "", "", nil
+	return "", "", "", "", nil
 }
 
 // git describe --tags --exact-match
 
 func gitGetTag() (string, error) {
+	if useNativeGit() {
+		repo, err := openGitRepository(".")
+		if err != nil {
+			return "", err
+		}
+		return repo.tag()
+	}
+
 	cmd := exec.Command("git", "describe", "--tags", "--exact-match")
+	cmd.Env = append(os.Environ(), "LC_ALL=C") // The error messages below are not localized
 	output, err := cmd.CombinedOutput()
 	lg.dbg("git describe --tags --exact-match:\n%s---", string(output))
 	if err != nil {
@@ -79,6 +149,14 @@ func firstLine(lines []byte) string {
 // git rev-list <tag>
 
 func gitGetTagCommit(tag string) (string, error) {
+	if useNativeGit() {
+		repo, err := openGitRepository(".")
+		if err != nil {
+			return "", err
+		}
+		return repo.tagCommit(tag)
+	}
+
 	cmd := exec.Command("git", "rev-list", tag)
 	output, err := cmd.CombinedOutput()
 	lg.dbg("git rev-list:\n%s---", string(output))
@@ -88,6 +166,168 @@ func gitGetTagCommit(tag string) (string, error) {
 	return firstLine(output), nil
 }
 
+// git status --porcelain --untracked-files=no
+
+func gitGetChanges() ([]string, error) {
+	cmd := exec.Command("git", "status", "--porcelain", "--untracked-files=no")
+	output, err := cmd.CombinedOutput()
+	lg.dbg("git status --porcelain --untracked-files=no:\n%s---", string(output))
+	if err != nil {
+		return nil, fmt.Errorf("git: %v: %s", err, firstLine(output))
+	}
+	var changes []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			changes = append(changes, line)
+		}
+	}
+	return changes, nil
+}
+
+// git rev-parse --show-toplevel
+
+func gitGetTopLevel() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	output, err := cmd.CombinedOutput()
+	lg.dbg("git rev-parse --show-toplevel:\n%s---", string(output))
+	if err != nil {
+		return "", fmt.Errorf("git: %v: %s", err, firstLine(output))
+	}
+	return firstLine(output), nil
+}
+
+// git status --porcelain --untracked-files=all -- <path> ...
+
+func gitGetUntracked(paths ...string) ([]string, error) {
+	arguments := append([]string{"status", "--porcelain", "--untracked-files=all", "--"}, paths...)
+	cmd := exec.Command("git", arguments...)
+	output, err := cmd.CombinedOutput()
+	lg.dbg("git %s:\n%s---", strings.Join(arguments, " "), string(output))
+	if err != nil {
+		return nil, fmt.Errorf("git: %v: %s", err, firstLine(output))
+	}
+	var untracked []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "?? ") {
+			untracked = append(untracked, strings.TrimSpace(line[3:]))
+		}
+	}
+	return untracked, nil
+}
+
+// git rev-parse refs/tags/<tag>
+
+func gitGetTagObject(tag string) (string, error) {
+	if useNativeGit() {
+		repo, err := openGitRepository(".")
+		if err != nil {
+			return "", err
+		}
+		return repo.tagObject(tag)
+	}
+
+	cmd := exec.Command("git", "rev-parse", "refs/tags/"+tag)
+	output, err := cmd.CombinedOutput()
+	lg.dbg("git rev-parse refs/tags/%s:\n%s---", tag, string(output))
+	if err != nil {
+		return "", fmt.Errorf("git: %v: %s", err, firstLine(output))
+	}
+	return firstLine(output), nil
+}
+
+// git ls-remote --tags <remote> refs/tags/<tag>
+
+func gitGetRemoteTagObject(remote, tag string) (string, error) {
+	cmd := exec.Command("git", "ls-remote", "--tags", remote, "refs/tags/"+tag)
+	output, err := cmd.CombinedOutput()
+	lg.dbg("git ls-remote --tags %s refs/tags/%s:\n%s---", remote, tag, string(output))
+	if err != nil {
+		return "", fmt.Errorf("git: %v: %s", err, firstLine(output))
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[1] == "refs/tags/"+tag {
+			return fields[0], nil
+		}
+	}
+	return "", nil
+}
+
+// git ls-remote --heads <remote>
+// git merge-base --is-ancestor <commit> <branch>
+
+// gitIsPushed returns whether <commit> is on a branch of <remote>, as it is now
+// (not as the remote-tracking branches last saw it). Nothing is fetched, so if
+// <commit> is on no branch it can tell, it also returns the branches it cannot
+// (those with a commit that is not here, until they are fetched).
+func gitIsPushed(remote, commit string) (bool, []string, error) {
+	cmd := exec.Command("git", "ls-remote", "--heads", remote)
+	output, err := cmd.CombinedOutput()
+	lg.dbg("git ls-remote --heads %s:\n%s---", remote, string(output))
+	if err != nil {
+		return false, nil, fmt.Errorf("git: %v: %s", err, firstLine(output))
+	}
+	var unknown []string
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if fields[0] == commit {
+			return true, nil, nil
+		}
+		if !gitHasCommit(fields[0]) {
+			unknown = append(unknown, strings.TrimPrefix(fields[1], "refs/heads/"))
+			continue
+		}
+		cmd := exec.Command("git", "merge-base", "--is-ancestor", commit, fields[0])
+		output, err := cmd.CombinedOutput()
+		lg.dbg("git merge-base --is-ancestor %s %s:\n%s---", commit, fields[0], string(output))
+		if err == nil {
+			return true, nil, nil
+		}
+		if exit, ok := err.(*exec.ExitError); !ok || exit.ExitCode() != 1 {
+			return false, nil, fmt.Errorf("git: %v: %s", err, firstLine(output))
+		}
+	}
+	return false, unknown, nil
+}
+
+// git cat-file -e <commit>^{commit}
+
+func gitHasCommit(commit string) bool {
+	return exec.Command("git", "cat-file", "-e", commit+"^{commit}").Run() == nil
+}
+
+// git tag --annotate [--sign] --cleanup=whitespace --message <message> <tag>
+
+func gitCreateTag(tag, message string, sign bool) error {
+	// --cleanup=whitespace, otherwise the "### ..." headings of the message are stripped as comments
+	arguments := []string{"tag", "--annotate", "--cleanup=whitespace", "--message", message, tag}
+	if sign {
+		arguments[1] = "--sign"
+	}
+	cmd := exec.Command("git", arguments...)
+	output, err := cmd.CombinedOutput()
+	lg.dbg("git %s:\n%s---", strings.Join(arguments, " "), string(output))
+	if err != nil {
+		return fmt.Errorf("git: %v: %s", err, firstLine(output))
+	}
+	return nil
+}
+
+// git push <remote> refs/tags/<tag>
+
+func gitPushTag(remote, tag string) error {
+	cmd := exec.Command("git", "push", remote, "refs/tags/"+tag)
+	output, err := cmd.CombinedOutput()
+	lg.dbg("git push %s refs/tags/%s:\n%s---", remote, tag, string(output))
+	if err != nil {
+		return fmt.Errorf("git: %v: %s", err, firstLine(output))
+	}
+	return nil
+}
+
 //func gitGetProgramName() (string, error) {
 //    return "gphr", nil
 //    cmd := exec.Command("go", "build", "-n")
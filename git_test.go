@@ -0,0 +1,465 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"./gphr/terst"
+)
+
+var is = terst.Is
+
+type _gitFixture struct {
+	t   *testing.T
+	dir string // The working tree
+}
+
+func newGitFixture(t *testing.T) *_gitFixture {
+	dir, err := ioutil.TempDir("", "gphr-git-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixture := &_gitFixture{t: t, dir: dir}
+	for _, path := range []string{".git/objects/pack", ".git/refs/heads", ".git/refs/tags"} {
+		fixture.mkdir(path)
+	}
+	fixture.write(".git/HEAD", "ref: refs/heads/master\n")
+	return fixture
+}
+
+func (fixture *_gitFixture) close() {
+	os.RemoveAll(fixture.dir)
+}
+
+func (fixture *_gitFixture) mkdir(path string) {
+	err := os.MkdirAll(filepath.Join(fixture.dir, path), 0755)
+	if err != nil {
+		fixture.t.Fatal(err)
+	}
+}
+
+func (fixture *_gitFixture) write(path, content string) {
+	fixture.mkdir(filepath.Dir(path))
+	err := ioutil.WriteFile(filepath.Join(fixture.dir, path), []byte(content), 0644)
+	if err != nil {
+		fixture.t.Fatal(err)
+	}
+}
+
+func gitObjectSHA(kind string, data []byte) string {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "%s %d\x00", kind, len(data))
+	hash.Write(data)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func deflate(data []byte) []byte {
+	buffer := bytes.NewBuffer(nil)
+	writer := zlib.NewWriter(buffer)
+	writer.Write(data)
+	writer.Close()
+	return buffer.Bytes()
+}
+
+// object writes a loose object
+func (fixture *_gitFixture) object(kind, data string) string {
+	sha := gitObjectSHA(kind, []byte(data))
+	content := deflate([]byte(fmt.Sprintf("%s %d\x00%s", kind, len(data), data)))
+	fixture.write(filepath.Join(".git/objects", sha[:2], sha[2:]), string(content))
+	return sha
+}
+
+// pack writes a packfile (and index) of commit objects, where each commit after
+// the first is stored as an OFS_DELTA against the one before it.
+func (fixture *_gitFixture) pack(commits ...string) []string {
+	pack := bytes.NewBuffer(nil)
+	pack.WriteString("PACK")
+	binary.Write(pack, binary.BigEndian, uint32(2))
+	binary.Write(pack, binary.BigEndian, uint32(len(commits)))
+
+	header := func(kind byte, size int) {
+		chr := kind<<4 | byte(size&0x0f)
+		size >>= 4
+		for size > 0 {
+			pack.WriteByte(chr | 0x80)
+			chr = byte(size & 0x7f)
+			size >>= 7
+		}
+		pack.WriteByte(chr)
+	}
+	varint := func(buffer *bytes.Buffer, size int) {
+		for size >= 0x80 {
+			buffer.WriteByte(byte(size&0x7f) | 0x80)
+			size >>= 7
+		}
+		buffer.WriteByte(byte(size))
+	}
+
+	var shas []string
+	var offsets []int64
+	for index, commit := range commits {
+		offset := int64(pack.Len())
+		if index == 0 {
+			header(1, len(commit))
+			pack.Write(deflate([]byte(commit)))
+		} else {
+			// Copy the first byte of the base, then insert the rest
+			base := commits[index-1]
+			delta := bytes.NewBuffer(nil)
+			varint(delta, len(base))
+			varint(delta, len(commit))
+			delta.Write([]byte{0x80 | 0x10, 0x01}) // copy offset=0 length=1
+			if commit[0] != base[0] {
+				panic("fixture: commits must start with the same byte")
+			}
+			rest := commit[1:]
+			for len(rest) > 0 {
+				length := len(rest)
+				if length > 0x7f {
+					length = 0x7f
+				}
+				delta.WriteByte(byte(length))
+				delta.WriteString(rest[:length])
+				rest = rest[length:]
+			}
+			header(6, delta.Len())
+			distance := offset - offsets[index-1]
+			// The (peculiar) offset encoding of OFS_DELTA
+			encoded := []byte{byte(distance & 0x7f)}
+			for distance >>= 7; distance > 0; distance >>= 7 {
+				distance--
+				encoded = append([]byte{byte(0x80 | distance&0x7f)}, encoded...)
+			}
+			pack.Write(encoded)
+			pack.Write(deflate(delta.Bytes()))
+		}
+		shas = append(shas, gitObjectSHA("commit", []byte(commit)))
+		offsets = append(offsets, offset)
+	}
+	checksum := sha1.Sum(pack.Bytes())
+	pack.Write(checksum[:])
+
+	order := make([]int, len(shas))
+	for index := range order {
+		order[index] = index
+	}
+	sort.Slice(order, func(i, j int) bool { return shas[order[i]] < shas[order[j]] })
+
+	idx := bytes.NewBuffer(nil)
+	idx.Write([]byte{0xff, 't', 'O', 'c'})
+	binary.Write(idx, binary.BigEndian, uint32(2))
+	for fanout := 0; fanout < 256; fanout++ {
+		count := 0
+		for _, sha := range shas {
+			raw, _ := hex.DecodeString(sha)
+			if int(raw[0]) <= fanout {
+				count++
+			}
+		}
+		binary.Write(idx, binary.BigEndian, uint32(count))
+	}
+	for _, index := range order {
+		raw, _ := hex.DecodeString(shas[index])
+		idx.Write(raw)
+	}
+	for range order {
+		binary.Write(idx, binary.BigEndian, uint32(0)) // CRC32 (unused)
+	}
+	for _, index := range order {
+		binary.Write(idx, binary.BigEndian, uint32(offsets[index]))
+	}
+	idx.Write(checksum[:])
+
+	name := hex.EncodeToString(checksum[:])
+	fixture.write(".git/objects/pack/pack-"+name+".pack", pack.String())
+	fixture.write(".git/objects/pack/pack-"+name+".idx", idx.String())
+	return shas
+}
+
+func TestGitNativeConfig(t *testing.T) {
+	terst.Terst(t, func() {
+		config, err := parseGitConfig(strings.NewReader(`
+# A comment
+[core]
+	bare = false
+	ignorecase
+[remote "origin"]
+	url = git@github.com:alice/example.git ; A comment
+	fetch = +refs/heads/*:refs/remotes/origin/*
+[remote "The \"Other\""] URL = "https://github.com/alice/other.git"
+[branch.master]
+	remote = origin
+`))
+		is(err, nil)
+		is(config["core.bare"], "false")
+		is(config["core.ignorecase"], "true")
+		is(config["remote.origin.url"], "git@github.com:alice/example.git")
+		is(config["remote.The \"Other\".url"], "https://github.com/alice/other.git")
+		is(config["branch.master.remote"], "origin")
+	})
+}
+
+func TestGitNative(t *testing.T) {
+	terst.Terst(t, func() {
+		fixture := newGitFixture(t)
+		defer fixture.close()
+
+		fixture.write(".git/config", "[remote \"origin\"]\n\turl = https://github.com/alice/example.git\n")
+
+		tree := fixture.object("tree", "")
+		commit := func(parent, message string) string {
+			content := "tree " + tree + "\n"
+			if parent != "" {
+				content += "parent " + parent + "\n"
+			}
+			return content + "author Alice <alice@example.com> 1400000000 +0000\ncommitter Alice <alice@example.com> 1400000000 +0000\n\n" + message + "\n"
+		}
+
+		// The first two commits are packed (the second as a delta), the third is loose
+		first := commit("", "First")
+		shas := fixture.pack(first, commit(gitObjectSHA("commit", []byte(first)), "Second"))
+		third := fixture.object("commit", commit(shas[1], "Third"))
+
+		annotated := fixture.object("tag", "object "+shas[1]+"\ntype commit\ntag v0.2.0\ntagger Alice <alice@example.com> 1400000000 +0000\n\nRelease v0.2.0\n")
+		fixture.write(".git/packed-refs", "# pack-refs with: peeled fully-peeled\n"+
+			shas[0]+" refs/tags/v0.1.0\n"+
+			annotated+" refs/tags/v0.2.0\n^"+shas[1]+"\n")
+		fixture.write(".git/refs/heads/master", shas[1]+"\n")
+
+		repo, err := openGitRepository(filepath.Join(fixture.dir, ".git", "refs"))
+		is(err, nil)
+
+		remote, host, owner, repository, err := repo.githubURL()
+		is(err, nil)
+		is(remote, "origin")
+		is(host, "github.com")
+		is(owner, "alice")
+		is(repository, "example")
+
+		kind, data, err := repo.object(shas[1])
+		is(err, nil)
+		is(kind, "commit")
+		is(string(data), "=~", "\n\nSecond\n$")
+
+		tag, err := repo.tag()
+		is(err, nil)
+		is(tag, "v0.2.0")
+
+		sha, err := repo.tagCommit("v0.2.0")
+		is(err, nil)
+		is(sha, shas[1])
+
+		sha, err = repo.tagCommit("v0.1.0")
+		is(err, nil)
+		is(sha, shas[0])
+
+		sha, err = repo.tagCommit("HEAD")
+		is(err, nil)
+		is(sha, shas[1])
+
+		_, err = repo.tagCommit("v0.3.0")
+		is(err, "git: unknown revision: v0.3.0")
+
+		// A lightweight (loose) tag on a detached HEAD
+		fixture.write(".git/refs/tags/v0.3.0", third+"\n")
+		fixture.write(".git/HEAD", third+"\n")
+
+		tag, err = repo.tag()
+		is(err, nil)
+		is(tag, "v0.3.0")
+
+		// A worktree
+		fixture.write(".git/worktrees/other/HEAD", "ref: refs/heads/master\n")
+		fixture.write(".git/worktrees/other/commondir", "../..\n")
+		fixture.write("other/.git", "gitdir: ../.git/worktrees/other\n")
+
+		repo, err = openGitRepository(filepath.Join(fixture.dir, "other"))
+		is(err, nil)
+
+		tag, err = repo.tag()
+		is(err, nil)
+		is(tag, "v0.2.0")
+	})
+}
+
+// TestGitNativeExec compares the native implementation against git itself (if installed).
+func TestGitNativeExec(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	terst.Terst(t, func() {
+		dir, err := ioutil.TempDir("", "gphr-git-")
+		is(err, nil)
+		defer os.RemoveAll(dir)
+
+		now := 1400000000
+		run := func(arguments ...string) string {
+			now += 3600 // Every commit (and tag) an hour later, in -0500 (so not always the same day in UTC)
+			cmd := exec.Command("git", arguments...)
+			cmd.Dir = dir
+			cmd.Env = append(os.Environ(),
+				"GIT_AUTHOR_NAME=Alice", "GIT_AUTHOR_EMAIL=alice@example.com",
+				"GIT_COMMITTER_NAME=Alice", "GIT_COMMITTER_EMAIL=alice@example.com",
+				fmt.Sprintf("GIT_AUTHOR_DATE=%d -0500", now), fmt.Sprintf("GIT_COMMITTER_DATE=%d -0500", now),
+			)
+			output, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("git %s: %v: %s", strings.Join(arguments, " "), err, output)
+			}
+			return strings.TrimSpace(string(output))
+		}
+
+		run("init", "-q")
+		run("remote", "add", "origin", "git@github.com:alice/example.git")
+		for index := 0; index < 3; index++ {
+			run("commit", "-q", "--allow-empty", "-m", fmt.Sprintf("Commit %d", index))
+			run("tag", "-a", "-m", "Release", fmt.Sprintf("v0.%d.0", index))
+		}
+		run("tag", "lightweight", "HEAD~1")
+
+		// A merge (of a branch, with commits in between those of master), and a
+		// message of more than one line (the subject is the first paragraph)
+		run("checkout", "-q", "-b", "topic")
+		run("commit", "-q", "--allow-empty", "-m", "feat: Topic 1")
+		run("checkout", "-q", "master")
+		run("commit", "-q", "--allow-empty", "-m", "fix: Master 1\nwrapped\n\nThe body")
+		run("checkout", "-q", "topic")
+		run("commit", "-q", "--allow-empty", "-m", "feat: Topic 2")
+		run("checkout", "-q", "master")
+		run("merge", "-q", "--no-ff", "-m", "Merge topic", "topic")
+		run("tag", "-a", "-m", "Release v0.3.0\n\nWith notes", "v0.3.0")
+		run("commit", "-q", "--allow-empty", "-m", "chore: Unreleased")
+		run("gc", "-q", "--aggressive")
+		run("commit", "-q", "--allow-empty", "-m", "chore: Loose")
+		run("tag", "v0.4.0")
+
+		repo, err := openGitRepository(dir)
+		is(err, nil)
+
+		tag, err := repo.tag()
+		is(err, nil)
+		is(tag, run("describe", "--tags", "--exact-match"))
+
+		for _, tag := range []string{"v0.0.0", "v0.1.0", "v0.2.0", "lightweight", "HEAD"} {
+			sha, err := repo.tagCommit(tag)
+			is(err, nil)
+			is(sha, run("rev-list", "-n", "1", tag))
+		}
+
+		versions, err := repo.versions()
+		is(err, nil)
+		is(strings.Join(versions, "\n"), run("tag", "--list"))
+
+		lines := func(output string) []string {
+			if output == "" {
+				return nil
+			}
+			return strings.Split(output, "\n")
+		}
+		for _, tag := range []string{"v0.0.0", "v0.1.0", "v0.2.0", "lightweight", "v0.3.0", "v0.4.0"} {
+			sha, err := repo.tagObject(tag)
+			is(err, nil)
+			is(sha, run("rev-parse", "refs/tags/"+tag))
+
+			message, err := repo.tagMessage(tag)
+			is(err, nil)
+			if expected := run("for-each-ref", "--format=%(objecttype) %(contents)", "refs/tags/"+tag); strings.HasPrefix(expected, "tag ") {
+				is(message, strings.TrimPrefix(expected, "tag "))
+			} else {
+				is(message, "")
+			}
+
+			date, err := repo.tagDate(tag)
+			is(err, nil)
+			is(date, run("log", "-1", "--format=%ad", "--date=short", tag))
+
+			previous, err := repo.previousTag(tag)
+			is(err, nil)
+			if tag == "v0.0.0" {
+				is(previous, "")
+			} else {
+				is(previous, run("describe", "--tags", "--abbrev=0", tag+"^"))
+			}
+
+			subjects, err := repo.subjects(previous, tag)
+			is(err, nil)
+			revision := tag
+			if previous != "" {
+				revision = previous + ".." + tag
+			}
+			is(subjects, lines(run("log", "--format=%s", revision)))
+		}
+
+		subjects, err := repo.subjects("v0.3.0", "HEAD")
+		is(err, nil)
+		is(subjects, []string{"chore: Loose", "chore: Unreleased"})
+		subjects, err = repo.subjects("v0.2.0", "v0.3.0")
+		is(err, nil)
+		is(subjects, []string{"Merge topic", "feat: Topic 2", "fix: Master 1 wrapped", "feat: Topic 1"})
+	})
+}
+
+func TestParseGitHubURL(t *testing.T) {
+	terst.Terst(t, func() {
+		for _, test := range [][4]string{
+			{"https://github.com/alice/example.git", "github.com", "alice", "example"},
+			{"https://github.com/alice/example", "github.com", "alice", "example"},
+			{"git@github.com:alice/example.git", "github.com", "alice", "example"},
+			{"git@github.com-work:alice/example.git", "github.com", "alice", "example"},
+			{"ssh://git@github.example.com/alice/example.git", "github.example.com", "alice", "example"},
+			{"https://token@github.example.com/alice/example.git", "github.example.com", "alice", "example"},
+			{"https://github.example.com:8443/alice/example.git", "github.example.com:8443", "alice", "example"},
+			{"https://github.com/alice/example/", "github.com", "alice", "example"},
+			{"ssh://git@github.example.com:2222/alice/example.git", "github.example.com", "alice", "example"},
+			{"ssh://git@github.example.com/alice/example", "github.example.com", "alice", "example"},
+			{"alice@gitea.example.com:alice/example.git", "gitea.example.com", "alice", "example"},
+			{"https://gitlab.com/group/subgroup/example.git", "gitlab.com", "group/subgroup", "example"},
+			{"git@gitlab.com:group/subgroup/example.git", "gitlab.com", "group/subgroup", "example"},
+			{"https://github.com/example.git", "", "", ""},
+			{"https://github.com/alice//example.git", "", "", ""},
+			{"git@github.com:alice/../example.git", "", "", ""},
+			{"file:///srv/git/alice/example.git", "", "", ""},
+			{"/srv/git/example.git", "", "", ""},
+			{"../example.git", "", "", ""},
+		} {
+			host, owner, repository := parseGitHubURL(test[0])
+			is(host, test[1])
+			is(owner, test[2])
+			is(repository, test[3])
+		}
+	})
+}
+
+func TestGetRepository(t *testing.T) {
+	terst.Terst(t, func() {
+		for _, test := range [][4]string{
+			{"alice/example", "github.com", "alice", "example"},
+			{"github.com/alice/example", "github.com", "alice", "example"},
+			{"github.example.com/alice/example/", "github.example.com", "alice", "example"},
+			{"gitlab.com/group/subgroup/example", "gitlab.com", "group/subgroup", "example"},
+		} {
+			host, owner, repository, err := getRepository(test[0])
+			is(err, nil)
+			is(host, test[1])
+			is(owner, test[2])
+			is(repository, test[3])
+		}
+
+		for _, repository := range []string{"example", "github.com//example", "/"} {
+			_, _, _, err := getRepository(repository)
+			is(err, "cannot determine GitHub repository (owner/repository) from: "+repository)
+		}
+	})
+}
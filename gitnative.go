@@ -0,0 +1,910 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A (read-only) git repository, read without the git binary:
+//
+//     .git/config
+//     .git/HEAD
+//     .git/refs/...
+//     .git/packed-refs
+//     .git/objects/xx/xxxxxx...
+//     .git/objects/pack/pack-*.{idx,pack}
+//
+// This is enough to find the GitHub remote, the tag for HEAD, the commit (and
+// the object) of a tag, and what the release notes and changelog need: the
+// message of a tag, the tag before it, the commit subjects between them, the
+// semver tags, and the date of each (gitGetGitHubURL, gitGetTag,
+// gitGetTagCommit, gitGetTagObject, gitGetTagMessage, gitGetPreviousTag,
+// gitGetSubjects, gitGetVersions, gitGetTagDate).
+//
+// What needs the working tree (the index), the remote, or writing (checking
+// for uncommitted changes, untracked files, and an unpushed HEAD or tag before
+// a release, and gphr tag) still needs the git binary: release skips those
+// checks (with a warning), and tag refuses.
+
+type _gitRepository struct {
+	gitDir    string // .git (or .git/worktrees/<name>)
+	commonDir string // .git
+	packs     []*_gitPack
+}
+
+var matchGitSHA = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// useNativeGit returns true if git should be read natively (-git=native, or
+// -git=auto without a git binary).
+func useNativeGit() bool {
+	switch *flags.main.git {
+	case "native":
+		return true
+	case "exec":
+		return false
+	}
+	_, err := exec.LookPath("git")
+	return err != nil
+}
+
+// openGitRepository finds the repository containing <path> (like git does, by
+// looking for .git in <path> and each of its parents).
+func openGitRepository(path string) (*_gitRepository, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		gitDir := filepath.Join(path, ".git")
+		if stat, err := os.Stat(gitDir); err == nil {
+			if !stat.IsDir() {
+				// A worktree (or submodule): "gitdir: <path>"
+				content, err := ioutil.ReadFile(gitDir)
+				if err != nil {
+					return nil, err
+				}
+				line := strings.TrimSpace(string(content))
+				if !strings.HasPrefix(line, "gitdir:") {
+					return nil, fmt.Errorf("git: invalid .git file: %s", gitDir)
+				}
+				gitDir = strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+				if !filepath.IsAbs(gitDir) {
+					gitDir = filepath.Join(path, gitDir)
+				}
+			}
+			return newGitRepository(gitDir)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return nil, fmt.Errorf("git: not a git repository (or any of the parent directories)")
+		}
+		path = parent
+	}
+}
+
+func newGitRepository(gitDir string) (*_gitRepository, error) {
+	repo := &_gitRepository{
+		gitDir:    gitDir,
+		commonDir: gitDir,
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir := strings.TrimSpace(string(content))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+		repo.commonDir = commonDir
+	}
+	if _, err := os.Stat(filepath.Join(repo.commonDir, "objects")); err != nil {
+		return nil, fmt.Errorf("git: not a git repository: %s", gitDir)
+	}
+	return repo, nil
+}
+
+// config returns the configuration of the repository, keyed by the canonical
+// name of each variable (e.g. "remote.origin.url").
+func (repo *_gitRepository) config() (map[string]string, error) {
+	file, err := os.Open(filepath.Join(repo.commonDir, "config"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	return parseGitConfig(file)
+}
+
+var (
+	matchGitConfigSection  = regexp.MustCompile(`^\[\s*([^\s"\]]+)(?:\s+"((?:[^"\\]|\\.)*)")?\s*\]\s*(.*)$`)
+	matchGitConfigVariable = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9-]*)\s*(?:=\s*(.*))?$`)
+)
+
+func parseGitConfig(input io.Reader) (map[string]string, error) {
+	config := map[string]string{}
+	section := ""
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			match := matchGitConfigSection.FindStringSubmatch(line)
+			if match == nil {
+				return nil, fmt.Errorf("git: invalid config section: %s", line)
+			}
+			name := strings.ToLower(match[1])
+			if index := strings.Index(name, "."); index != -1 {
+				// [section.subsection] (deprecated)
+				section = name
+			} else if match[2] != "" {
+				section = name + "." + strings.Replace(strings.Replace(match[2], `\"`, `"`, -1), `\\`, `\`, -1)
+			} else {
+				section = name
+			}
+			line = strings.TrimSpace(match[3])
+			if line == "" || line[0] == '#' || line[0] == ';' {
+				continue
+			}
+		}
+		match := matchGitConfigVariable.FindStringSubmatch(line)
+		if match == nil {
+			return nil, fmt.Errorf("git: invalid config variable: %s", line)
+		}
+		value := "true" // A variable without a value is a boolean (true)
+		if strings.Contains(line, "=") {
+			value = parseGitConfigValue(match[2])
+		}
+		config[section+"."+strings.ToLower(match[1])] = value
+	}
+	return config, scanner.Err()
+}
+
+func parseGitConfigValue(input string) string {
+	value := bytes.NewBuffer(nil)
+	quoted := false
+	for index := 0; index < len(input); index++ {
+		switch chr := input[index]; {
+		case chr == '"':
+			quoted = !quoted
+		case chr == '\\' && index+1 < len(input):
+			index++
+			switch input[index] {
+			case 'n':
+				value.WriteByte('\n')
+			case 't':
+				value.WriteByte('\t')
+			default:
+				value.WriteByte(input[index])
+			}
+		case (chr == '#' || chr == ';') && !quoted:
+			return strings.TrimSpace(value.String())
+		default:
+			value.WriteByte(chr)
+		}
+	}
+	return strings.TrimSpace(value.String())
+}
+
+// refs returns every ref of the repository (loose and packed), keyed by name
+// (e.g. "refs/tags/v1.0.0").
+func (repo *_gitRepository) refs() (map[string]string, error) {
+	refs := map[string]string{}
+
+	file, err := os.Open(filepath.Join(repo.commonDir, "packed-refs"))
+	if err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "" || line[0] == '#' || line[0] == '^' {
+				continue // A comment, or a peeled tag (^<commit>)
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 2 && matchGitSHA.MatchString(fields[0]) {
+				refs[fields[1]] = fields[0]
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	root := filepath.Join(repo.commonDir, "refs")
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sha := strings.TrimSpace(string(content))
+		if !matchGitSHA.MatchString(sha) {
+			return nil // A symbolic ref (refs/remotes/origin/HEAD), ...
+		}
+		name, err := filepath.Rel(repo.commonDir, path)
+		if err != nil {
+			return err
+		}
+		refs[filepath.ToSlash(name)] = sha
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return refs, nil
+}
+
+// resolve returns the object named by <name>: a sha, HEAD, a full ref name, or
+// a tag/branch name.
+func (repo *_gitRepository) resolve(name string) (string, error) {
+	if matchGitSHA.MatchString(name) {
+		return name, nil
+	}
+	if name == "HEAD" {
+		content, err := ioutil.ReadFile(filepath.Join(repo.gitDir, "HEAD"))
+		if err != nil {
+			return "", err
+		}
+		head := strings.TrimSpace(string(content))
+		if !strings.HasPrefix(head, "ref:") {
+			return head, nil // Detached
+		}
+		name = strings.TrimSpace(strings.TrimPrefix(head, "ref:"))
+	}
+	refs, err := repo.refs()
+	if err != nil {
+		return "", err
+	}
+	for _, tmp := range []string{name, "refs/" + name, "refs/tags/" + name, "refs/heads/" + name, "refs/remotes/" + name} {
+		if sha, exists := refs[tmp]; exists {
+			return sha, nil
+		}
+	}
+	return "", fmt.Errorf("git: unknown revision: %s", name)
+}
+
+// peel follows (annotated) tag objects from <sha> until it reaches a commit.
+func (repo *_gitRepository) peel(sha string) (string, error) {
+	for depth := 0; depth < 16; depth++ {
+		kind, data, err := repo.object(sha)
+		if err != nil {
+			return "", err
+		}
+		switch kind {
+		case "commit":
+			return sha, nil
+		case "tag":
+			object := ""
+			for _, line := range strings.Split(string(data), "\n") {
+				if strings.HasPrefix(line, "object ") {
+					object = strings.TrimSpace(line[len("object "):])
+					break
+				}
+				if line == "" {
+					break
+				}
+			}
+			if !matchGitSHA.MatchString(object) {
+				return "", fmt.Errorf("git: invalid tag object: %s", sha)
+			}
+			sha = object
+		default:
+			return "", fmt.Errorf("git: %s is a %s, not a commit", sha, kind)
+		}
+	}
+	return "", fmt.Errorf("git: too many levels of tags: %s", sha)
+}
+
+// object returns the type and content of the object <sha> (loose or packed).
+func (repo *_gitRepository) object(sha string) (string, []byte, error) {
+	path := filepath.Join(repo.commonDir, "objects", sha[:2], sha[2:])
+	if file, err := os.Open(path); err == nil {
+		defer file.Close()
+		reader, err := zlib.NewReader(file)
+		if err != nil {
+			return "", nil, fmt.Errorf("git: %s: %v", sha, err)
+		}
+		content, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return "", nil, fmt.Errorf("git: %s: %v", sha, err)
+		}
+		index := bytes.IndexByte(content, 0)
+		if index == -1 {
+			return "", nil, fmt.Errorf("git: %s: invalid object", sha)
+		}
+		header := strings.Fields(string(content[:index]))
+		if len(header) != 2 {
+			return "", nil, fmt.Errorf("git: %s: invalid object header", sha)
+		}
+		return header[0], content[index+1:], nil
+	} else if !os.IsNotExist(err) {
+		return "", nil, err
+	}
+
+	if repo.packs == nil {
+		paths, err := filepath.Glob(filepath.Join(repo.commonDir, "objects", "pack", "pack-*.idx"))
+		if err != nil {
+			return "", nil, err
+		}
+		sort.Strings(paths)
+		repo.packs = []*_gitPack{}
+		for _, path := range paths {
+			pack, err := openGitPack(path)
+			if err != nil {
+				return "", nil, err
+			}
+			repo.packs = append(repo.packs, pack)
+		}
+	}
+
+	raw, err := hex.DecodeString(sha)
+	if err != nil {
+		return "", nil, fmt.Errorf("git: invalid object name: %s", sha)
+	}
+	for _, pack := range repo.packs {
+		if offset, exists := pack.find(raw); exists {
+			return pack.object(repo, offset)
+		}
+	}
+
+	return "", nil, fmt.Errorf("git: object not found: %s", sha)
+}
+
+// A packfile (version 2), along with its (version 2) index
+type _gitPack struct {
+	path    string // .pack
+	names   [][]byte
+	offsets []int64
+}
+
+var gitPackTypes = map[byte]string{1: "commit", 2: "tree", 3: "blob", 4: "tag"}
+
+func openGitPack(path string) (*_gitPack, error) {
+	idx, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(idx) < 8+256*4 || !bytes.Equal(idx[:4], []byte{0xff, 't', 'O', 'c'}) || binary.BigEndian.Uint32(idx[4:8]) != 2 {
+		return nil, fmt.Errorf("git: %s: unsupported pack index (not version 2)", path)
+	}
+	count := int(binary.BigEndian.Uint32(idx[8+255*4:]))
+	names := 8 + 256*4
+	crcs := names + count*20
+	offsets := crcs + count*4
+	large := offsets + count*4
+	if len(idx) < large {
+		return nil, fmt.Errorf("git: %s: truncated pack index", path)
+	}
+
+	pack := &_gitPack{
+		path:    strings.TrimSuffix(path, ".idx") + ".pack",
+		names:   make([][]byte, count),
+		offsets: make([]int64, count),
+	}
+	for index := 0; index < count; index++ {
+		pack.names[index] = idx[names+index*20 : names+(index+1)*20]
+		offset := binary.BigEndian.Uint32(idx[offsets+index*4:])
+		if offset&0x80000000 != 0 {
+			position := large + int(offset&0x7fffffff)*8
+			if len(idx) < position+8 {
+				return nil, fmt.Errorf("git: %s: truncated pack index", path)
+			}
+			pack.offsets[index] = int64(binary.BigEndian.Uint64(idx[position:]))
+		} else {
+			pack.offsets[index] = int64(offset)
+		}
+	}
+	return pack, nil
+}
+
+func (pack *_gitPack) find(sha []byte) (int64, bool) {
+	index := sort.Search(len(pack.names), func(index int) bool {
+		return bytes.Compare(pack.names[index], sha) >= 0
+	})
+	if index < len(pack.names) && bytes.Equal(pack.names[index], sha) {
+		return pack.offsets[index], true
+	}
+	return 0, false
+}
+
+func (pack *_gitPack) object(repo *_gitRepository, offset int64) (string, []byte, error) {
+	file, err := os.Open(pack.path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+
+	_, err = file.Seek(offset, 0)
+	if err != nil {
+		return "", nil, err
+	}
+	reader := bufio.NewReader(file)
+
+	// The header: type (3 bits) and size (variable length)
+	chr, err := reader.ReadByte()
+	if err != nil {
+		return "", nil, err
+	}
+	kind := (chr >> 4) & 0x7
+	for chr&0x80 != 0 {
+		chr, err = reader.ReadByte()
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	var base func() (string, []byte, error)
+	switch kind {
+	case 6: // OFS_DELTA
+		chr, err := reader.ReadByte()
+		if err != nil {
+			return "", nil, err
+		}
+		distance := int64(chr & 0x7f)
+		for chr&0x80 != 0 {
+			chr, err = reader.ReadByte()
+			if err != nil {
+				return "", nil, err
+			}
+			distance = ((distance + 1) << 7) | int64(chr&0x7f)
+		}
+		base = func() (string, []byte, error) {
+			return pack.object(repo, offset-distance)
+		}
+	case 7: // REF_DELTA
+		sha := make([]byte, 20)
+		_, err := io.ReadFull(reader, sha)
+		if err != nil {
+			return "", nil, err
+		}
+		base = func() (string, []byte, error) {
+			return repo.object(hex.EncodeToString(sha))
+		}
+	}
+
+	inflate, err := zlib.NewReader(reader)
+	if err != nil {
+		return "", nil, fmt.Errorf("git: %s: %v", pack.path, err)
+	}
+	data, err := ioutil.ReadAll(inflate)
+	if err != nil {
+		return "", nil, fmt.Errorf("git: %s: %v", pack.path, err)
+	}
+
+	if base == nil {
+		name, exists := gitPackTypes[kind]
+		if !exists {
+			return "", nil, fmt.Errorf("git: %s: invalid object type: %d", pack.path, kind)
+		}
+		return name, data, nil
+	}
+
+	name, source, err := base()
+	if err != nil {
+		return "", nil, err
+	}
+	data, err = applyGitDelta(source, data)
+	if err != nil {
+		return "", nil, fmt.Errorf("git: %s: %v", pack.path, err)
+	}
+	return name, data, nil
+}
+
+func applyGitDelta(source, delta []byte) ([]byte, error) {
+	size := func() (int, error) {
+		value, shift := 0, uint(0)
+		for {
+			if len(delta) == 0 {
+				return 0, fmt.Errorf("truncated delta")
+			}
+			chr := delta[0]
+			delta = delta[1:]
+			value |= int(chr&0x7f) << shift
+			shift += 7
+			if chr&0x80 == 0 {
+				return value, nil
+			}
+		}
+	}
+
+	sourceSize, err := size()
+	if err != nil {
+		return nil, err
+	}
+	if sourceSize != len(source) {
+		return nil, fmt.Errorf("delta base size mismatch (%d != %d)", sourceSize, len(source))
+	}
+	targetSize, err := size()
+	if err != nil {
+		return nil, err
+	}
+
+	target := make([]byte, 0, targetSize)
+	for len(delta) > 0 {
+		instruction := delta[0]
+		delta = delta[1:]
+		if instruction&0x80 != 0 {
+			// Copy from the source
+			var offset, length int
+			for bit := uint(0); bit < 7; bit++ {
+				if instruction&(1<<bit) == 0 {
+					continue
+				}
+				if len(delta) == 0 {
+					return nil, fmt.Errorf("truncated delta")
+				}
+				if bit < 4 {
+					offset |= int(delta[0]) << (8 * bit)
+				} else {
+					length |= int(delta[0]) << (8 * (bit - 4))
+				}
+				delta = delta[1:]
+			}
+			if length == 0 {
+				length = 0x10000
+			}
+			if offset+length > len(source) {
+				return nil, fmt.Errorf("invalid delta copy")
+			}
+			target = append(target, source[offset:offset+length]...)
+		} else if instruction != 0 {
+			// Insert from the delta
+			length := int(instruction)
+			if length > len(delta) {
+				return nil, fmt.Errorf("truncated delta")
+			}
+			target = append(target, delta[:length]...)
+			delta = delta[length:]
+		} else {
+			return nil, fmt.Errorf("invalid delta instruction")
+		}
+	}
+	if len(target) != targetSize {
+		return nil, fmt.Errorf("delta target size mismatch (%d != %d)", len(target), targetSize)
+	}
+	return target, nil
+}
+
+// githubURL is the native gitGetGitHubRemote
+func (repo *_gitRepository) githubURL() (string, string, string, string, error) {
+	config, err := repo.config()
+	if err != nil {
+		return "", "", "", "", err
+	}
+	for _, name := range []string{"origin", "github"} {
+		if host, owner, repository := parseGitHubURL(config["remote."+name+".url"]); host != "" {
+			return name, host, owner, repository, nil
+		}
+	}
+	return "", "", "", "", nil
+}
+
+// tag is the native gitGetTag (git describe --tags --exact-match)
+func (repo *_gitRepository) tag() (string, error) {
+	head, err := repo.resolve("HEAD")
+	if err != nil {
+		return "", nil // An empty repository (no commits)
+	}
+	head, err = repo.peel(head)
+	if err != nil {
+		return "", err
+	}
+
+	tags, err := repo.tags()
+	if err != nil {
+		return "", err
+	}
+	if tags := tags[head]; len(tags) > 0 {
+		return tags[0], nil
+	}
+	return "", nil
+}
+
+// tags returns the tags of each commit (that has any), an annotated tag before a
+// lightweight one (like git describe), and otherwise by name.
+func (repo *_gitRepository) tags() (map[string][]string, error) {
+	refs, err := repo.refs()
+	if err != nil {
+		return nil, err
+	}
+
+	annotated, lightweight := map[string][]string{}, map[string][]string{}
+	for name, sha := range refs {
+		if !strings.HasPrefix(name, "refs/tags/") {
+			continue
+		}
+		commit, err := repo.peel(sha)
+		if err != nil {
+			continue // A tag of a tree, or a blob
+		}
+		if commit == sha {
+			lightweight[commit] = append(lightweight[commit], strings.TrimPrefix(name, "refs/tags/"))
+		} else {
+			annotated[commit] = append(annotated[commit], strings.TrimPrefix(name, "refs/tags/"))
+		}
+	}
+	tags := map[string][]string{}
+	for _, tmp := range []map[string][]string{annotated, lightweight} {
+		for commit, names := range tmp {
+			sort.Strings(names)
+			tags[commit] = append(tags[commit], names...)
+		}
+	}
+	return tags, nil
+}
+
+// tagCommit is the native gitGetTagCommit (git rev-list <tag>)
+func (repo *_gitRepository) tagCommit(tag string) (string, error) {
+	sha, err := repo.resolve(tag)
+	if err != nil {
+		return "", err
+	}
+	return repo.peel(sha)
+}
+
+// tagObject is the native gitGetTagObject (git rev-parse refs/tags/<tag>)
+func (repo *_gitRepository) tagObject(tag string) (string, error) {
+	refs, err := repo.refs()
+	if err != nil {
+		return "", err
+	}
+	sha, exists := refs["refs/tags/"+tag]
+	if !exists {
+		return "", fmt.Errorf("git: unknown revision: refs/tags/%s", tag)
+	}
+	return sha, nil
+}
+
+// tagMessage is the native gitGetTagMessage: the message of the annotated tag
+// <tag> (without a signature), or "" if the tag is lightweight (or missing).
+func (repo *_gitRepository) tagMessage(tag string) (string, error) {
+	refs, err := repo.refs()
+	if err != nil {
+		return "", err
+	}
+	sha, exists := refs["refs/tags/"+tag]
+	if !exists {
+		return "", nil
+	}
+	kind, data, err := repo.object(sha)
+	if err != nil {
+		return "", err
+	}
+	if kind != "tag" {
+		return "", nil
+	}
+	message := ""
+	if index := bytes.Index(data, []byte("\n\n")); index != -1 {
+		message = string(data[index+2:])
+	}
+	if index := strings.Index(message, "-----BEGIN PGP SIGNATURE-----"); index != -1 {
+		message = message[:index]
+	}
+	return strings.TrimSpace(message), nil
+}
+
+// versions is the native gitGetVersions (git tag --list): the names of the tags.
+func (repo *_gitRepository) versions() ([]string, error) {
+	refs, err := repo.refs()
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	for name := range refs {
+		if strings.HasPrefix(name, "refs/tags/") {
+			tags = append(tags, strings.TrimPrefix(name, "refs/tags/"))
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// _gitCommit is as much of a commit as gphr needs.
+type _gitCommit struct {
+	sha     string
+	parents []string
+	author  time.Time // In the time zone of the author (like --date=short)
+	time    int64     // The time of the committer (which git log orders by)
+	subject string    // The first paragraph of the message, as one line (like %s)
+}
+
+// commit returns the commit <sha>.
+func (repo *_gitRepository) commit(sha string) (*_gitCommit, error) {
+	kind, data, err := repo.object(sha)
+	if err != nil {
+		return nil, err
+	}
+	if kind != "commit" {
+		return nil, fmt.Errorf("git: %s is a %s, not a commit", sha, kind)
+	}
+	commit := &_gitCommit{sha: sha}
+	header, message := string(data), ""
+	if index := strings.Index(header, "\n\n"); index != -1 {
+		header, message = header[:index], header[index+2:]
+	}
+	for _, line := range strings.Split(header, "\n") {
+		switch {
+		case strings.HasPrefix(line, "parent "):
+			commit.parents = append(commit.parents, strings.TrimSpace(line[len("parent "):]))
+		case strings.HasPrefix(line, "author "):
+			seconds, zone := parseGitIdentTime(line)
+			commit.author = time.Unix(seconds, 0).In(zone)
+		case strings.HasPrefix(line, "committer "):
+			commit.time, _ = parseGitIdentTime(line)
+		}
+	}
+	var subject []string
+	for _, line := range strings.Split(strings.TrimLeft(message, "\n"), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			break
+		}
+		subject = append(subject, line)
+	}
+	commit.subject = strings.Join(subject, " ")
+	return commit, nil
+}
+
+// parseGitIdentTime returns the time of an author (or committer) line:
+//
+//	author Alice <alice@example.com> 1400000000 +0200
+func parseGitIdentTime(line string) (int64, *time.Location) {
+	if index := strings.LastIndex(line, ">"); index != -1 {
+		line = line[index+1:]
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 2 || len(fields[1]) != 5 {
+		return 0, time.UTC
+	}
+	seconds, _ := strconv.ParseInt(fields[0], 10, 64)
+	hours, _ := strconv.Atoi(fields[1][1:3])
+	minutes, _ := strconv.Atoi(fields[1][3:5])
+	offset := hours*3600 + minutes*60
+	if fields[1][0] == '-' {
+		offset = -offset
+	}
+	return seconds, time.FixedZone(fields[1], offset)
+}
+
+// walk visits the commits reachable from <from>, but not from <exclude>, newest
+// (by the time of the committer) first, like git log, until <visit> returns
+// false.
+func (repo *_gitRepository) walk(from, exclude []string, visit func(commit *_gitCommit) bool) error {
+	seen := map[string]bool{}
+	if len(exclude) > 0 {
+		err := repo.walk(exclude, nil, func(commit *_gitCommit) bool {
+			seen[commit.sha] = true
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var queue []*_gitCommit
+	push := func(sha string) error {
+		if seen[sha] {
+			return nil
+		}
+		seen[sha] = true
+		commit, err := repo.commit(sha)
+		if err != nil {
+			return err
+		}
+		queue = append(queue, commit)
+		return nil
+	}
+	for _, sha := range from {
+		err := push(sha)
+		if err != nil {
+			return err
+		}
+	}
+	for len(queue) > 0 {
+		newest := 0
+		for index, commit := range queue {
+			if commit.time > queue[newest].time {
+				newest = index
+			}
+		}
+		commit := queue[newest]
+		queue = append(queue[:newest], queue[newest+1:]...)
+		if !visit(commit) {
+			return nil
+		}
+		for _, parent := range commit.parents {
+			err := push(parent)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// subjects is the native gitGetSubjects (git log --format=%s <previous>..<tag>)
+func (repo *_gitRepository) subjects(previous, tag string) ([]string, error) {
+	from, err := repo.tagCommit(tag)
+	if err != nil {
+		return nil, err
+	}
+	var exclude []string
+	if previous != "" {
+		sha, err := repo.tagCommit(previous)
+		if err != nil {
+			return nil, err
+		}
+		exclude = append(exclude, sha)
+	}
+	var subjects []string
+	err = repo.walk([]string{from}, exclude, func(commit *_gitCommit) bool {
+		if commit.subject != "" {
+			subjects = append(subjects, commit.subject)
+		}
+		return true
+	})
+	return subjects, err
+}
+
+// previousTag is the native gitGetPreviousTag (git describe --tags --abbrev=0
+// <tag>^): the tag of the newest commit before <tag> that has one, or "".
+func (repo *_gitRepository) previousTag(tag string) (string, error) {
+	sha, err := repo.tagCommit(tag)
+	if err != nil {
+		return "", err
+	}
+	commit, err := repo.commit(sha)
+	if err != nil {
+		return "", err
+	}
+	tags, err := repo.tags()
+	if err != nil {
+		return "", err
+	}
+	previous := ""
+	err = repo.walk(commit.parents, nil, func(commit *_gitCommit) bool {
+		if tags := tags[commit.sha]; len(tags) > 0 {
+			previous = tags[0]
+			return false
+		}
+		return true
+	})
+	return previous, err
+}
+
+// tagDate is the native gitGetTagDate (git log -1 --format=%ad --date=short <tag>)
+func (repo *_gitRepository) tagDate(tag string) (string, error) {
+	sha, err := repo.tagCommit(tag)
+	if err != nil {
+		return "", err
+	}
+	commit, err := repo.commit(sha)
+	if err != nil {
+		return "", err
+	}
+	return commit.author.Format("2006-01-02"), nil
+}
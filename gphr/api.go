@@ -0,0 +1,148 @@
+package gphr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// _api is a (minimal) JSON API client, for the providers without a client of their own.
+type _api struct {
+	base   *url.URL
+	client *http.Client
+	header http.Header // Added to every request (authentication, ...)
+}
+
+func newAPI(base string, client *http.Client) (*_api, error) {
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	url_, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API URL: %s: %v", base, err)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &_api{
+		base:   url_,
+		client: client,
+		header: http.Header{},
+	}, nil
+}
+
+// An APIError is an unsuccessful (non-2xx) response from an API.
+type APIError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Message    string
+}
+
+func (err *APIError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", err.Method, err.URL, err.StatusCode, err.Message)
+}
+
+// do sends a request to <path> (relative to the base URL), decoding the (JSON)
+// response into <v> (if not nil). <body> is sent as is if it is an io.Reader,
+// otherwise as JSON.
+func (api *_api) do(method, path string, body interface{}, contentType string, v interface{}) (*http.Response, error) {
+	url_, err := api.base.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader
+	switch body := body.(type) {
+	case nil:
+	case io.Reader:
+		reader = body
+	default:
+		content, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = strings.NewReader(string(content))
+		contentType = "application/json"
+	}
+
+	request, err := http.NewRequest(method, url_.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if file, ok := body.(*os.File); ok {
+		if stat, err := file.Stat(); err == nil {
+			request.ContentLength = stat.Size()
+		}
+	}
+	for key, values := range api.header {
+		request.Header[key] = values
+	}
+	request.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+
+	response, err := api.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		content, _ := ioutil.ReadAll(io.LimitReader(response.Body, 4096))
+		message := strings.TrimSpace(string(content))
+		var tmp struct {
+			Message string `json:"message"`
+			Error   string `json:"error"`
+		}
+		if json.Unmarshal(content, &tmp) == nil {
+			if tmp.Message != "" {
+				message = tmp.Message
+			} else if tmp.Error != "" {
+				message = tmp.Error
+			}
+		}
+		return response, &APIError{
+			Method:     method,
+			URL:        url_.String(),
+			StatusCode: response.StatusCode,
+			Message:    message,
+		}
+	}
+
+	if v != nil {
+		err = json.NewDecoder(response.Body).Decode(v)
+		if err != nil && err != io.EOF {
+			return response, err
+		}
+	}
+	return response, nil
+}
+
+func isNotFound(response *http.Response) bool {
+	return response != nil && response.StatusCode == 404
+}
+
+// escapePath escapes every segment of <path> (e.g. Formula/example.rb).
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for index, segment := range segments {
+		segments[index] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// sameContent returns whether <encoded> (base64, as a file of a repository is
+// returned, possibly broken into lines) is <content>.
+func sameContent(encoded string, content []byte) bool {
+	tmp, err := base64.StdEncoding.DecodeString(strings.Replace(encoded, "\n", "", -1))
+	return err == nil && bytes.Equal(tmp, content)
+}
@@ -0,0 +1,228 @@
+package gphr
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An App is a GitHub App, to authenticate as (an installation of) instead of
+// with a (personal access) token.
+//
+// https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app
+type App struct {
+	ID             string // The App ID (or Client ID)
+	InstallationID int    // If 0, the installation for the repository
+	PrivateKey     *rsa.PrivateKey
+}
+
+// ParsePrivateKey parses the (PEM) private key of a GitHub App, as downloaded
+// from GitHub (PKCS #1), or converted to PKCS #8.
+func ParsePrivateKey(input []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(input)
+	if block == nil {
+		return nil, errors.New("invalid private key: not PEM")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %v", err)
+	}
+	tmp, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("invalid private key: not RSA")
+	}
+	return tmp, nil
+}
+
+// JWT returns a JSON Web Token (RS256) for <app>, valid from (a minute before)
+// <now> for 9 minutes (GitHub allows 10, at most).
+func (app *App) JWT(now time.Time) (string, error) {
+	encode := base64.RawURLEncoding.EncodeToString
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(), // Allow for clock drift
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": app.ID,
+	})
+	input := encode(header) + "." + encode(claims)
+
+	hash := sha256.Sum256([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, app.PrivateKey, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return input + "." + encode(signature), nil
+}
+
+// AppTransport is an http.RoundTripper that authenticates every request as the
+// installation of an App, with an installation access token for the repository.
+//
+// The token is fetched when first needed, and fetched again when it is about to
+// expire (an installation token lasts an hour, which a slow upload can outlast),
+// or if a request is unauthorized.
+type AppTransport struct {
+	App        *App
+	APIURL     string // https://api.github.com/
+	Owner      string
+	Repository string
+	Transport  http.RoundTripper // By default, http.DefaultTransport
+
+	mutex   sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// appTokenMargin is how long before it expires that a token is replaced.
+const appTokenMargin = 5 * time.Minute
+
+// NewAppTransport returns an AppTransport for <app> on <owner>/<repository>,
+// using the API at <apiURL> (by default, https://api.github.com/).
+func NewAppTransport(app *App, apiURL, owner, repository string, transport http.RoundTripper) *AppTransport {
+	if apiURL == "" {
+		apiURL, _ = APIURL("github.com")
+	}
+	if !strings.HasSuffix(apiURL, "/") {
+		apiURL += "/"
+	}
+	return &AppTransport{
+		App:        app,
+		APIURL:     apiURL,
+		Owner:      owner,
+		Repository: repository,
+		Transport:  transport,
+	}
+}
+
+func (transport *AppTransport) transport() http.RoundTripper {
+	if transport.Transport == nil {
+		return http.DefaultTransport
+	}
+	return transport.Transport
+}
+
+// Token returns an installation access token (and when it expires), fetching
+// a new one if there is none, it is about to expire, or <refresh> is true.
+func (transport *AppTransport) Token(refresh bool) (string, time.Time, error) {
+	return transport.TokenContext(context.Background(), refresh)
+}
+
+// TokenContext is Token, with <ctx>.
+func (transport *AppTransport) TokenContext(ctx context.Context, refresh bool) (string, time.Time, error) {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+
+	now := time.Now()
+	if !refresh && transport.token != "" && now.Add(appTokenMargin).Before(transport.expires) {
+		return transport.token, transport.expires, nil
+	}
+
+	jwt, err := transport.App.JWT(now)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	installation := transport.App.InstallationID
+	if installation == 0 {
+		var tmp struct {
+			ID int `json:"id"`
+		}
+		err = transport.do(ctx, "GET", "repos/"+url.PathEscape(transport.Owner)+"/"+url.PathEscape(transport.Repository)+"/installation", jwt, nil, &tmp)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("app %s: no installation for %s/%s: %v", transport.App.ID, transport.Owner, transport.Repository, err)
+		}
+		installation = tmp.ID
+	}
+
+	var tmp struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	var body interface{}
+	if transport.Repository != "" {
+		body = map[string][]string{"repositories": {transport.Repository}}
+	}
+	err = transport.do(ctx, "POST", fmt.Sprintf("app/installations/%d/access_tokens", installation), jwt, body, &tmp)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("app %s: installation %d: %v", transport.App.ID, installation, err)
+	}
+	if tmp.Token == "" {
+		return "", time.Time{}, fmt.Errorf("app %s: installation %d: no token", transport.App.ID, installation)
+	}
+
+	transport.token, transport.expires = tmp.Token, tmp.ExpiresAt
+	return transport.token, transport.expires, nil
+}
+
+// do sends a request (authenticated as the App, with <jwt>) to the API.
+func (transport *AppTransport) do(ctx context.Context, method, path, jwt string, body interface{}, v interface{}) error {
+	api, err := newAPI(transport.APIURL, withContext(&http.Client{Transport: transport.transport()}, ctx))
+	if err != nil {
+		return err
+	}
+	api.header.Set("Authorization", "Bearer "+jwt)
+	api.header.Set("Accept", "application/vnd.github+json")
+	_, err = api.do(method, path, body, "", v)
+	return err
+}
+
+func (transport *AppTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	token, _, err := transport.TokenContext(request.Context(), false)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := transport.transport().RoundTrip(transport.authorize(request, token))
+	if err != nil {
+		return nil, err
+	}
+
+	// Revoked (or expired early), try once more with a new token, if the
+	// request can be sent again (it has no body, or one that can be had again,
+	// unlike an upload)
+	if response.StatusCode == 401 && (request.Body == nil || request.GetBody != nil) {
+		token, _, err := transport.TokenContext(request.Context(), true)
+		if err != nil {
+			return response, nil
+		}
+		retry := transport.authorize(request, token)
+		if request.GetBody != nil {
+			retry.Body, err = request.GetBody()
+			if err != nil {
+				return response, nil
+			}
+		}
+		response.Body.Close()
+		return transport.transport().RoundTrip(retry)
+	}
+
+	return response, nil
+}
+
+// authorize returns a copy of <request> (which is not to be modified) with <token>.
+func (transport *AppTransport) authorize(request *http.Request, token string) *http.Request {
+	tmp := new(http.Request)
+	*tmp = *request
+	tmp.Header = make(http.Header, len(request.Header)+1)
+	for key, values := range request.Header {
+		tmp.Header[key] = values
+	}
+	tmp.Header.Set("Authorization", "token "+token)
+	return tmp
+}
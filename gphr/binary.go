@@ -7,9 +7,10 @@ import (
 	"github.com/google/go-github/github"
 )
 
-var MatchBinary = regexp.MustCompile(`^(.*)[_-](darwin|dragonfly|freebsd|linux|netbsd|openbsd|plan9|windows)[_-](386|amd64|arm)(?:\.exe)?$`)
+var MatchBinary = regexp.MustCompile(`^(.*)[_-](darwin|dragonfly|freebsd|linux|netbsd|openbsd|plan9|windows)[_-](386|amd64|arm64|arm)(?:\.exe)?$`)
 
 // darwin/386
+// darwin/arm64
 // dragonfly/386
 // dragonfly/amd64
 // freebsd/386
@@ -18,6 +19,7 @@ var MatchBinary = regexp.MustCompile(`^(.*)[_-](darwin|dragonfly|freebsd|linux|n
 // linux/386
 // linux/amd64
 // linux/arm
+// linux/arm64
 // netbsd/386
 // netbsd/amd64
 // netbsd/arm
@@ -26,6 +28,7 @@ var MatchBinary = regexp.MustCompile(`^(.*)[_-](darwin|dragonfly|freebsd|linux|n
 // plan9/386
 // plan9/amd64
 // windows/386
+// windows/arm64
 
 type Binary struct {
 	Path    string              // ../../example/example_linux_386
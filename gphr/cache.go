@@ -0,0 +1,215 @@
+package gphr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiskCache is an httpcache.Cache kept in a directory (one file per response),
+// so that it outlasts the process. Responses are stored with their ETag, so
+// a cached response can be revalidated (If-None-Match) instead of fetched
+// again, and a 304 does not count against the rate limit of GitHub.
+//
+// When the cache grows beyond MaxSize, the least recently used responses are
+// removed (down to 3/4 of MaxSize).
+type DiskCache struct {
+	Dir     string
+	MaxSize int64 // In bytes (if 0, there is no limit)
+
+	mutex sync.Mutex
+	size  int64 // -1 if unknown (not yet counted)
+}
+
+// DefaultCacheSize is the MaxSize of a DiskCache, unless otherwise given.
+const DefaultCacheSize = 64 << 20
+
+// CacheDirectory returns the (default) directory of the cache: gphr in the
+// user cache directory ($XDG_CACHE_HOME or ~/.cache, ~/Library/Caches, or %LocalAppData%).
+func CacheDirectory() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gphr"), nil
+}
+
+// NewDiskCache returns a cache in <dir> (created when first needed), of at
+// most <maxSize> bytes.
+func NewDiskCache(dir string, maxSize int64) *DiskCache {
+	return &DiskCache{
+		Dir:     dir,
+		MaxSize: maxSize,
+		size:    -1,
+	}
+}
+
+func (cache *DiskCache) path(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(cache.Dir, hex.EncodeToString(hash[:]))
+}
+
+func (cache *DiskCache) Get(key string) ([]byte, bool) {
+	path := cache.path(key)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now) // Recently used
+	return content, true
+}
+
+func (cache *DiskCache) Set(key string, content []byte) {
+	if cache.MaxSize > 0 && int64(len(content)) > cache.MaxSize/4 {
+		cache.Delete(key) // Too big to keep (and what is cached is now stale)
+		return
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	err := os.MkdirAll(cache.Dir, 0700)
+	if err != nil {
+		return
+	}
+	path := cache.path(key)
+	before := int64(0)
+	if stat, err := os.Stat(path); err == nil {
+		before = stat.Size()
+	}
+
+	// Write to a temporary file first, so that a response is never half-written
+	file, err := ioutil.TempFile(cache.Dir, ".tmp-")
+	if err != nil {
+		return
+	}
+	_, err = file.Write(content)
+	if tmp := file.Close(); err == nil {
+		err = tmp
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return
+	}
+
+	if cache.size >= 0 {
+		cache.size += int64(len(content)) - before
+	}
+	cache.prune()
+}
+
+func (cache *DiskCache) Delete(key string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	path := cache.path(key)
+	stat, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if os.Remove(path) == nil && cache.size >= 0 {
+		cache.size -= stat.Size()
+	}
+}
+
+// entries returns every (cached) response in the cache, least recently used first.
+func (cache *DiskCache) entries() ([]os.FileInfo, error) {
+	infos, err := ioutil.ReadDir(cache.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	entries := infos[:0]
+	for _, info := range infos {
+		if info.Mode().IsRegular() && !strings.HasPrefix(info.Name(), ".") {
+			entries = append(entries, info)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime().Before(entries[j].ModTime())
+	})
+	return entries, nil
+}
+
+// prune removes the least recently used responses, if the cache is too big.
+func (cache *DiskCache) prune() {
+	if cache.MaxSize <= 0 || (cache.size >= 0 && cache.size <= cache.MaxSize) {
+		return
+	}
+	entries, err := cache.entries()
+	if err != nil {
+		return
+	}
+	size := int64(0)
+	for _, entry := range entries {
+		size += entry.Size()
+	}
+	for _, entry := range entries {
+		if size <= cache.MaxSize*3/4 {
+			break
+		}
+		if os.Remove(filepath.Join(cache.Dir, entry.Name())) == nil {
+			size -= entry.Size()
+		}
+	}
+	cache.size = size
+}
+
+// Size returns the number of responses in the cache, and their size (in bytes).
+func (cache *DiskCache) Size() (int, int64, error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	entries, err := cache.entries()
+	if err != nil {
+		return 0, 0, err
+	}
+	size := int64(0)
+	for _, entry := range entries {
+		size += entry.Size()
+	}
+	return len(entries), size, nil
+}
+
+// Clean removes every response from the cache, returning how many were
+// removed, and their size (in bytes).
+func (cache *DiskCache) Clean() (int, int64, error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	infos, err := ioutil.ReadDir(cache.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	count, size := 0, int64(0)
+	for _, info := range infos {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		err := os.Remove(filepath.Join(cache.Dir, info.Name()))
+		if err != nil {
+			return count, size, err
+		}
+		if !strings.HasPrefix(info.Name(), ".") {
+			count++
+			size += info.Size()
+		}
+	}
+	cache.size = 0
+	return count, size, nil
+}
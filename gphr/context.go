@@ -0,0 +1,102 @@
+package gphr
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// _contextTransport makes every request (that has no context of its own) with ctx.
+type _contextTransport struct {
+	ctx       context.Context
+	transport http.RoundTripper
+}
+
+func (transport _contextTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Context() == context.Background() {
+		request = request.WithContext(transport.ctx)
+	}
+	return transport.transport.RoundTrip(request)
+}
+
+// withContext returns a copy of <client> that makes every request with <ctx>.
+func withContext(client *http.Client, ctx context.Context) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	tmp := *client
+	transport := tmp.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if inner, ok := transport.(_contextTransport); ok {
+		transport = inner.transport
+	}
+	tmp.Transport = _contextTransport{ctx: ctx, transport: transport}
+	return &tmp
+}
+
+// _contextReader is <reader> until <ctx> is done, and the error of <ctx> after.
+type _contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (reader _contextReader) Read(p []byte) (int, error) {
+	if err := reader.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return reader.reader.Read(p)
+}
+
+// sleep waits for <wait>, or until <ctx> is done (returning its error).
+var sleep = func(ctx context.Context, wait time.Duration) error {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (gh *GitHub) WithContext(ctx context.Context) Provider {
+	tmp := *gh
+	tmp.client = withContext(gh.client, ctx)
+	tmp.Client = github.NewClient(tmp.client)
+	tmp.Client.BaseURL = gh.Client.BaseURL
+	tmp.Client.UploadURL = gh.Client.UploadURL
+	tmp.Client.UserAgent = gh.Client.UserAgent
+	return &tmp
+}
+
+func (gl *GitLab) WithContext(ctx context.Context) Provider {
+	tmp := *gl
+	api := *gl.api
+	api.client = withContext(api.client, ctx)
+	tmp.api = &api
+	return &tmp
+}
+
+func (gt *Gitea) WithContext(ctx context.Context) Provider {
+	tmp := *gt
+	api := *gt.api
+	api.client = withContext(api.client, ctx)
+	tmp.api = &api
+	return &tmp
+}
+
+func (st *Store) WithContext(ctx context.Context) Provider {
+	tmp := *st
+	tmp.ctx = ctx
+	if s3, ok := st.store.(*_s3); ok {
+		s3_ := *s3
+		s3_.client = withContext(s3.client, ctx)
+		tmp.store = &s3_
+	}
+	return &tmp
+}
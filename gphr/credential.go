@@ -0,0 +1,342 @@
+package gphr
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// A Credential is a token (for a host), and where it came from.
+type Credential struct {
+	Token  string
+	Source string // -token, GITHUB_TOKEN, git credential fill, ~/.netrc, ...
+}
+
+// A CredentialSource is somewhere a token can come from.
+type CredentialSource interface {
+	// Credential returns the credential for <host>, or nil if there is none.
+	Credential(host string) (*Credential, error)
+}
+
+// GetCredential returns the credential of the first of <sources> to have one
+// for <host>, or nil if none of them do.
+func GetCredential(host string, sources ...CredentialSource) (*Credential, error) {
+	for _, source := range sources {
+		credential, err := source.Credential(host)
+		if err != nil {
+			return nil, err
+		}
+		if credential != nil && credential.Token != "" {
+			return credential, nil
+		}
+	}
+	return nil, nil
+}
+
+// CredentialSources returns the usual sources of a token for the <kind> of
+// provider at <host>, in order:
+//
+//     The environment (GH_TOKEN/GITHUB_TOKEN, GH_ENTERPRISE_TOKEN/GITHUB_ENTERPRISE_TOKEN, GITLAB_TOKEN, or GITEA_TOKEN)
+//     git credential fill
+//     ~/.netrc
+//     The hosts.yml of the gh CLI (GitHub only)
+func CredentialSources(kind, host string) []CredentialSource {
+	var names []string
+	switch kind {
+	case ProviderGitHub:
+		if host == "" || host == "github.com" {
+			names = []string{"GH_TOKEN", "GITHUB_TOKEN"}
+		} else {
+			names = []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}
+		}
+	case ProviderGitLab:
+		names = []string{"GITLAB_TOKEN"}
+	case ProviderGitea:
+		names = []string{"GITEA_TOKEN"}
+	}
+
+	sources := []CredentialSource{
+		&EnvironmentCredential{Names: names},
+		&GitCredential{},
+		&NetrcCredential{},
+	}
+	if kind == ProviderGitHub {
+		sources = append(sources, &GHCredential{})
+	}
+	return sources
+}
+
+// TokenCredential is a token given explicitly (e.g. with -token). If the token
+// starts with a "!", then the rest is a command (run with /bin/sh -c, or cmd /C
+// on Windows) that outputs the token instead.
+type TokenCredential struct {
+	Token  string
+	Source string
+}
+
+func (source *TokenCredential) Credential(host string) (*Credential, error) {
+	token := source.Token
+	if token == "" {
+		return nil, nil
+	}
+	if token[0] == '!' {
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("cmd", "/C", token[1:])
+		} else {
+			cmd = exec.Command("/bin/sh", "-c", token[1:])
+		}
+		cmd.Stderr = os.Stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", source.Source, token[1:], err)
+		}
+		token = strings.TrimSpace(string(output))
+	}
+	return &Credential{Token: token, Source: source.Source}, nil
+}
+
+// EnvironmentCredential is the first of the environment variables <Names> that is set.
+type EnvironmentCredential struct {
+	Names  []string
+	Getenv func(string) string // By default, os.Getenv
+}
+
+func (source *EnvironmentCredential) Credential(host string) (*Credential, error) {
+	getenv := source.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	for _, name := range source.Names {
+		if token := getenv(name); token != "" {
+			return &Credential{Token: token, Source: name}, nil
+		}
+	}
+	return nil, nil
+}
+
+// GitCredential is the password that git (git credential fill) has for https://<host>,
+// from a credential helper (osxkeychain, manager, store, ...). git is never
+// allowed to prompt for one.
+type GitCredential struct {
+	Dir string   // Where to run git (by default, the current directory)
+	Env []string // Added to the environment of git
+}
+
+func (source *GitCredential) Credential(host string) (*Credential, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, nil
+	}
+	cmd := exec.Command("git", "-c", "core.askPass=", "credential", "fill")
+	cmd.Dir = source.Dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	cmd.Env = append(cmd.Env, source.Env...)
+	cmd.Stdin = strings.NewReader("protocol=https\nhost=" + host + "\n\n")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, nil // No helper (or no credential), and no prompting
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "password=") {
+			token := strings.TrimSpace(strings.TrimPrefix(line, "password="))
+			if token == "" {
+				break
+			}
+			return &Credential{Token: token, Source: "git credential fill"}, nil
+		}
+	}
+	return nil, nil
+}
+
+// NetrcCredential is the password for <host> (or the default) in a .netrc.
+type NetrcCredential struct {
+	Path string // By default, $NETRC, or ~/.netrc (~/_netrc on Windows)
+}
+
+func (source *NetrcCredential) Credential(host string) (*Credential, error) {
+	path := source.Path
+	if path == "" {
+		path = os.Getenv("NETRC")
+	}
+	if path == "" {
+		home := homeDirectory()
+		if home == "" {
+			return nil, nil
+		}
+		path = filepath.Join(home, ".netrc")
+		if runtime.GOOS == "windows" {
+			path = filepath.Join(home, "_netrc")
+		}
+	}
+	input, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	token := parseNetrc(input, host)
+	if token == "" {
+		return nil, nil
+	}
+	return &Credential{Token: token, Source: path}, nil
+}
+
+// parseNetrc returns the password for <host> (with or without a port) in the .netrc <input>.
+func parseNetrc(input []byte, host string) string {
+	hostname := host
+	if index := strings.LastIndex(host, ":"); index != -1 {
+		hostname = host[:index]
+	}
+
+	var machine, password, fallback string
+	inDefault := false
+	done := func() bool {
+		if machine == host || machine == hostname {
+			return password != ""
+		}
+		return false
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(input))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == "macdef" {
+			// A macro, up to the next empty line
+			for scanner.Scan() && strings.TrimSpace(scanner.Text()) != "" {
+			}
+			continue
+		}
+		for index := 0; index < len(fields); index++ {
+			field := fields[index]
+			if strings.HasPrefix(field, "#") {
+				break
+			}
+			switch field {
+			case "machine":
+				if done() {
+					return password
+				}
+				machine, password, inDefault = "", "", false
+				if index+1 < len(fields) {
+					index++
+					machine = fields[index]
+				}
+			case "default":
+				if done() {
+					return password
+				}
+				machine, password, inDefault = "", "", true
+			case "password":
+				if index+1 < len(fields) {
+					index++
+					password = fields[index]
+					if inDefault {
+						fallback = password
+					}
+				}
+			case "login", "account":
+				index++
+			}
+		}
+	}
+	if done() {
+		return password
+	}
+	return fallback
+}
+
+// GHCredential is the oauth_token for <host> in the hosts.yml of the gh CLI
+// (https://cli.github.com/). A token kept by gh in the system keyring is not
+// in hosts.yml, but git credential fill can get to it if gh is the credential
+// helper (gh auth setup-git).
+type GHCredential struct {
+	Path string // By default, $GH_CONFIG_DIR/hosts.yml, $XDG_CONFIG_HOME/gh/hosts.yml, or ~/.config/gh/hosts.yml
+}
+
+func (source *GHCredential) Credential(host string) (*Credential, error) {
+	path := source.Path
+	if path == "" {
+		switch {
+		case os.Getenv("GH_CONFIG_DIR") != "":
+			path = filepath.Join(os.Getenv("GH_CONFIG_DIR"), "hosts.yml")
+		case os.Getenv("XDG_CONFIG_HOME") != "":
+			path = filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "gh", "hosts.yml")
+		case runtime.GOOS == "windows" && os.Getenv("AppData") != "":
+			path = filepath.Join(os.Getenv("AppData"), "GitHub CLI", "hosts.yml")
+		default:
+			home := homeDirectory()
+			if home == "" {
+				return nil, nil
+			}
+			path = filepath.Join(home, ".config", "gh", "hosts.yml")
+		}
+	}
+	input, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	token := parseGHHosts(input, host)
+	if token == "" {
+		return nil, nil
+	}
+	return &Credential{Token: token, Source: path}, nil
+}
+
+// parseGHHosts returns the oauth_token for <host> in the (YAML) hosts.yml <input>:
+//
+//     github.com:
+//         user: alice
+//         oauth_token: gho_...
+//         users:
+//             alice:
+//                 oauth_token: gho_...
+//
+// The oauth_token closest to the host (the active user) wins.
+func parseGHHosts(input []byte, host string) string {
+	inHost := false
+	token, depth := "", -1
+	for _, line := range strings.Split(string(input), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(trimmed)
+		if indent == 0 {
+			key := strings.TrimSuffix(trimmed, ":")
+			key = strings.Trim(key, `"'`)
+			inHost = key == host
+			continue
+		}
+		if !inHost || !strings.HasPrefix(trimmed, "oauth_token:") {
+			continue
+		}
+		value := strings.TrimSpace(strings.TrimPrefix(trimmed, "oauth_token:"))
+		value = strings.Trim(value, `"'`)
+		if value != "" && (depth == -1 || indent < depth) {
+			token, depth = value, indent
+		}
+	}
+	return token
+}
+
+func homeDirectory() string {
+	if runtime.GOOS == "windows" {
+		if home := os.Getenv("USERPROFILE"); home != "" {
+			return home
+		}
+		return os.Getenv("HOMEDRIVE") + os.Getenv("HOMEPATH")
+	}
+	return os.Getenv("HOME")
+}
@@ -0,0 +1,104 @@
+package gphr
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/go-github/github"
+)
+
+// The kinds of error (see Kind) that a caller may want to act on, e.g. to skip
+// a release that is already done.
+var (
+	ErrNotTagged       = errors.New("not tagged")
+	ErrTagNotFound     = errors.New("tag not found")
+	ErrTagMismatch     = errors.New("tag mismatch")
+	ErrAssetExists     = errors.New("asset already exists")
+	ErrAuth            = errors.New("unauthorized")
+	ErrRateLimited     = errors.New("rate limited")
+	ErrNoMatchingAsset = errors.New("no matching asset")
+	ErrIncomplete      = errors.New("incomplete")
+)
+
+// An Error is an error of a Kind (ErrTagNotFound, ...), with a message of its
+// own. errors.Is(err, Kind) is true, as it is for Err (if any).
+type Error struct {
+	Kind    error
+	Message string
+	Err     error // The underlying error, if any
+}
+
+// Errorf returns an Error of <kind>, with a message formatted as by fmt.Sprintf.
+func Errorf(kind error, format string, arguments ...interface{}) *Error {
+	return &Error{
+		Kind:    kind,
+		Message: fmt.Sprintf(format, arguments...),
+	}
+}
+
+func (err *Error) Error() string {
+	if err.Message == "" && err.Err != nil {
+		return err.Err.Error()
+	}
+	return err.Message
+}
+
+func (err *Error) Is(target error) bool {
+	return target == err.Kind
+}
+
+func (err *Error) Unwrap() error {
+	return err.Err
+}
+
+func (err *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+func (err *APIError) Is(target error) bool {
+	switch target {
+	case ErrAuth:
+		return err.StatusCode == 401
+	case ErrRateLimited:
+		return err.StatusCode == 429
+	}
+	return false
+}
+
+// Kind returns the kind of <err> (ErrTagNotFound, ...), or nil if it is of
+// none. A 401 (or a 429) from any provider is ErrAuth (or ErrRateLimited), and
+// a 422 from GitHub for an asset that already_exists is ErrAssetExists.
+func Kind(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, kind := range []error{
+		ErrNotTagged, ErrTagNotFound, ErrTagMismatch, ErrAssetExists,
+		ErrAuth, ErrRateLimited, ErrNoMatchingAsset, ErrIncomplete,
+	} {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+
+	var response *github.ErrorResponse
+	if errors.As(err, &response) && response.Response != nil {
+		switch response.Response.StatusCode {
+		case 401:
+			return ErrAuth
+		case 429:
+			return ErrRateLimited
+		case 403:
+			if response.Response.Header.Get("X-RateLimit-Remaining") == "0" {
+				return ErrRateLimited
+			}
+		case 422:
+			for _, tmp := range response.Errors {
+				if tmp.Resource == "ReleaseAsset" && tmp.Code == "already_exists" {
+					return ErrAssetExists
+				}
+			}
+		}
+	}
+	return nil
+}
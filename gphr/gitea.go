@@ -0,0 +1,301 @@
+package gphr
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// Gitea keeps releases in Gitea (or Forgejo, e.g. codeberg.org) releases, which
+// are modeled after GitHub releases.
+//
+// https://try.gitea.io/api/swagger
+type Gitea struct {
+	Host       string // codeberg.org
+	Owner      string
+	Repository string
+	api        *_api
+}
+
+// NewGitea returns a Gitea provider for <owner>/<repository> at <host>, using
+// the API at <apiURL> (by default, https://<host>/api/v1/).
+func NewGitea(host, apiURL, owner, repository string, client *http.Client, token string) (*Gitea, error) {
+	if apiURL == "" {
+		apiURL = "https://" + host + "/api/v1/"
+	}
+	api, err := newAPI(apiURL, client)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		api.header.Set("Authorization", "token "+token)
+	}
+	return &Gitea{
+		Host:       host,
+		Owner:      owner,
+		Repository: repository,
+		api:        api,
+	}, nil
+}
+
+func (gt *Gitea) Location() string {
+	return fmt.Sprintf("%s/%s/%s", gt.Host, gt.Owner, gt.Repository)
+}
+
+func (gt *Gitea) repository() string {
+	return "repos/" + url.PathEscape(gt.Owner) + "/" + url.PathEscape(gt.Repository) + "/"
+}
+
+type _giteaRelease struct {
+	ID              int           `json:"id"`
+	TagName         string        `json:"tag_name"`
+	TargetCommitish string        `json:"target_commitish"`
+	Name            string        `json:"name"`
+	Body            string        `json:"body"`
+	Draft           bool          `json:"draft"`
+	Prerelease      bool          `json:"prerelease"`
+	CreatedAt       *time.Time    `json:"created_at,omitempty"`
+	PublishedAt     *time.Time    `json:"published_at,omitempty"`
+	HTMLURL         string        `json:"html_url"`
+	Assets          []_giteaAsset `json:"assets"`
+}
+
+type _giteaAsset struct {
+	ID                 int        `json:"id"`
+	Name               string     `json:"name"`
+	Size               int        `json:"size"`
+	DownloadCount      int        `json:"download_count"`
+	CreatedAt          *time.Time `json:"created_at,omitempty"`
+	BrowserDownloadURL string     `json:"browser_download_url"`
+}
+
+func (asset _giteaAsset) asset() github.ReleaseAsset {
+	tmp := github.ReleaseAsset{
+		ID:            github.Int(asset.ID),
+		Name:          github.String(asset.Name),
+		URL:           github.String(asset.BrowserDownloadURL),
+		Size:          github.Int(asset.Size),
+		DownloadCount: github.Int(asset.DownloadCount),
+	}
+	if asset.CreatedAt != nil {
+		tmp.CreatedAt = &github.Timestamp{Time: *asset.CreatedAt}
+	}
+	return tmp
+}
+
+func (release _giteaRelease) release() *Release {
+	tmp := &Release{}
+	tmp.ID = github.Int(release.ID)
+	tmp.TagName = github.String(release.TagName)
+	tmp.TargetCommitish = github.String(release.TargetCommitish)
+	tmp.Name = github.String(release.Name)
+	tmp.Body = github.String(release.Body)
+	tmp.Draft = github.Bool(release.Draft)
+	tmp.Prerelease = github.Bool(release.Prerelease)
+	tmp.HTMLURL = github.String(release.HTMLURL)
+	if release.CreatedAt != nil {
+		tmp.CreatedAt = &github.Timestamp{Time: *release.CreatedAt}
+	}
+	if release.PublishedAt != nil {
+		tmp.PublishedAt = &github.Timestamp{Time: *release.PublishedAt}
+	}
+	for _, asset := range release.Assets {
+		tmp.Assets = append(tmp.Assets, asset.asset())
+	}
+	return tmp
+}
+
+func (gt *Gitea) GetReleases() ([]*Release, error) {
+	const limit = 50
+
+	var releases []*Release
+	for page := 1; ; page++ {
+		var tmp []_giteaRelease
+		response, err := gt.api.do("GET", gt.repository()+"releases?limit="+strconv.Itoa(limit)+"&page="+strconv.Itoa(page), nil, "", &tmp)
+		if err != nil {
+			if isNotFound(response) && page == 1 {
+				return nil, nil
+			}
+			return nil, err
+		}
+		for _, release := range tmp {
+			releases = append(releases, release.release())
+		}
+		if len(tmp) < limit {
+			break
+		}
+	}
+
+	sort.Sort(sort.Reverse(_sortReleaseByTime(releases)))
+
+	return releases, nil
+}
+
+// GetRepositories returns the repositories of the owner (an organization, or
+// else a user).
+func (gt *Gitea) GetRepositories() ([]string, error) {
+	const limit = 50
+
+	var repositories []string
+	base, organization := "orgs/"+url.PathEscape(gt.Owner)+"/repos", true
+	for page := 1; ; page++ {
+		var tmp []struct {
+			Name string `json:"name"`
+		}
+		response, err := gt.api.do("GET", base+"?limit="+strconv.Itoa(limit)+"&page="+strconv.Itoa(page), nil, "", &tmp)
+		if err != nil {
+			if isNotFound(response) && page == 1 && organization {
+				base, organization = "users/"+url.PathEscape(gt.Owner)+"/repos", false // Not an organization
+				page = 0
+				continue
+			}
+			return nil, err
+		}
+		for _, repository := range tmp {
+			repositories = append(repositories, repository.Name)
+		}
+		if len(tmp) < limit {
+			break
+		}
+	}
+	return repositories, nil
+}
+
+func (gt *Gitea) CreateRelease(release *Release) error {
+	var tmp _giteaRelease
+	_, err := gt.api.do("POST", gt.repository()+"releases", map[string]string{
+		"tag_name": stringValue(release.TagName),
+		"name":     stringValue(release.Name),
+		"body":     stringValue(release.Body),
+	}, "", &tmp)
+	if err != nil {
+		return err
+	}
+	*release = *tmp.release()
+	return nil
+}
+
+func (gt *Gitea) EditRelease(release *Release) error {
+	_, err := gt.api.do("PATCH", gt.repository()+"releases/"+strconv.Itoa(intValue(release.ID)), map[string]string{
+		"name": stringValue(release.Name),
+		"body": stringValue(release.Body),
+	}, "", nil)
+	return err
+}
+
+func (gt *Gitea) GetReleaseAssets(release github.RepositoryRelease) ([]github.ReleaseAsset, error) {
+	var tmp []_giteaAsset
+	_, err := gt.api.do("GET", gt.repository()+"releases/"+strconv.Itoa(intValue(release.ID))+"/assets", nil, "", &tmp)
+	if err != nil {
+		return nil, err
+	}
+	var assets []github.ReleaseAsset
+	for _, asset := range tmp {
+		assets = append(assets, asset.asset())
+	}
+	return assets, nil
+}
+
+func (gt *Gitea) UploadAsset(release *Release, name string, file *os.File) (*github.ReleaseAsset, error) {
+	// multipart/form-data, streamed (binaries are not small)
+	reader, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		part, err := form.CreateFormFile("attachment", filepath.Base(name))
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	var tmp _giteaAsset
+	path := gt.repository() + "releases/" + strconv.Itoa(intValue(release.ID)) + "/assets?name=" + url.QueryEscape(name)
+	_, err := gt.api.do("POST", path, reader, form.FormDataContentType(), &tmp)
+	reader.Close()
+	if err != nil {
+		return nil, err
+	}
+	asset := tmp.asset()
+	return &asset, nil
+}
+
+func (gt *Gitea) DeleteAsset(release *Release, asset github.ReleaseAsset) error {
+	path := gt.repository() + "releases/" + strconv.Itoa(intValue(release.ID)) + "/assets/" + strconv.Itoa(intValue(asset.ID))
+	response, err := gt.api.do("DELETE", path, nil, "", nil)
+	if err != nil && !isNotFound(response) {
+		return err
+	}
+	return nil
+}
+
+func (gt *Gitea) ResolveTag(tag string) (string, error) {
+	var tmp struct {
+		Commit struct {
+			SHA string `json:"sha"`
+		} `json:"commit"`
+	}
+	response, err := gt.api.do("GET", gt.repository()+"tags/"+url.PathEscape(tag), nil, "", &tmp)
+	if err != nil {
+		if isNotFound(response) {
+			return "", Errorf(ErrTagNotFound, "%s: tag %q does not exist", gt.Location(), tag)
+		}
+		return "", err
+	}
+	return tmp.Commit.SHA, nil
+}
+
+// WriteFile commits <content> to <path> (with the contents API).
+func (gt *Gitea) WriteFile(path string, content []byte, message string) (bool, error) {
+	contents := gt.repository() + "contents/" + escapePath(path)
+	var file struct {
+		SHA     string `json:"sha"`
+		Content string `json:"content"`
+	}
+	body := map[string]string{
+		"message": message,
+		"content": base64.StdEncoding.EncodeToString(content),
+	}
+	method := "PUT" // Updating the file
+	response, err := gt.api.do("GET", contents, nil, "", &file)
+	if err != nil {
+		if !isNotFound(response) {
+			return false, err
+		}
+		method = "POST"
+	} else if sameContent(file.Content, content) {
+		return false, nil
+	} else {
+		body["sha"] = file.SHA
+	}
+
+	_, err = gt.api.do(method, contents, body, "", nil)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (gt *Gitea) DownloadURL(tag, name string) string {
+	return "https://" + gt.Location() + "/releases/download/" + tag + "/" + name
+}
+
+func (gt *Gitea) ReleaseURL(tag string) string {
+	return "https://" + gt.Location() + "/releases/tag/" + tag
+}
+
+func (gt *Gitea) CompareURL(base, head string) string {
+	return "https://" + gt.Location() + "/compare/" + base + "..." + head
+}
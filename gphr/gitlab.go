@@ -0,0 +1,376 @@
+package gphr
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// GitLab keeps releases in GitLab Releases, with each asset uploaded to the
+// Generic Package Registry (<repository>/<tag>/<name>) and linked from the release.
+//
+// https://docs.gitlab.com/ee/api/releases/
+// https://docs.gitlab.com/ee/user/packages/generic_packages/
+type GitLab struct {
+	Host       string // gitlab.com
+	Owner      string // alice (or a group/subgroup)
+	Repository string // example
+	api        *_api
+}
+
+// NewGitLab returns a GitLab provider for <owner>/<repository> at <host>, using
+// the API at <apiURL> (by default, https://<host>/api/v4/).
+func NewGitLab(host, apiURL, owner, repository string, client *http.Client, token string) (*GitLab, error) {
+	if apiURL == "" {
+		apiURL = "https://" + host + "/api/v4/"
+	}
+	api, err := newAPI(apiURL, client)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		api.header.Set("Private-Token", token)
+	}
+	return &GitLab{
+		Host:       host,
+		Owner:      owner,
+		Repository: repository,
+		api:        api,
+	}, nil
+}
+
+func (gl *GitLab) Location() string {
+	return fmt.Sprintf("%s/%s/%s", gl.Host, gl.Owner, gl.Repository)
+}
+
+// project returns the (URL-encoded) path of the project, e.g. "projects/alice%2Fexample/"
+func (gl *GitLab) project() string {
+	return "projects/" + url.PathEscape(gl.Owner+"/"+gl.Repository) + "/"
+}
+
+type _gitlabRelease struct {
+	TagName     string     `json:"tag_name"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	ReleasedAt  *time.Time `json:"released_at,omitempty"`
+	Commit      struct {
+		ID string `json:"id"`
+	} `json:"commit"`
+	Assets struct {
+		Links []_gitlabLink `json:"links"`
+	} `json:"assets"`
+}
+
+type _gitlabLink struct {
+	ID             int    `json:"id"`
+	Name           string `json:"name"`
+	URL            string `json:"url"`
+	DirectAssetURL string `json:"direct_asset_url"`
+	LinkType       string `json:"link_type"`
+}
+
+func (link _gitlabLink) asset() github.ReleaseAsset {
+	url_ := link.DirectAssetURL
+	if url_ == "" {
+		url_ = link.URL
+	}
+	return github.ReleaseAsset{
+		ID:   github.Int(link.ID),
+		Name: github.String(link.Name),
+		URL:  github.String(url_),
+	}
+}
+
+func (release _gitlabRelease) release() *Release {
+	tmp := &Release{}
+	tmp.TagName = github.String(release.TagName)
+	tmp.Name = github.String(release.Name)
+	tmp.Body = github.String(release.Description)
+	tmp.TargetCommitish = github.String(release.Commit.ID)
+	if release.CreatedAt != nil {
+		tmp.CreatedAt = &github.Timestamp{Time: *release.CreatedAt}
+	}
+	if release.ReleasedAt != nil {
+		tmp.PublishedAt = &github.Timestamp{Time: *release.ReleasedAt}
+	}
+	for _, link := range release.Assets.Links {
+		tmp.Assets = append(tmp.Assets, link.asset())
+	}
+	return tmp
+}
+
+func (gl *GitLab) GetReleases() ([]*Release, error) {
+	var releases []*Release
+	for page := 1; page > 0; {
+		var tmp []_gitlabRelease
+		response, err := gl.api.do("GET", gl.project()+"releases?per_page=100&page="+strconv.Itoa(page), nil, "", &tmp)
+		if err != nil {
+			if isNotFound(response) && page == 1 {
+				return nil, nil
+			}
+			return nil, err
+		}
+		for _, release := range tmp {
+			releases = append(releases, release.release())
+		}
+		page, _ = strconv.Atoi(response.Header.Get("X-Next-Page"))
+	}
+
+	sort.Sort(sort.Reverse(_sortReleaseByTime(releases)))
+
+	return releases, nil
+}
+
+// GetRepositories returns the projects of the owner (a group, or else a user).
+func (gl *GitLab) GetRepositories() ([]string, error) {
+	var repositories []string
+	base, group := "groups/"+url.PathEscape(gl.Owner)+"/projects", true
+	for page := 1; page > 0; {
+		var tmp []struct {
+			Path string `json:"path"`
+		}
+		response, err := gl.api.do("GET", base+"?per_page=100&page="+strconv.Itoa(page), nil, "", &tmp)
+		if err != nil {
+			if isNotFound(response) && page == 1 && group {
+				base, group = "users/"+url.PathEscape(gl.Owner)+"/projects", false // Not a group
+				continue
+			}
+			return nil, err
+		}
+		for _, project := range tmp {
+			repositories = append(repositories, project.Path)
+		}
+		page, _ = strconv.Atoi(response.Header.Get("X-Next-Page"))
+	}
+	return repositories, nil
+}
+
+func (gl *GitLab) CreateRelease(release *Release) error {
+	var tmp _gitlabRelease
+	_, err := gl.api.do("POST", gl.project()+"releases", map[string]string{
+		"tag_name":    stringValue(release.TagName),
+		"name":        stringValue(release.Name),
+		"description": stringValue(release.Body),
+	}, "", &tmp)
+	if err != nil {
+		return err
+	}
+	*release = *tmp.release()
+	return nil
+}
+
+func (gl *GitLab) EditRelease(release *Release) error {
+	_, err := gl.api.do("PUT", gl.project()+"releases/"+url.PathEscape(stringValue(release.TagName)), map[string]string{
+		"name":        stringValue(release.Name),
+		"description": stringValue(release.Body),
+	}, "", nil)
+	return err
+}
+
+func (gl *GitLab) GetReleaseAssets(release github.RepositoryRelease) ([]github.ReleaseAsset, error) {
+	var assets []github.ReleaseAsset
+	for page := 1; page > 0; {
+		var links []_gitlabLink
+		response, err := gl.api.do("GET", gl.project()+"releases/"+url.PathEscape(stringValue(release.TagName))+"/assets/links?per_page=100&page="+strconv.Itoa(page), nil, "", &links)
+		if err != nil {
+			return nil, err
+		}
+		for _, link := range links {
+			assets = append(assets, link.asset())
+		}
+		page, _ = strconv.Atoi(response.Header.Get("X-Next-Page"))
+	}
+	return assets, nil
+}
+
+// packageURL returns the (API) URL of asset <name> in the Generic Package Registry.
+func (gl *GitLab) packageURL(tag, name string) string {
+	url_, _ := gl.api.base.Parse(gl.project() + "packages/generic/" + url.PathEscape(gl.Repository) + "/" + url.PathEscape(tag) + "/" + url.PathEscape(name))
+	return url_.String()
+}
+
+func (gl *GitLab) UploadAsset(release *Release, name string, file *os.File) (*github.ReleaseAsset, error) {
+	tag := stringValue(release.TagName)
+
+	// Before the upload, which closes <file>
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = gl.api.do("PUT", gl.packageURL(tag, name), file, "application/octet-stream", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var link _gitlabLink
+	_, err = gl.api.do("POST", gl.project()+"releases/"+url.PathEscape(tag)+"/assets/links", map[string]string{
+		"name":      name,
+		"url":       gl.packageURL(tag, name),
+		"link_type": "package",
+	}, "", &link)
+	if err != nil {
+		return nil, err
+	}
+
+	asset := link.asset()
+	asset.Size = github.Int(int(stat.Size()))
+	return &asset, nil
+}
+
+// DeleteAsset deletes the link to <asset>, and the file of <asset> in the Generic
+// Package Registry (otherwise the registry would keep every file ever replaced).
+func (gl *GitLab) DeleteAsset(release *Release, asset github.ReleaseAsset) error {
+	tag := stringValue(release.TagName)
+	path := fmt.Sprintf("%sreleases/%s/assets/links/%d", gl.project(), url.PathEscape(tag), intValue(asset.ID))
+	response, err := gl.api.do("DELETE", path, nil, "", nil)
+	if err != nil && !isNotFound(response) {
+		return err
+	}
+	return gl.deletePackageFile(tag, stringValue(asset.Name))
+}
+
+// deletePackageFile deletes every file <name> (there can be more than one, of the
+// same name) from the package of <tag>.
+func (gl *GitLab) deletePackageFile(tag, name string) error {
+	// package_name matches any package with the name in it, so it is matched again (below)
+	query := "packages?package_type=generic&package_name=" + url.QueryEscape(gl.Repository) + "&package_version=" + url.QueryEscape(tag) + "&per_page=100"
+	var packages []int
+	for page := 1; page > 0; {
+		var tmp []struct {
+			ID      int    `json:"id"`
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		}
+		response, err := gl.api.do("GET", gl.project()+query+"&page="+strconv.Itoa(page), nil, "", &tmp)
+		if err != nil {
+			if isNotFound(response) && page == 1 {
+				return nil
+			}
+			return err
+		}
+		for _, package_ := range tmp {
+			if package_.Name == gl.Repository && package_.Version == tag {
+				packages = append(packages, package_.ID)
+			}
+		}
+		page, _ = strconv.Atoi(response.Header.Get("X-Next-Page"))
+	}
+
+	for _, package_ := range packages {
+		// Every page first (deleting as it goes would shift the pages after)
+		files := fmt.Sprintf("%spackages/%d/package_files", gl.project(), package_)
+		var matching []int
+		for page := 1; page > 0; {
+			var tmp []struct {
+				ID       int    `json:"id"`
+				FileName string `json:"file_name"`
+			}
+			response, err := gl.api.do("GET", files+"?per_page=100&page="+strconv.Itoa(page), nil, "", &tmp)
+			if err != nil {
+				return err
+			}
+			for _, file := range tmp {
+				if file.FileName == name {
+					matching = append(matching, file.ID)
+				}
+			}
+			page, _ = strconv.Atoi(response.Header.Get("X-Next-Page"))
+		}
+		for _, file := range matching {
+			response, err := gl.api.do("DELETE", fmt.Sprintf("%s/%d", files, file), nil, "", nil)
+			if err != nil && !isNotFound(response) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (gl *GitLab) ResolveTag(tag string) (string, error) {
+	var tmp struct {
+		Commit struct {
+			ID string `json:"id"`
+		} `json:"commit"`
+	}
+	response, err := gl.api.do("GET", gl.project()+"repository/tags/"+url.PathEscape(tag), nil, "", &tmp)
+	if err != nil {
+		if isNotFound(response) {
+			return "", Errorf(ErrTagNotFound, "%s: tag %q does not exist", gl.Location(), tag)
+		}
+		return "", err
+	}
+	return tmp.Commit.ID, nil
+}
+
+// WriteFile commits <content> to <path> (on the default branch).
+func (gl *GitLab) WriteFile(path string, content []byte, message string) (bool, error) {
+	var project struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	_, err := gl.api.do("GET", strings.TrimSuffix(gl.project(), "/"), nil, "", &project)
+	if err != nil {
+		return false, err
+	}
+
+	files := gl.project() + "repository/files/" + url.PathEscape(path)
+	var file struct {
+		Content string `json:"content"`
+	}
+	method := "PUT" // Updating the file
+	response, err := gl.api.do("GET", files+"?ref="+url.QueryEscape(project.DefaultBranch), nil, "", &file)
+	if err != nil {
+		if !isNotFound(response) {
+			return false, err
+		}
+		method = "POST"
+	} else if sameContent(file.Content, content) {
+		return false, nil
+	}
+
+	_, err = gl.api.do(method, files, map[string]string{
+		"branch":         project.DefaultBranch,
+		"content":        base64.StdEncoding.EncodeToString(content),
+		"encoding":       "base64",
+		"commit_message": message,
+	}, "", nil)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (gl *GitLab) DownloadURL(tag, name string) string {
+	return gl.packageURL(tag, name)
+}
+
+func (gl *GitLab) ReleaseURL(tag string) string {
+	return "https://" + gl.Location() + "/-/releases/" + tag
+}
+
+func (gl *GitLab) CompareURL(base, head string) string {
+	return "https://" + gl.Location() + "/-/compare/" + base + "..." + head
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func intValue(value *int) int {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
@@ -9,8 +9,10 @@ https://github.com/robertkrimen/gphr
 package gphr
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -39,8 +41,8 @@ func MatchTarget(target string) (host, owner, repository, program string) {
 func GetTarget(target string) (host, owner, repository, program string, err error) {
 	if target != "" {
 		host, owner, repository, program = MatchTarget(target)
-		if host != "github.com" {
-			return "", "", "", "", fmt.Errorf("invalid target: %s: not a github.com URL", target)
+		if !strings.Contains(host, ".") && !strings.Contains(host, ":") {
+			return "", "", "", "", fmt.Errorf("invalid target: %s: not a URL (e.g. github.com/alice/example)", target)
 		}
 		if repository == "" {
 			return "", "", "", "", fmt.Errorf("invalid target: %s: missing repository", target)
@@ -49,10 +51,23 @@ func GetTarget(target string) (host, owner, repository, program string, err erro
 	return
 }
 
+// APIURL returns the (default) API and upload URLs for <host>: api.github.com
+// for github.com, and /api/v3/ & /api/uploads/ for anything else (GitHub Enterprise).
+func APIURL(host string) (string, string) {
+	if host == "" || host == "github.com" {
+		return "https://api.github.com/", "https://uploads.github.com/"
+	}
+	return "https://" + host + "/api/v3/", "https://" + host + "/api/uploads/"
+}
+
 type GitHub struct {
+	Host       string // github.com (or a GitHub Enterprise host)
 	Owner      string
 	Repository string
 	Client     *github.Client
+	App        *AppTransport // If authenticated as an App (see NewGitHubApp)
+
+	client *http.Client
 }
 
 func NewGitHub(owner, repository string, client *http.Client, token string) *GitHub {
@@ -69,16 +84,76 @@ func NewGitHub(owner, repository string, client *http.Client, token string) *Git
 	}
 
 	gh := &GitHub{
+		Host:       "github.com",
 		Owner:      owner,
 		Repository: repository,
 		Client:     github.NewClient(client),
+		client:     client,
 	}
 
 	return gh
 }
 
+// NewGitHubEnterprise is NewGitHub for a GitHub Enterprise <host>, using the API at
+// <apiURL> and <uploadURL> (if empty, the default for <host>, see APIURL).
+func NewGitHubEnterprise(host, apiURL, uploadURL, owner, repository string, client *http.Client, token string) (*GitHub, error) {
+	gh := NewGitHub(owner, repository, client, token)
+	gh.Host = host
+
+	defaultAPIURL, defaultUploadURL := APIURL(host)
+	if apiURL == "" {
+		apiURL = defaultAPIURL
+	}
+	if uploadURL == "" {
+		uploadURL = defaultUploadURL
+	}
+	for _, tmp := range []struct {
+		url_ string
+		set  **url.URL
+	}{
+		{apiURL, &gh.Client.BaseURL},
+		{uploadURL, &gh.Client.UploadURL},
+	} {
+		if !strings.HasSuffix(tmp.url_, "/") {
+			tmp.url_ += "/"
+		}
+		url_, err := url.Parse(tmp.url_)
+		if err != nil {
+			return nil, fmt.Errorf("invalid API URL: %s: %v", tmp.url_, err)
+		}
+		*tmp.set = url_
+	}
+
+	return gh, nil
+}
+
+// NewGitHubApp is NewGitHubEnterprise (for any host, including github.com), but
+// authenticated as (the installation of) <app> instead of with a token, see AppTransport.
+func NewGitHubApp(host, apiURL, uploadURL, owner, repository string, client *http.Client, app *App) (*GitHub, error) {
+	if host == "" {
+		host = "github.com"
+	}
+	if apiURL == "" {
+		apiURL, _ = APIURL(host)
+	}
+
+	tmp := &http.Client{}
+	if client != nil {
+		*tmp = *client
+	}
+	transport := NewAppTransport(app, apiURL, owner, repository, tmp.Transport)
+	tmp.Transport = transport
+
+	gh, err := NewGitHubEnterprise(host, apiURL, uploadURL, owner, repository, tmp, "")
+	if err != nil {
+		return nil, err
+	}
+	gh.App = transport
+	return gh, nil
+}
+
 func (gh *GitHub) Location() string {
-	return fmt.Sprintf("github.com/%s/%s", gh.Owner, gh.Repository)
+	return fmt.Sprintf("%s/%s/%s", gh.Host, gh.Owner, gh.Repository)
 }
 
 func (gh *GitHub) UploadReleaseAsset(owner, repository string, release int, name string, file *os.File) (*github.ReleaseAsset, *github.Response, error) {
@@ -111,6 +186,84 @@ func (gh *GitHub) UploadReleaseAsset(owner, repository string, release int, name
 	return asset, rp, err
 }
 
+func (gh *GitHub) CreateRelease(release *Release) error {
+	tmp, _, err := gh.Client.Repositories.CreateRelease(gh.Owner, gh.Repository, &release.RepositoryRelease)
+	if err != nil {
+		return err
+	}
+	release.RepositoryRelease = *tmp
+	return nil
+}
+
+func (gh *GitHub) EditRelease(release *Release) error {
+	_, _, err := gh.Client.Repositories.EditRelease(gh.Owner, gh.Repository, *release.ID, &github.RepositoryRelease{
+		Name: release.Name,
+		Body: release.Body,
+	})
+	return err
+}
+
+func (gh *GitHub) UploadAsset(release *Release, name string, file *os.File) (*github.ReleaseAsset, error) {
+	asset, _, err := gh.Client.Repositories.UploadReleaseAsset(gh.Owner, gh.Repository, *release.ID, &github.UploadOptions{Name: name}, file)
+	return asset, err
+}
+
+func (gh *GitHub) DeleteAsset(release *Release, asset github.ReleaseAsset) error {
+	response, err := gh.Client.Repositories.DeleteReleaseAsset(gh.Owner, gh.Repository, *asset.ID)
+	if err != nil {
+		if response == nil || response.StatusCode != 404 {
+			return err
+		}
+	}
+	return nil
+}
+
+func (gh *GitHub) ResolveTag(tag string) (string, error) {
+	commit, _, err := gh.GetCommit(tag)
+	if err == nil && commit == "" {
+		return "", Errorf(ErrTagNotFound, "%s: tag %q does not exist", gh.Location(), tag)
+	}
+	return commit, err
+}
+
+func (gh *GitHub) DownloadURL(tag, name string) string {
+	return "https://" + gh.Location() + "/releases/download/" + tag + "/" + name
+}
+
+func (gh *GitHub) ReleaseURL(tag string) string {
+	return "https://" + gh.Location() + "/releases/tag/" + tag
+}
+
+func (gh *GitHub) CompareURL(base, head string) string {
+	return "https://" + gh.Location() + "/compare/" + base + "..." + head
+}
+
+// GetAuthenticatedUser returns the login of the user the token is for, and the
+// scopes of the token (none for a fine-grained token, which has permissions
+// instead).
+func (gh *GitHub) GetAuthenticatedUser() (string, []string, error) {
+	rq, err := gh.Client.NewRequest("GET", "user", nil)
+	if err != nil {
+		return "", nil, err
+	}
+	user := new(github.User)
+	rp, err := gh.Client.Do(rq, user)
+	if err != nil {
+		return "", nil, err
+	}
+	var scopes []string
+	for _, scope := range strings.Split(rp.Header.Get("X-OAuth-Scopes"), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	login := ""
+	if user.Login != nil {
+		login = *user.Login
+	}
+	return login, scopes, nil
+}
+
 func (gh *GitHub) TagExists(tag string) (bool, error) {
 	_, response, err := gh.Client.Git.GetRef(gh.Owner, gh.Repository, "tags/"+tag)
 	if response != nil {
@@ -179,23 +332,139 @@ func (gh *GitHub) GetReleases() ([]*Release, error) {
 	return releases, nil
 }
 
+// GetRepositories returns the repositories of the owner: of an organization,
+// those visible with the token, and of a user, every one if the user is the one
+// of the token, otherwise only the public ones (which is all GitHub lists).
+func (gh *GitHub) GetRepositories() ([]string, error) {
+	var repositories []string
+	list := func(list func(options github.ListOptions) ([]github.Repository, *github.Response, error)) (*github.Response, error) {
+		return pages(func(options *github.ListOptions) (*github.Response, error) {
+			tmp, response, err := list(*options)
+			if err != nil {
+				return response, err
+			}
+			for _, repository := range tmp {
+				repositories = append(repositories, stringValue(repository.Name))
+			}
+			return response, nil
+		})
+	}
+
+	response, err := list(func(options github.ListOptions) ([]github.Repository, *github.Response, error) {
+		return gh.Client.Repositories.ListByOrg(gh.Owner, &github.RepositoryListByOrgOptions{ListOptions: options})
+	})
+	if err == nil {
+		return repositories, nil
+	}
+	if response == nil || response.StatusCode != 404 {
+		return nil, err
+	}
+
+	// Not an organization, but a user (the user of the token, if /users/:owner/repos would be only the public ones)
+	user := gh.Owner
+	if login, _, err := gh.GetAuthenticatedUser(); err == nil && strings.EqualFold(login, gh.Owner) {
+		user = ""
+	}
+	_, err = list(func(options github.ListOptions) ([]github.Repository, *github.Response, error) {
+		return gh.Client.Repositories.List(user, &github.RepositoryListOptions{Type: "owner", ListOptions: options})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repositories, nil
+}
+
 func (gh *GitHub) GetAssetURL(program, platform string) (string, error) {
 	releases, err := gh.GetReleases()
 	if err != nil {
 		return "", err
 	}
 
-	binary := NewBinary(program + "_" + platform)
+	release, asset := FindAsset(releases, NewBinary(program+"_"+platform), "")
+	if asset == nil {
+		return "", nil
+	}
+	return gh.DownloadURL(*release.TagName, *asset.Name), nil
+}
 
+// FindAsset returns the newest asset of <releases> (newest first) that matches
+// <binary> (and its release), of the release for <tag> (v1.2.0, or 1.2.0), if
+// given. It returns nil if there is no such asset.
+func FindAsset(releases []*Release, binary *Binary, tag string) (*Release, *github.ReleaseAsset) {
 	for _, release := range releases {
-		for _, asset := range release.Assets {
-			if binary.Match(*asset.Name) {
-				return "https://" + gh.Location() + "/releases/download/" + *release.TagName + "/" + *asset.Name, nil
+		if tag != "" && strings.TrimPrefix(stringValue(release.TagName), "v") != strings.TrimPrefix(tag, "v") {
+			continue
+		}
+		for index := range release.Assets {
+			if asset := &release.Assets[index]; binary.Match(stringValue(asset.Name)) {
+				return release, asset
 			}
 		}
 	}
+	return nil, nil
+}
+
+// Download returns the content of <asset> (of <release>), from the API (which,
+// unlike the DownloadURL, works for a private repository, too).
+func (gh *GitHub) Download(release *Release, asset github.ReleaseAsset) (io.ReadCloser, error) {
+	request, err := gh.Client.NewRequest("GET", stringValue(asset.URL), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/octet-stream")
+
+	client := gh.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	err = github.CheckResponse(response)
+	if err != nil {
+		response.Body.Close()
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+// WriteFile commits <content> to <path> (with the contents API).
+func (gh *GitHub) WriteFile(path string, content []byte, message string) (bool, error) {
+	contents := fmt.Sprintf("repos/%s/%s/contents/%s", gh.Owner, gh.Repository, escapePath(path))
+	request, err := gh.Client.NewRequest("GET", contents, nil)
+	if err != nil {
+		return false, err
+	}
+	var file struct {
+		SHA     string `json:"sha"`
+		Content string `json:"content"`
+	}
+	response, err := gh.Client.Do(request, &file)
+	if err != nil {
+		if response == nil || response.StatusCode != 404 {
+			return false, err
+		}
+	} else if sameContent(file.Content, content) {
+		return false, nil
+	}
 
-	return "", nil
+	body := map[string]string{
+		"message": message,
+		"content": base64.StdEncoding.EncodeToString(content),
+	}
+	if file.SHA != "" {
+		body["sha"] = file.SHA // Updating the file
+	}
+	request, err = gh.Client.NewRequest("PUT", contents, body)
+	if err != nil {
+		return false, err
+	}
+	_, err = gh.Client.Do(request, nil)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 func (gh *GitHub) GetReleaseAssets(release github.RepositoryRelease) ([]github.ReleaseAsset, error) {
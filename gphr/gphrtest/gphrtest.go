@@ -0,0 +1,964 @@
+/*
+Package gphrtest provides a fake GitHub, for testing gphr (or anything else that
+uses GitHub Releases) without GitHub.
+
+    server := gphrtest.NewServer()
+    defer server.Close()
+
+    repository := server.Repository("alice", "example")
+    repository.Tag("v1.0.0", "0123456789abcdef0123456789abcdef01234567")
+    release := repository.CreateRelease("v1.0.0")
+    release.CreateAsset("example_linux_386", []byte("..."))
+
+The server is a GitHub Enterprise host (server.Host()), with the API at /api/v3/,
+uploads at /api/uploads/, and the web interface (/<owner>/<repository>/releases/...)
+at the root. It implements:
+
+    GET    /repos/:owner/:repository/releases
+    POST   /repos/:owner/:repository/releases
+    GET    /repos/:owner/:repository/releases/:id
+    PATCH  /repos/:owner/:repository/releases/:id
+    DELETE /repos/:owner/:repository/releases/:id
+    GET    /repos/:owner/:repository/releases/:id/assets
+    POST   /repos/:owner/:repository/releases/:id/assets?name=:name (uploads)
+    GET    /repos/:owner/:repository/releases/assets/:id
+    DELETE /repos/:owner/:repository/releases/assets/:id
+    GET    /repos/:owner/:repository/git/refs/tags/:tag
+    GET    /repos/:owner/:repository/commits/:sha (or :tag)
+    GET    /repos/:owner/:repository/contents/:path
+    PUT    /repos/:owner/:repository/contents/:path
+    GET    /user
+    GET    /user/repos
+    GET    /users/:owner/repos
+    GET    /orgs/:owner/repos
+    GET    /repos/:owner/:repository/installation (as the App)
+    POST   /app/installations/:id/access_tokens (as the App)
+
+    GET    /:owner/:repository/releases/latest
+    GET    /:owner/:repository/releases/tag/:tag
+    GET    /:owner/:repository/releases/download/:tag/:name
+
+Lists are paginated (PerPage per page, with a Link header), anything that
+does not exist is a 404, and every GET of the API has an ETag (If-None-Match
+gets a 304).
+
+If AppID and AppKey are set, the server is also a GitHub App (installed, as
+installation 1, on every repository), and an installation token (from a JWT
+signed by the App) is as good as the Token.
+*/
+package gphrtest
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+type Server struct {
+	*httptest.Server
+
+	// PerPage is the number of items per page of a list (30, like GitHub).
+	PerPage int
+
+	// Token, if not empty, is required (as "token <Token>" or "Bearer <Token>")
+	// for every API request that is not a GET (as if every repository is public),
+	// and for GET /user.
+	Token string
+
+	// Private, if true, requires the Token for every request of the API (a GET
+	// too), with a 404 without it, as for a private repository. The web pages
+	// are public still.
+	Private bool
+
+	// Login and Scopes are the user (alice) and the scopes (repo) of the Token.
+	Login  string
+	Scopes string
+
+	// Organizations are the owners that are organizations (the rest are users).
+	Organizations []string
+
+	// AppID and AppKey (the public key of the App) are the GitHub App to accept
+	// a JWT from, and TokenLifetime is how long an installation token lasts (an
+	// hour, like GitHub).
+	AppID         string
+	AppKey        *rsa.PublicKey
+	TokenLifetime time.Duration
+
+	// NotModified is the number of 304 responses (to a GET of the API with
+	// If-None-Match), which do not count against the rate limit of GitHub.
+	NotModified int
+
+	// BreakUploads is the number of (the next) uploads to break off halfway, with
+	// a 502, leaving behind an asset in the "starter" state (as GitHub does).
+	BreakUploads int
+
+	// AccessTokens are the installation tokens issued (the newest last), and
+	// when each expires.
+	AccessTokens map[string]time.Time
+
+	mutex        sync.Mutex
+	repositories map[string]*Repository
+	id           int
+	now          time.Time
+}
+
+type Repository struct {
+	Owner    string
+	Name     string
+	Private  bool              // Not listed without the Token (by /users/:owner/repos, not at all)
+	Tags     map[string]string // v1.0.0 => <commit>
+	Releases []*Release
+	Files    map[string][]byte // Formula/example.rb => <content> (of the default branch)
+	Commits  []string          // The message of every commit (of a file)
+
+	server *Server
+}
+
+type Release struct {
+	github.RepositoryRelease
+	Assets []*Asset
+
+	repository *Repository
+}
+
+type Asset struct {
+	github.ReleaseAsset
+	Content []byte
+
+	release *Release
+}
+
+// NewServer starts and returns a new (TLS) server. Use Client (or trust the
+// certificate of the server) to talk to it.
+func NewServer() *Server {
+	server := &Server{
+		PerPage:       30,
+		Login:         "alice",
+		Scopes:        "repo",
+		TokenLifetime: time.Hour,
+		AccessTokens:  map[string]time.Time{},
+		repositories:  map[string]*Repository{},
+		now:           time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	server.Server = httptest.NewTLSServer(http.HandlerFunc(server.serveHTTP))
+	return server
+}
+
+// Host returns the host (and port) of the server, e.g. 127.0.0.1:49152.
+func (server *Server) Host() string {
+	url_, _ := url.Parse(server.URL)
+	return url_.Host
+}
+
+// APIURL returns the URL of the API and of uploads.
+func (server *Server) APIURL() (string, string) {
+	return server.URL + "/api/v3/", server.URL + "/api/uploads/"
+}
+
+// Client returns an http.Client that trusts the server.
+func (server *Server) Client() *http.Client {
+	return &http.Client{Transport: server.Transport()}
+}
+
+// Transport returns an http.Transport that trusts the server (or, rather, that
+// does not verify any certificate).
+func (server *Server) Transport() *http.Transport {
+	return &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+}
+
+// Repository returns <owner>/<name>, creating it if necessary.
+func (server *Server) Repository(owner, name string) *Repository {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.repository(owner, name, true)
+}
+
+func (server *Server) repository(owner, name string, create bool) *Repository {
+	key := owner + "/" + name
+	repository := server.repositories[key]
+	if repository == nil && create {
+		repository = &Repository{
+			Owner:  owner,
+			Name:   name,
+			Tags:   map[string]string{},
+			Files:  map[string][]byte{},
+			server: server,
+		}
+		server.repositories[key] = repository
+	}
+	return repository
+}
+
+// next returns the next ID, and the next time (a second apart, so that
+// everything is ordered by time as it is by ID).
+func (server *Server) next() (int, *github.Timestamp) {
+	server.id++
+	server.now = server.now.Add(time.Second)
+	return server.id, &github.Timestamp{Time: server.now}
+}
+
+// Tag creates (or moves) <tag> to <commit>.
+func (repository *Repository) Tag(tag, commit string) {
+	repository.server.mutex.Lock()
+	defer repository.server.mutex.Unlock()
+	repository.Tags[tag] = commit
+}
+
+// Release returns the release for <tag> (or nil).
+func (repository *Repository) Release(tag string) *Release {
+	repository.server.mutex.Lock()
+	defer repository.server.mutex.Unlock()
+	return repository.release(tag)
+}
+
+func (repository *Repository) release(tag string) *Release {
+	for _, release := range repository.Releases {
+		if *release.TagName == tag {
+			return release
+		}
+	}
+	return nil
+}
+
+// CreateRelease creates a release for <tag>.
+func (repository *Repository) CreateRelease(tag string) *Release {
+	repository.server.mutex.Lock()
+	defer repository.server.mutex.Unlock()
+	return repository.createRelease(github.RepositoryRelease{TagName: github.String(tag)})
+}
+
+func (repository *Repository) createRelease(tmp github.RepositoryRelease) *Release {
+	release := &Release{
+		RepositoryRelease: tmp,
+		repository:        repository,
+	}
+	id, now := repository.server.next()
+	release.ID = github.Int(id)
+	release.CreatedAt = now
+	release.PublishedAt = now
+	if release.Name == nil {
+		release.Name = github.String(*release.TagName)
+	}
+	if release.Draft == nil {
+		release.Draft = github.Bool(false)
+	}
+	if release.Prerelease == nil {
+		release.Prerelease = github.Bool(false)
+	}
+	repository.Releases = append(repository.Releases, release)
+	return release
+}
+
+// Asset returns the asset <name> (or nil).
+func (release *Release) Asset(name string) *Asset {
+	release.repository.server.mutex.Lock()
+	defer release.repository.server.mutex.Unlock()
+	return release.asset(name)
+}
+
+func (release *Release) asset(name string) *Asset {
+	for _, asset := range release.Assets {
+		if *asset.Name == name {
+			return asset
+		}
+	}
+	return nil
+}
+
+// CreateAsset creates (uploads) the asset <name> of <release>.
+func (release *Release) CreateAsset(name string, content []byte) *Asset {
+	release.repository.server.mutex.Lock()
+	defer release.repository.server.mutex.Unlock()
+	return release.createAsset(name, content)
+}
+
+func (release *Release) createAsset(name string, content []byte) *Asset {
+	server := release.repository.server
+	asset := &Asset{
+		Content: content,
+		release: release,
+	}
+	id, now := server.next()
+	asset.ID = github.Int(id)
+	asset.Name = github.String(name)
+	asset.Label = github.String("")
+	asset.State = github.String("uploaded")
+	asset.ContentType = github.String("application/octet-stream")
+	asset.Size = github.Int(len(content))
+	asset.DownloadCount = github.Int(0)
+	asset.Uploader = &github.User{Login: github.String(server.Login)}
+	asset.CreatedAt = now
+	asset.UpdatedAt = now
+	asset.URL = github.String(fmt.Sprintf("%s/api/v3/repos/%s/%s/releases/assets/%d", server.URL, release.repository.Owner, release.repository.Name, id))
+	release.Assets = append(release.Assets, asset)
+	return asset
+}
+
+// DownloadURL returns the (web) URL to download <asset>.
+func (asset *Asset) DownloadURL() string {
+	repository := asset.release.repository
+	return fmt.Sprintf("%s/%s/%s/releases/download/%s/%s", repository.server.URL, repository.Owner, repository.Name, *asset.release.TagName, *asset.Name)
+}
+
+func (server *Server) serveHTTP(response http.ResponseWriter, request *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	path := request.URL.Path
+	switch {
+	case strings.HasPrefix(path, "/api/v3/app/"):
+		server.serveApp(response, request, strings.Split(strings.Trim(strings.TrimPrefix(path, "/api/v3/app/"), "/"), "/"))
+	case strings.HasPrefix(path, "/api/v3/"):
+		if request.Method != "GET" && !server.authorized(request) {
+			server.error(response, 401, "Bad credentials")
+			return
+		}
+		if server.Private && !server.authorized(request) {
+			server.error(response, 404, "Not Found")
+			return
+		}
+		path := strings.Split(strings.Trim(strings.TrimPrefix(path, "/api/v3/"), "/"), "/")
+		if request.Method != "GET" {
+			server.serveAPI(response, request, path)
+			return
+		}
+		recorder := httptest.NewRecorder()
+		server.serveAPI(recorder, request, path)
+		server.etag(response, request, recorder)
+	case strings.HasPrefix(path, "/api/uploads/"):
+		if request.Method != "GET" && !server.authorized(request) {
+			server.error(response, 401, "Bad credentials")
+			return
+		}
+		server.serveUpload(response, request, strings.Split(strings.Trim(strings.TrimPrefix(path, "/api/uploads/"), "/"), "/"))
+	default:
+		server.serveWeb(response, request, strings.Split(strings.Trim(path, "/"), "/"))
+	}
+}
+
+// repos writes the repositories of <owner> (with the private ones, if <private>).
+func (server *Server) repos(response http.ResponseWriter, request *http.Request, owner string, private bool) {
+	var names []string
+	found := false
+	for _, repository := range server.repositories {
+		if repository.Owner == owner {
+			found = true
+			if !repository.Private || private {
+				names = append(names, repository.Name)
+			}
+		}
+	}
+	if !found {
+		server.error(response, 404, "Not Found")
+		return
+	}
+	sort.Strings(names)
+	items := []interface{}{}
+	for _, name := range names {
+		items = append(items, github.Repository{
+			Name:     github.String(name),
+			FullName: github.String(owner + "/" + name),
+		})
+	}
+	server.page(response, request, items)
+}
+
+// etag writes <recorder> (a GET of the API) to <response>, with an ETag, or
+// just a 304 if the ETag is the one in If-None-Match.
+func (server *Server) etag(response http.ResponseWriter, request *http.Request, recorder *httptest.ResponseRecorder) {
+	for key, values := range recorder.Header() {
+		response.Header()[key] = values
+	}
+	if recorder.Code == 200 {
+		// The Link (of a page) is part of the response, too: the same first page
+		// of a list that got longer is not the same response
+		hash := sha256.Sum256(append([]byte(recorder.Header().Get("Link")+"\n"), recorder.Body.Bytes()...))
+		etag := fmt.Sprintf(`"%x"`, hash[:16])
+		response.Header().Set("ETag", etag)
+		response.Header().Set("Cache-Control", "private, max-age=60, s-maxage=60")
+		response.Header().Set("Vary", "Accept, Authorization, Cookie")
+		if request.Header.Get("If-None-Match") == etag {
+			server.NotModified++
+			response.WriteHeader(304)
+			return
+		}
+	}
+	response.WriteHeader(recorder.Code)
+	response.Write(recorder.Body.Bytes())
+}
+
+func (server *Server) authorized(request *http.Request) bool {
+	if server.Token == "" {
+		return true
+	}
+	authorization := request.Header.Get("Authorization")
+	if expires, exists := server.AccessTokens[strings.TrimPrefix(authorization, "token ")]; exists {
+		return time.Now().Before(expires)
+	}
+	return authorization == "token "+server.Token || authorization == "Bearer "+server.Token
+}
+
+// authorizedApp returns whether <request> is authorized with a (valid) JWT for the App.
+func (server *Server) authorizedApp(request *http.Request) bool {
+	authorization := request.Header.Get("Authorization")
+	if server.AppKey == nil || !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+	parts := strings.Split(strings.TrimPrefix(authorization, "Bearer "), ".")
+	if len(parts) != 3 {
+		return false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if rsa.VerifyPKCS1v15(server.AppKey, crypto.SHA256, hash[:], signature) != nil {
+		return false
+	}
+	content, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	var claims struct {
+		Iss interface{} `json:"iss"`
+		Iat int64       `json:"iat"`
+		Exp int64       `json:"exp"`
+	}
+	if json.Unmarshal(content, &claims) != nil {
+		return false
+	}
+	now := time.Now().Unix()
+	return fmt.Sprint(claims.Iss) == server.AppID && claims.Iat <= now && now < claims.Exp && claims.Exp-claims.Iat <= 600
+}
+
+// serveApp serves app/installations/1/access_tokens.
+func (server *Server) serveApp(response http.ResponseWriter, request *http.Request, path []string) {
+	if !server.authorizedApp(request) {
+		server.error(response, 401, "A JSON web token could not be decoded")
+		return
+	}
+	if len(path) != 3 || path[0] != "installations" || path[2] != "access_tokens" || request.Method != "POST" {
+		server.error(response, 404, "Not Found")
+		return
+	}
+	if path[1] != "1" {
+		server.error(response, 404, "Not Found")
+		return
+	}
+
+	var tmp struct {
+		Repositories []string `json:"repositories"`
+	}
+	json.NewDecoder(request.Body).Decode(&tmp)
+
+	token := fmt.Sprintf("ghs_%036d", len(server.AccessTokens)+1)
+	expires := time.Now().Add(server.TokenLifetime).UTC()
+	server.AccessTokens[token] = expires
+	server.json(response, 201, map[string]interface{}{
+		"token":        token,
+		"expires_at":   expires.Format(time.RFC3339),
+		"repositories": tmp.Repositories,
+	})
+}
+
+func (server *Server) error(response http.ResponseWriter, status int, message string) {
+	server.json(response, status, map[string]string{
+		"message":           message,
+		"documentation_url": "https://developer.github.com/v3",
+	})
+}
+
+// invalid writes a 422 (as GitHub does, with the error of <resource> in "errors").
+func (server *Server) invalid(response http.ResponseWriter, resource, field, code string) {
+	server.json(response, 422, map[string]interface{}{
+		"message": "Validation Failed",
+		"errors": []map[string]string{
+			{"resource": resource, "field": field, "code": code},
+		},
+		"documentation_url": "https://developer.github.com/v3",
+	})
+}
+
+func (server *Server) json(response http.ResponseWriter, status int, value interface{}) {
+	response.Header().Set("Content-Type", "application/json; charset=utf-8")
+	response.WriteHeader(status)
+	if value != nil {
+		json.NewEncoder(response).Encode(value)
+	}
+}
+
+// page writes page ?page= (of ?per_page=) of <items>, with a Link header.
+func (server *Server) page(response http.ResponseWriter, request *http.Request, items []interface{}) {
+	query := request.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(query.Get("per_page"))
+	if perPage < 1 {
+		perPage = server.PerPage
+	}
+	last := (len(items) + perPage - 1) / perPage
+	if last < 1 {
+		last = 1
+	}
+
+	link := func(page int, rel string) string {
+		url_ := *request.URL
+		url_.Scheme, url_.Host = "https", request.Host
+		tmp := url_.Query()
+		tmp.Set("page", strconv.Itoa(page))
+		url_.RawQuery = tmp.Encode()
+		return fmt.Sprintf(`<%s>; rel="%s"`, url_.String(), rel)
+	}
+	var links []string
+	if page < last {
+		links = append(links, link(page+1, "next"), link(last, "last"))
+	}
+	if page > 1 {
+		links = append(links, link(1, "first"), link(page-1, "prev"))
+	}
+	if len(links) > 0 {
+		response.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	start, end := (page-1)*perPage, page*perPage
+	if start > len(items) {
+		start = len(items)
+	}
+	if end > len(items) {
+		end = len(items)
+	}
+	server.json(response, 200, items[start:end])
+}
+
+func (release *Release) json() github.RepositoryRelease {
+	tmp := release.RepositoryRelease
+	repository := release.repository
+	base := fmt.Sprintf("%s/api/v3/repos/%s/%s/releases/%d", repository.server.URL, repository.Owner, repository.Name, *release.ID)
+	tmp.URL = github.String(base)
+	tmp.AssetsURL = github.String(base + "/assets")
+	tmp.UploadURL = github.String(fmt.Sprintf("%s/api/uploads/repos/%s/%s/releases/%d/assets{?name}", repository.server.URL, repository.Owner, repository.Name, *release.ID))
+	tmp.HTMLURL = github.String(fmt.Sprintf("%s/%s/%s/releases/tag/%s", repository.server.URL, repository.Owner, repository.Name, *release.TagName))
+	return tmp
+}
+
+func (server *Server) findAsset(repository *Repository, id int) (*Release, int) {
+	for _, release := range repository.Releases {
+		for index, asset := range release.Assets {
+			if *asset.ID == id {
+				return release, index
+			}
+		}
+	}
+	return nil, -1
+}
+
+func (server *Server) serveAPI(response http.ResponseWriter, request *http.Request, path []string) {
+	if len(path) == 1 && path[0] == "user" && request.Method == "GET" {
+		if request.Header.Get("Authorization") == "" {
+			server.error(response, 401, "Requires authentication")
+			return
+		}
+		if !server.authorized(request) {
+			server.error(response, 401, "Bad credentials")
+			return
+		}
+		response.Header().Set("X-OAuth-Scopes", server.Scopes)
+		server.json(response, 200, github.User{
+			Login: github.String(server.Login),
+			ID:    github.Int(1),
+		})
+		return
+	}
+
+	if len(path) == 3 && (path[0] == "users" || path[0] == "orgs") && path[2] == "repos" && request.Method == "GET" {
+		organization := false
+		for _, tmp := range server.Organizations {
+			organization = organization || tmp == path[1]
+		}
+		if path[0] == "orgs" && !organization {
+			server.error(response, 404, "Not Found")
+			return
+		}
+		// Of a user, only the public repositories (whatever the token), of an organization, those visible with the token
+		authenticated := request.Header.Get("Authorization") != "" && server.authorized(request)
+		server.repos(response, request, path[1], path[0] == "orgs" && authenticated)
+		return
+	}
+
+	if len(path) == 2 && path[0] == "user" && path[1] == "repos" && request.Method == "GET" {
+		if request.Header.Get("Authorization") == "" || !server.authorized(request) {
+			server.error(response, 401, "Requires authentication")
+			return
+		}
+		server.repos(response, request, server.Login, true) // (As with ?type=owner)
+		return
+	}
+
+	// repos/:owner/:repository/...
+	if len(path) < 4 || path[0] != "repos" {
+		server.error(response, 404, "Not Found")
+		return
+	}
+	repository := server.repository(path[1], path[2], false)
+	if repository == nil {
+		server.error(response, 404, "Not Found")
+		return
+	}
+	method, path := request.Method, path[3:]
+
+	switch {
+	case path[0] == "installation" && len(path) == 1 && method == "GET":
+		if !server.authorizedApp(request) {
+			server.error(response, 401, "A JSON web token could not be decoded")
+			return
+		}
+		server.json(response, 200, map[string]interface{}{"id": 1, "app_id": server.AppID})
+
+	case path[0] == "releases" && len(path) == 1:
+		switch method {
+		case "GET":
+			var items []interface{}
+			for index := len(repository.Releases) - 1; index >= 0; index-- {
+				items = append(items, repository.Releases[index].json())
+			}
+			server.page(response, request, items)
+		case "POST":
+			var tmp github.RepositoryRelease
+			err := json.NewDecoder(request.Body).Decode(&tmp)
+			if err != nil || tmp.TagName == nil || *tmp.TagName == "" {
+				server.error(response, 422, "Validation Failed")
+				return
+			}
+			if repository.release(*tmp.TagName) != nil {
+				server.invalid(response, "Release", "tag_name", "already_exists")
+				return
+			}
+			if _, exists := repository.Tags[*tmp.TagName]; !exists {
+				// GitHub creates the tag (from target_commitish)
+				if tmp.TargetCommitish == nil || *tmp.TargetCommitish == "" {
+					server.error(response, 422, "Validation Failed: tag_name does not exist")
+					return
+				}
+				repository.Tags[*tmp.TagName] = *tmp.TargetCommitish
+			}
+			server.json(response, 201, repository.createRelease(tmp).json())
+		default:
+			server.error(response, 405, "Method Not Allowed")
+		}
+
+	case path[0] == "releases" && len(path) == 3 && path[1] == "assets":
+		id, _ := strconv.Atoi(path[2])
+		release, index := server.findAsset(repository, id)
+		if release == nil {
+			server.error(response, 404, "Not Found")
+			return
+		}
+		asset := release.Assets[index]
+		switch method {
+		case "GET":
+			if request.Header.Get("Accept") == "application/octet-stream" {
+				http.Redirect(response, request, asset.DownloadURL(), 302)
+				return
+			}
+			server.json(response, 200, asset.ReleaseAsset)
+		case "DELETE":
+			release.Assets = append(release.Assets[:index], release.Assets[index+1:]...)
+			server.json(response, 204, nil)
+		default:
+			server.error(response, 405, "Method Not Allowed")
+		}
+
+	case path[0] == "releases" && (len(path) == 2 || len(path) == 3 && path[2] == "assets"):
+		id, _ := strconv.Atoi(path[1])
+		index := -1
+		for tmp, release := range repository.Releases {
+			if *release.ID == id {
+				index = tmp
+			}
+		}
+		if index == -1 {
+			server.error(response, 404, "Not Found")
+			return
+		}
+		release := repository.Releases[index]
+
+		if len(path) == 3 {
+			if method != "GET" {
+				server.error(response, 405, "Method Not Allowed")
+				return
+			}
+			var items []interface{}
+			for _, asset := range release.Assets {
+				items = append(items, asset.ReleaseAsset)
+			}
+			server.page(response, request, items)
+			return
+		}
+
+		switch method {
+		case "GET":
+			server.json(response, 200, release.json())
+		case "PATCH":
+			var tmp github.RepositoryRelease
+			err := json.NewDecoder(request.Body).Decode(&tmp)
+			if err != nil {
+				server.error(response, 400, "Problems parsing JSON")
+				return
+			}
+			if tmp.Name != nil {
+				release.Name = tmp.Name
+			}
+			if tmp.Body != nil {
+				release.Body = tmp.Body
+			}
+			if tmp.Draft != nil {
+				release.Draft = tmp.Draft
+			}
+			if tmp.Prerelease != nil {
+				release.Prerelease = tmp.Prerelease
+			}
+			server.json(response, 200, release.json())
+		case "DELETE":
+			repository.Releases = append(repository.Releases[:index], repository.Releases[index+1:]...)
+			server.json(response, 204, nil)
+		default:
+			server.error(response, 405, "Method Not Allowed")
+		}
+
+	case path[0] == "git" && len(path) >= 4 && path[1] == "refs" && path[2] == "tags" && method == "GET":
+		tag := strings.Join(path[3:], "/")
+		commit, exists := repository.Tags[tag]
+		if !exists {
+			server.error(response, 404, "Not Found")
+			return
+		}
+		server.json(response, 200, github.Reference{
+			Ref: github.String("refs/tags/" + tag),
+			Object: &github.GitObject{
+				Type: github.String("commit"),
+				SHA:  github.String(commit),
+			},
+		})
+
+	case path[0] == "contents" && len(path) >= 2:
+		name := strings.Join(path[1:], "/")
+		content, exists := repository.Files[name]
+		sha := blobSHA(content)
+		switch method {
+		case "GET":
+			if !exists {
+				server.error(response, 404, "Not Found")
+				return
+			}
+			server.json(response, 200, map[string]interface{}{
+				"type":     "file",
+				"path":     name,
+				"sha":      sha,
+				"size":     len(content),
+				"encoding": "base64",
+				"content":  base64.StdEncoding.EncodeToString(content),
+			})
+		case "PUT":
+			var tmp struct {
+				Message string `json:"message"`
+				Content string `json:"content"`
+				SHA     string `json:"sha"`
+			}
+			err := json.NewDecoder(request.Body).Decode(&tmp)
+			if err != nil || tmp.Message == "" {
+				server.error(response, 422, "Validation Failed")
+				return
+			}
+			content, err := base64.StdEncoding.DecodeString(tmp.Content)
+			if err != nil {
+				server.error(response, 422, "Validation Failed: content is not valid Base64")
+				return
+			}
+			if exists && tmp.SHA != sha {
+				server.error(response, 409, name+" does not match "+tmp.SHA)
+				return
+			}
+			repository.Files[name] = content
+			repository.Commits = append(repository.Commits, tmp.Message)
+			status := 200
+			if !exists {
+				status = 201
+			}
+			server.json(response, status, map[string]interface{}{
+				"content": map[string]interface{}{"path": name, "sha": blobSHA(content)},
+			})
+		default:
+			server.error(response, 405, "Method Not Allowed")
+		}
+
+	case path[0] == "commits" && len(path) >= 2 && method == "GET":
+		sha := strings.Join(path[1:], "/")
+		if commit, exists := repository.Tags[sha]; exists {
+			sha = commit
+		} else {
+			found := false
+			for _, commit := range repository.Tags {
+				if len(sha) >= 7 && strings.HasPrefix(commit, sha) {
+					sha, found = commit, true
+					break
+				}
+			}
+			if !found {
+				server.error(response, 404, "Not Found")
+				return
+			}
+		}
+		server.json(response, 200, github.RepositoryCommit{
+			SHA: github.String(sha),
+		})
+
+	default:
+		server.error(response, 404, "Not Found")
+	}
+}
+
+// blobSHA returns the SHA of <content> as a git blob.
+func blobSHA(content []byte) string {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "blob %d\x00", len(content))
+	hash.Write(content)
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+func (server *Server) serveUpload(response http.ResponseWriter, request *http.Request, path []string) {
+	// repos/:owner/:repository/releases/:id/assets?name=:name
+	if len(path) != 6 || path[0] != "repos" || path[3] != "releases" || path[5] != "assets" {
+		server.error(response, 404, "Not Found")
+		return
+	}
+	if request.Method != "POST" {
+		server.error(response, 405, "Method Not Allowed")
+		return
+	}
+	repository := server.repository(path[1], path[2], false)
+	if repository == nil {
+		server.error(response, 404, "Not Found")
+		return
+	}
+	id, _ := strconv.Atoi(path[4])
+	var release *Release
+	for _, tmp := range repository.Releases {
+		if *tmp.ID == id {
+			release = tmp
+		}
+	}
+	if release == nil {
+		server.error(response, 404, "Not Found")
+		return
+	}
+
+	name := request.URL.Query().Get("name")
+	if name == "" {
+		server.error(response, 422, "Validation Failed: name")
+		return
+	}
+	if release.asset(name) != nil {
+		server.invalid(response, "ReleaseAsset", "name", "already_exists")
+		return
+	}
+	content, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		server.error(response, 400, err.Error())
+		return
+	}
+	if request.ContentLength >= 0 && int64(len(content)) != request.ContentLength {
+		server.error(response, 400, "Content-Length does not match the body")
+		return
+	}
+	if server.BreakUploads > 0 {
+		server.BreakUploads--
+		release.createAsset(name, content[:len(content)/2]).State = github.String("starter")
+		server.error(response, 502, "Server Error")
+		return
+	}
+	server.json(response, 201, release.createAsset(name, content).ReleaseAsset)
+}
+
+func (server *Server) serveWeb(response http.ResponseWriter, request *http.Request, path []string) {
+	// :owner/:repository/releases/...
+	if len(path) < 4 || path[2] != "releases" || request.Method != "GET" {
+		http.NotFound(response, request)
+		return
+	}
+	repository := server.repository(path[0], path[1], false)
+	if repository == nil {
+		http.NotFound(response, request)
+		return
+	}
+
+	switch {
+	case path[3] == "latest" && len(path) == 4:
+		var releases []*Release
+		for _, release := range repository.Releases {
+			if !*release.Draft && !*release.Prerelease {
+				releases = append(releases, release)
+			}
+		}
+		if len(releases) == 0 {
+			http.NotFound(response, request)
+			return
+		}
+		sort.Sort(_sortReleaseByTime(releases))
+		latest := releases[len(releases)-1]
+		http.Redirect(response, request, fmt.Sprintf("/%s/%s/releases/tag/%s", repository.Owner, repository.Name, *latest.TagName), 302)
+
+	case path[3] == "tag" && len(path) >= 5:
+		if repository.release(strings.Join(path[4:], "/")) == nil {
+			http.NotFound(response, request)
+			return
+		}
+		response.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(response, "<html><body>%s</body></html>\n", strings.Join(path[4:], "/"))
+
+	case path[3] == "download" && len(path) == 6:
+		release := repository.release(path[4])
+		if release == nil {
+			http.NotFound(response, request)
+			return
+		}
+		asset := release.asset(path[5])
+		if asset == nil {
+			http.NotFound(response, request)
+			return
+		}
+		*asset.DownloadCount++
+		response.Header().Set("Content-Type", "application/octet-stream")
+		response.Header().Set("Content-Length", strconv.Itoa(len(asset.Content)))
+		response.Write(asset.Content)
+
+	default:
+		http.NotFound(response, request)
+	}
+}
+
+type _sortReleaseByTime []*Release
+
+func (a _sortReleaseByTime) Len() int      { return len(a) }
+func (a _sortReleaseByTime) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a _sortReleaseByTime) Less(i, j int) bool {
+	return a[i].CreatedAt.UnixNano() < a[j].CreatedAt.UnixNano()
+}
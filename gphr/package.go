@@ -0,0 +1,531 @@
+package gphr
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A Package is the metadata of a (Linux) package of a program: a .deb (Debian,
+// Ubuntu, ...), an .rpm (Fedora, RHEL, SUSE, ...), or an .apk (Alpine), built
+// without dpkg-deb, rpmbuild, or abuild. A package installs the program as
+// /usr/bin/<name>.
+type Package struct {
+	Name        string    // example (by default, the program of the binary)
+	Version     string    // 1.2.0 (or v1.2.0, v1.2.0-rc.1, ...)
+	Maintainer  string    // Alice <alice@example.com>
+	Description string    // A one-line description (by default, the name)
+	License     string    // MIT
+	Homepage    string    // https://github.com/alice/example
+	Time        time.Time // The build time (and the time of the program), by default now
+}
+
+// PackageFormats is every format of a package.
+var PackageFormats = []string{"deb", "rpm", "apk"}
+
+// packageArch is the architecture of every $GOARCH, by format.
+var packageArch = map[string]map[string]string{
+	"deb": {"amd64": "amd64", "386": "i386", "arm": "armhf", "arm64": "arm64"},
+	"rpm": {"amd64": "x86_64", "386": "i386", "arm": "armv7hl", "arm64": "aarch64"},
+	"apk": {"amd64": "x86_64", "386": "x86", "arm": "armhf", "arm64": "aarch64"},
+}
+
+// PackageArch returns the architecture of <goarch> in <format> (x86_64 for
+// amd64 in an .rpm, armhf for arm in a .deb, ...), or "" if there is none.
+func PackageArch(format, goarch string) string {
+	return packageArch[format][goarch]
+}
+
+// Filename returns the name of the package (in <format>) of <binary>:
+//
+//	example_1.2.0_amd64.deb
+//	example-1.2.0-1.x86_64.rpm
+//	example-1.2.0-r0.x86_64.apk
+func (pkg *Package) Filename(format string, binary *Binary) string {
+	pkg = pkg.of(binary)
+	arch := PackageArch(format, binary.GOARCH)
+	switch format {
+	case "deb":
+		return fmt.Sprintf("%s_%s_%s.deb", pkg.Name, pkg.version(format), arch)
+	case "rpm":
+		return fmt.Sprintf("%s-%s-1.%s.rpm", pkg.Name, pkg.version(format), arch)
+	case "apk":
+		return fmt.Sprintf("%s-%s-r0.%s.apk", pkg.Name, pkg.version(format), arch)
+	}
+	return ""
+}
+
+// Build writes the package (in <format>) of <binary> (a linux binary) to <output>.
+func (pkg *Package) Build(format string, binary *Binary, output io.Writer) error {
+	if binary.GOOS != "linux" {
+		return fmt.Errorf("cannot package %s: not a linux binary", binary.Name)
+	}
+	arch := PackageArch(format, binary.GOARCH)
+	if arch == "" {
+		return fmt.Errorf("cannot package %s: unknown format (or architecture): %s", binary.Name, format)
+	}
+	content, err := ioutil.ReadFile(binary.Path)
+	if err != nil {
+		return err
+	}
+	pkg = pkg.of(binary)
+	switch format {
+	case "deb":
+		return pkg.deb(content, arch, output)
+	case "rpm":
+		return pkg.rpm(content, arch, output)
+	case "apk":
+		return pkg.apk(content, arch, output)
+	}
+	return nil
+}
+
+// of returns a copy of the package for <binary>, with the defaults filled in.
+func (pkg *Package) of(binary *Binary) *Package {
+	tmp := *pkg
+	if tmp.Name == "" {
+		tmp.Name = binary.Program
+	}
+	if index := strings.IndexByte(tmp.Description, '\n'); index != -1 {
+		tmp.Description = tmp.Description[:index]
+	}
+	if tmp.Description = strings.TrimSpace(tmp.Description); tmp.Description == "" {
+		tmp.Description = tmp.Name
+	}
+	if tmp.Time.IsZero() {
+		tmp.Time = time.Now()
+	}
+	tmp.Time = tmp.Time.UTC().Truncate(time.Second)
+	return &tmp
+}
+
+var matchApkPrerelease = regexp.MustCompile(`^(alpha|beta|pre|rc)[.-]?([0-9]*)$`)
+
+// version returns the version (without the v) in <format>, with a prerelease
+// ordered before the release: 1.2.0~rc.1 (deb, rpm), or 1.2.0_rc1 (apk).
+func (pkg *Package) version(format string) string {
+	vr, err := ParseVersion(pkg.Version)
+	if err != nil {
+		return strings.TrimPrefix(pkg.Version, "v")
+	}
+	version := fmt.Sprintf("%d.%d.%d", vr.Major, vr.Minor, vr.Patch)
+	if vr.Prerelease == "" {
+		return version
+	}
+	if format == "apk" {
+		if match := matchApkPrerelease.FindStringSubmatch(vr.Prerelease); match != nil {
+			return version + "_" + match[1] + match[2]
+		}
+		return version + "_pre"
+	}
+	return version + "~" + strings.Replace(vr.Prerelease, "-", ".", -1)
+}
+
+// _packageFile is a file (or directory, ending with /) of a package.
+type _packageFile struct {
+	name    string
+	mode    int64
+	content []byte
+}
+
+// files returns the files of the package (<content> as usr/bin/<name>), each
+// name prefixed with <prefix>.
+func (pkg *Package) files(prefix string, content []byte) []_packageFile {
+	return []_packageFile{
+		{prefix + "usr/", 0755, nil},
+		{prefix + "usr/bin/", 0755, nil},
+		{prefix + "usr/bin/" + pkg.Name, 0755, content},
+	}
+}
+
+// writeTar writes <files> to a tar (gzipped), with the PAX records of each from
+// <records> (if any). Unless <end>, the tar is written without its end (as the
+// control of an .apk).
+func writeTar(output io.Writer, files []_packageFile, mtime time.Time, records func(_packageFile) map[string]string, end bool) error {
+	compressor, _ := gzip.NewWriterLevel(output, gzip.BestCompression)
+	writer := tar.NewWriter(compressor)
+	for _, file := range files {
+		header := &tar.Header{
+			Name:    file.name,
+			Mode:    file.mode,
+			Size:    int64(len(file.content)),
+			ModTime: mtime,
+			Uname:   "root",
+			Gname:   "root",
+		}
+		if strings.HasSuffix(file.name, "/") {
+			header.Typeflag = tar.TypeDir
+		} else {
+			header.Typeflag = tar.TypeReg
+		}
+		if records != nil {
+			header.PAXRecords = records(file)
+			if header.PAXRecords != nil {
+				header.Format = tar.FormatPAX
+			}
+		}
+		err := writer.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(file.content)
+		if err != nil {
+			return err
+		}
+	}
+	var err error
+	if end {
+		err = writer.Close()
+	} else {
+		err = writer.Flush()
+	}
+	if err != nil {
+		return err
+	}
+	return compressor.Close()
+}
+
+// deb writes a .deb: an ar archive of debian-binary, control.tar.gz (control,
+// md5sums), and data.tar.gz.
+func (pkg *Package) deb(content []byte, arch string, output io.Writer) error {
+	data := bytes.NewBuffer(nil)
+	err := writeTar(data, append([]_packageFile{{"./", 0755, nil}}, pkg.files("./", content)...), pkg.Time, nil, true)
+	if err != nil {
+		return err
+	}
+
+	control := bytes.NewBuffer(nil)
+	fmt.Fprintf(control, "Package: %s\n", pkg.Name)
+	fmt.Fprintf(control, "Version: %s\n", pkg.version("deb"))
+	fmt.Fprintf(control, "Architecture: %s\n", arch)
+	if pkg.Maintainer != "" {
+		fmt.Fprintf(control, "Maintainer: %s\n", pkg.Maintainer)
+	}
+	fmt.Fprintf(control, "Installed-Size: %d\n", (len(content)+1023)/1024)
+	fmt.Fprintf(control, "Section: utils\n")
+	fmt.Fprintf(control, "Priority: optional\n")
+	if pkg.Homepage != "" {
+		fmt.Fprintf(control, "Homepage: %s\n", pkg.Homepage)
+	}
+	fmt.Fprintf(control, "Description: %s\n", pkg.Description)
+	digest := md5.Sum(content)
+	md5sums := fmt.Sprintf("%s  usr/bin/%s\n", hex.EncodeToString(digest[:]), pkg.Name)
+
+	controlTar := bytes.NewBuffer(nil)
+	err = writeTar(controlTar, []_packageFile{
+		{"./", 0755, nil},
+		{"./control", 0644, control.Bytes()},
+		{"./md5sums", 0644, []byte(md5sums)},
+	}, pkg.Time, nil, true)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(output, "!<arch>\n")
+	if err != nil {
+		return err
+	}
+	for _, member := range []struct {
+		name    string
+		content []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar.gz", controlTar.Bytes()},
+		{"data.tar.gz", data.Bytes()},
+	} {
+		header := fmt.Sprintf("%-16s%-12d%-6d%-6d%-8o%-10d`\n", member.name, pkg.Time.Unix(), 0, 0, 0100644, len(member.content))
+		_, err = io.WriteString(output, header)
+		if err != nil {
+			return err
+		}
+		_, err = output.Write(member.content)
+		if err != nil {
+			return err
+		}
+		if len(member.content)%2 == 1 {
+			_, err = output.Write([]byte{'\n'})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// An rpm header (of the signature, or of the package): an index of tags (each with
+// a type, an offset, and a count), and a store of their values.
+type _rpmHeader []_rpmTag
+
+type _rpmTag struct {
+	tag   int32
+	kind  int32
+	value interface{} // string, []string, []byte, []uint16, or []uint32
+}
+
+const (
+	rpmInt16       = 3
+	rpmInt32       = 4
+	rpmString      = 6
+	rpmBin         = 7
+	rpmStringArray = 8
+	rpmI18NString  = 9
+)
+
+func (header *_rpmHeader) add(tag, kind int32, value interface{}) {
+	*header = append(*header, _rpmTag{tag, kind, value})
+}
+
+// bytes returns the header, with every tag in the (immutable) <region>.
+func (header _rpmHeader) bytes(region int32) []byte {
+	sort.Slice(header, func(i, j int) bool {
+		return header[i].tag < header[j].tag
+	})
+	index := bytes.NewBuffer(nil)
+	store := bytes.NewBuffer(nil)
+	entry := func(tag, kind int32, offset, count int) {
+		binary.Write(index, binary.BigEndian, []int32{tag, kind, int32(offset), int32(count)})
+	}
+	for _, tag := range header {
+		var count int
+		switch tag.kind {
+		case rpmInt16:
+			for store.Len()%2 != 0 {
+				store.WriteByte(0)
+			}
+		case rpmInt32:
+			for store.Len()%4 != 0 {
+				store.WriteByte(0)
+			}
+		}
+		offset := store.Len()
+		switch value := tag.value.(type) {
+		case string:
+			count = 1
+			store.WriteString(value + "\x00")
+		case []string:
+			count = len(value)
+			for _, value := range value {
+				store.WriteString(value + "\x00")
+			}
+		case []byte:
+			count = len(value)
+			store.Write(value)
+		case []uint16:
+			count = len(value)
+			binary.Write(store, binary.BigEndian, value)
+		case []uint32:
+			count = len(value)
+			binary.Write(store, binary.BigEndian, value)
+		}
+		entry(tag.tag, tag.kind, offset, count)
+	}
+
+	// The region: its tag first in the index, pointing to a trailer (at the end of
+	// the store) that points back to the start of the index.
+	entries := len(header) + 1
+	trailer := bytes.NewBuffer(nil)
+	binary.Write(trailer, binary.BigEndian, []int32{region, rpmBin, int32(-entries * 16), 16})
+	offset := store.Len()
+	store.Write(trailer.Bytes())
+
+	output := bytes.NewBuffer(nil)
+	output.Write([]byte{0x8e, 0xad, 0xe8, 0x01, 0, 0, 0, 0})
+	binary.Write(output, binary.BigEndian, []int32{int32(entries), int32(store.Len())})
+	binary.Write(output, binary.BigEndian, []int32{region, rpmBin, int32(offset), 16})
+	output.Write(index.Bytes())
+	output.Write(store.Bytes())
+	return output.Bytes()
+}
+
+// writeCpio writes a file (or the trailer, without <content>) to a cpio (newc) archive.
+func writeCpio(output *bytes.Buffer, inode int, name string, mode int64, mtime time.Time, content []byte) {
+	nlink := 1
+	fmt.Fprintf(output, "070701%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x",
+		inode, mode, 0, 0, nlink, mtime.Unix(), len(content), 0, 0, 0, 0, len(name)+1, 0)
+	output.WriteString(name + "\x00")
+	for output.Len()%4 != 0 {
+		output.WriteByte(0)
+	}
+	output.Write(content)
+	for output.Len()%4 != 0 {
+		output.WriteByte(0)
+	}
+}
+
+// rpm writes an .rpm: the lead, the signature (header), the header, and the
+// payload (a gzipped cpio).
+func (pkg *Package) rpm(content []byte, arch string, output io.Writer) error {
+	version := pkg.version("rpm")
+
+	payload := bytes.NewBuffer(nil)
+	writeCpio(payload, 1, "./usr/bin/"+pkg.Name, 0100755, pkg.Time, content)
+	writeCpio(payload, 0, "TRAILER!!!", 0, time.Unix(0, 0), nil)
+	payloadSize := payload.Len()
+	compressed := bytes.NewBuffer(nil)
+	compressor, _ := gzip.NewWriterLevel(compressed, gzip.BestCompression)
+	compressor.Write(payload.Bytes())
+	compressor.Close()
+
+	digest := sha256.Sum256(content)
+	requires := [][2]string{
+		{"rpmlib(CompressedFileNames)", "3.0.4-1"},
+		{"rpmlib(FileDigests)", "4.6.0-1"},
+		{"rpmlib(PayloadFilesHavePrefix)", "4.0-1"},
+	}
+	if strings.Contains(version, "~") {
+		requires = append(requires, [2]string{"rpmlib(TildeInVersions)", "4.10.0-1"})
+	}
+	var requireNames, requireVersions []string
+	var requireFlags []uint32
+	for _, require := range requires {
+		requireNames = append(requireNames, require[0])
+		requireVersions = append(requireVersions, require[1])
+		requireFlags = append(requireFlags, 1<<24|0x02|0x08) // RPMLIB | LESS | EQUAL
+	}
+
+	var header _rpmHeader
+	header.add(100, rpmStringArray, []string{"C"})                // HEADERI18NTABLE
+	header.add(1000, rpmString, pkg.Name)                         // NAME
+	header.add(1001, rpmString, version)                          // VERSION
+	header.add(1002, rpmString, "1")                              // RELEASE
+	header.add(1004, rpmI18NString, pkg.Description)              // SUMMARY
+	header.add(1005, rpmI18NString, pkg.Description)              // DESCRIPTION
+	header.add(1006, rpmInt32, []uint32{uint32(pkg.Time.Unix())}) // BUILDTIME
+	header.add(1007, rpmString, "gphr")                           // BUILDHOST
+	header.add(1009, rpmInt32, []uint32{uint32(len(content))})    // SIZE
+	if pkg.License != "" {
+		header.add(1014, rpmString, pkg.License) // LICENSE
+	}
+	if pkg.Maintainer != "" {
+		header.add(1015, rpmString, pkg.Maintainer) // PACKAGER
+	}
+	header.add(1016, rpmI18NString, "Unspecified") // GROUP
+	if pkg.Homepage != "" {
+		header.add(1020, rpmString, pkg.Homepage) // URL
+	}
+	header.add(1021, rpmString, "linux")                                           // OS
+	header.add(1022, rpmString, arch)                                              // ARCH
+	header.add(1028, rpmInt32, []uint32{uint32(len(content))})                     // FILESIZES
+	header.add(1030, rpmInt16, []uint16{0100755})                                  // FILEMODES
+	header.add(1033, rpmInt16, []uint16{0})                                        // FILERDEVS
+	header.add(1034, rpmInt32, []uint32{uint32(pkg.Time.Unix())})                  // FILEMTIMES
+	header.add(1035, rpmStringArray, []string{hex.EncodeToString(digest[:])})      // FILEDIGESTS
+	header.add(1036, rpmStringArray, []string{""})                                 // FILELINKTOS
+	header.add(1037, rpmInt32, []uint32{0})                                        // FILEFLAGS
+	header.add(1039, rpmStringArray, []string{"root"})                             // FILEUSERNAME
+	header.add(1040, rpmStringArray, []string{"root"})                             // FILEGROUPNAME
+	header.add(1044, rpmString, fmt.Sprintf("%s-%s-1.src.rpm", pkg.Name, version)) // SOURCERPM (a binary package)
+	header.add(1047, rpmStringArray, []string{pkg.Name})                           // PROVIDENAME
+	header.add(1048, rpmInt32, requireFlags)                                       // REQUIREFLAGS
+	header.add(1049, rpmStringArray, requireNames)                                 // REQUIRENAME
+	header.add(1050, rpmStringArray, requireVersions)                              // REQUIREVERSION
+	header.add(1095, rpmInt32, []uint32{1})                                        // FILEDEVICES
+	header.add(1096, rpmInt32, []uint32{1})                                        // FILEINODES
+	header.add(1097, rpmStringArray, []string{""})                                 // FILELANGS
+	header.add(1112, rpmInt32, []uint32{0x08})                                     // PROVIDEFLAGS (EQUAL)
+	header.add(1113, rpmStringArray, []string{version + "-1"})                     // PROVIDEVERSION
+	header.add(1116, rpmInt32, []uint32{0})                                        // DIRINDEXES
+	header.add(1117, rpmStringArray, []string{pkg.Name})                           // BASENAMES
+	header.add(1118, rpmStringArray, []string{"/usr/bin/"})                        // DIRNAMES
+	header.add(1124, rpmString, "cpio")                                            // PAYLOADFORMAT
+	header.add(1125, rpmString, "gzip")                                            // PAYLOADCOMPRESSOR
+	header.add(1126, rpmString, "9")                                               // PAYLOADFLAGS
+	header.add(5011, rpmInt32, []uint32{8})                                        // FILEDIGESTALGO (SHA256)
+	head := header.bytes(63)                                                       // HEADERIMMUTABLE
+
+	md5sum := md5.New()
+	md5sum.Write(head)
+	md5sum.Write(compressed.Bytes())
+	sha1sum := sha1.Sum(head)
+	sha256sum := sha256.Sum256(head)
+	var signature _rpmHeader
+	signature.add(269, rpmString, hex.EncodeToString(sha1sum[:]))                 // SHA1
+	signature.add(273, rpmString, hex.EncodeToString(sha256sum[:]))               // SHA256
+	signature.add(1000, rpmInt32, []uint32{uint32(len(head) + compressed.Len())}) // SIZE
+	signature.add(1004, rpmBin, md5sum.Sum(nil))                                  // MD5
+	signature.add(1007, rpmInt32, []uint32{uint32(payloadSize)})                  // PAYLOADSIZE
+	sig := signature.bytes(62)                                                    // HEADERSIGNATURES
+	for len(sig)%8 != 0 {
+		sig = append(sig, 0)
+	}
+
+	lead := make([]byte, 96)
+	copy(lead, []byte{0xed, 0xab, 0xee, 0xdb, 3, 0}) // The magic, and version 3.0
+	archnum := uint16(1)
+	if strings.HasPrefix(arch, "arm") {
+		archnum = 12
+	} else if arch == "aarch64" {
+		archnum = 19
+	}
+	binary.BigEndian.PutUint16(lead[6:], 0) // A binary (not a source) package
+	binary.BigEndian.PutUint16(lead[8:], archnum)
+	copy(lead[10:75], fmt.Sprintf("%s-%s-1", pkg.Name, version))
+	binary.BigEndian.PutUint16(lead[76:], 1) // Linux
+	binary.BigEndian.PutUint16(lead[78:], 5) // A signature (header)
+
+	for _, part := range [][]byte{lead, sig, head, compressed.Bytes()} {
+		_, err := output.Write(part)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apk writes an .apk: a gzipped control tar (.PKGINFO, without its end) followed
+// by a gzipped data tar (with the SHA1 of every file). The package is not signed,
+// so installing it needs apk add --allow-untrusted.
+func (pkg *Package) apk(content []byte, arch string, output io.Writer) error {
+	data := bytes.NewBuffer(nil)
+	err := writeTar(data, pkg.files("", content), pkg.Time, func(file _packageFile) map[string]string {
+		if strings.HasSuffix(file.name, "/") {
+			return nil
+		}
+		digest := sha1.Sum(file.content)
+		return map[string]string{"APK-TOOLS.checksum.SHA1": hex.EncodeToString(digest[:])}
+	}, true)
+	if err != nil {
+		return err
+	}
+	datahash := sha256.Sum256(data.Bytes())
+
+	info := bytes.NewBuffer(nil)
+	fmt.Fprintf(info, "# Generated by gphr\n")
+	fmt.Fprintf(info, "pkgname = %s\n", pkg.Name)
+	fmt.Fprintf(info, "pkgver = %s-r0\n", pkg.version("apk"))
+	fmt.Fprintf(info, "pkgdesc = %s\n", pkg.Description)
+	if pkg.Homepage != "" {
+		fmt.Fprintf(info, "url = %s\n", pkg.Homepage)
+	}
+	fmt.Fprintf(info, "builddate = %d\n", pkg.Time.Unix())
+	if pkg.Maintainer != "" {
+		fmt.Fprintf(info, "packager = %s\n", pkg.Maintainer)
+		fmt.Fprintf(info, "maintainer = %s\n", pkg.Maintainer)
+	}
+	fmt.Fprintf(info, "size = %d\n", len(content))
+	fmt.Fprintf(info, "arch = %s\n", arch)
+	fmt.Fprintf(info, "origin = %s\n", pkg.Name)
+	if pkg.License != "" {
+		fmt.Fprintf(info, "license = %s\n", pkg.License)
+	}
+	fmt.Fprintf(info, "datahash = %s\n", hex.EncodeToString(datahash[:]))
+
+	err = writeTar(output, []_packageFile{{".PKGINFO", 0644, info.Bytes()}}, pkg.Time, nil, false)
+	if err != nil {
+		return err
+	}
+	_, err = output.Write(data.Bytes())
+	return err
+}
@@ -0,0 +1,154 @@
+package gphr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// A Provider is where releases are kept (GitHub, GitLab, Gitea, ...).
+//
+// Whatever the provider, releases and assets are described in terms of GitHub
+// (github.RepositoryRelease and github.ReleaseAsset), since that is what gphr
+// started with.
+type Provider interface {
+	// Location returns the host/owner/repository (e.g. github.com/alice/example).
+	Location() string
+
+	// GetReleases returns every release (along with its assets), newest first.
+	GetReleases() ([]*Release, error)
+
+	// CreateRelease creates <release> (a TagName, Name, and Body), filling in the
+	// rest (ID, ...) afterwards.
+	CreateRelease(release *Release) error
+
+	// EditRelease updates the Name and Body of <release>.
+	EditRelease(release *Release) error
+
+	// GetReleaseAssets returns the assets of <release>.
+	GetReleaseAssets(release github.RepositoryRelease) ([]github.ReleaseAsset, error)
+
+	// UploadAsset uploads <file> as asset <name> of <release>.
+	UploadAsset(release *Release, name string, file *os.File) (*github.ReleaseAsset, error)
+
+	// DeleteAsset deletes <asset> of <release>. An asset that does not exist
+	// (anymore) is not an error.
+	DeleteAsset(release *Release, asset github.ReleaseAsset) error
+
+	// ResolveTag returns the commit of <tag>, or ErrTagNotFound if there is no
+	// such tag.
+	ResolveTag(tag string) (string, error)
+
+	// DownloadURL returns the (public) URL to download asset <name> of release <tag>.
+	DownloadURL(tag, name string) string
+
+	// WithContext returns a copy of the provider that makes every request with
+	// <ctx>: to set a deadline, or to cancel (e.g. a large upload). Should <ctx>
+	// be done before an upload is, what the provider has of the asset is left
+	// behind (see Upload).
+	WithContext(ctx context.Context) Provider
+}
+
+// A RepositoryLister is a provider that can list the repositories of its
+// owner (a user, organization, or group), to list the releases of each.
+type RepositoryLister interface {
+	// GetRepositories returns the (names of the) repositories of the owner.
+	GetRepositories() ([]string, error)
+}
+
+// A Linker is a provider with a (web) page for each release, and for comparing
+// two tags, to link to (e.g. from a changelog). A Store has neither.
+type Linker interface {
+	// ReleaseURL returns the URL of the page of release <tag>.
+	ReleaseURL(tag string) string
+
+	// CompareURL returns the URL of the page comparing <base> with <head>.
+	CompareURL(base, head string) string
+}
+
+// A Downloader is a provider that can download an asset itself (rather than
+// from its DownloadURL, which may not be public).
+type Downloader interface {
+	// Download returns the content of <asset> (of <release>).
+	Download(release *Release, asset github.ReleaseAsset) (io.ReadCloser, error)
+}
+
+// A FileWriter is a provider that can commit a file to the repository (to its
+// default branch), e.g. a formula to a Homebrew tap.
+type FileWriter interface {
+	// WriteFile commits <content> to <path> with <message>, creating the file or
+	// updating it (unless it is already <content>). It returns whether there was
+	// a commit.
+	WriteFile(path string, content []byte, message string) (bool, error)
+}
+
+// Download returns the content of <asset> (of <release>), from <provider> if it
+// is a Downloader, otherwise from its DownloadURL (with <ctx>).
+func Download(ctx context.Context, provider Provider, release *Release, asset github.ReleaseAsset) (io.ReadCloser, error) {
+	if downloader, ok := provider.(Downloader); ok {
+		return downloader.Download(release, asset)
+	}
+	url_ := provider.DownloadURL(stringValue(release.TagName), stringValue(asset.Name))
+	request, err := http.NewRequestWithContext(ctx, "GET", url_, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != 200 {
+		response.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", url_, response.Status)
+	}
+	return response.Body, nil
+}
+
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	ProviderGitea  = "gitea"
+)
+
+// ProviderKind guesses the kind of provider at <host>: gitlab.com and gitlab.*
+// are GitLab; codeberg.org, gitea.*, and forgejo.* are Gitea (or Forgejo); anything
+// else is GitHub (or GitHub Enterprise).
+func ProviderKind(host string) string {
+	host = strings.ToLower(host)
+	if index := strings.Index(host, ":"); index != -1 {
+		host = host[:index]
+	}
+	switch {
+	case host == "gitlab.com" || strings.HasPrefix(host, "gitlab."):
+		return ProviderGitLab
+	case host == "codeberg.org" || strings.HasPrefix(host, "gitea.") || strings.HasPrefix(host, "forgejo."):
+		return ProviderGitea
+	}
+	return ProviderGitHub
+}
+
+// NewProvider returns the <kind> (or, if empty, the ProviderKind of <host>)
+// provider for <owner>/<repository> at <host>. <apiURL> and <uploadURL> are
+// optional (see NewGitHubEnterprise).
+func NewProvider(kind, host, apiURL, uploadURL, owner, repository string, client *http.Client, token string) (Provider, error) {
+	if kind == "" {
+		kind = ProviderKind(host)
+	}
+	switch kind {
+	case ProviderGitHub:
+		if host == "github.com" && apiURL == "" {
+			return NewGitHub(owner, repository, client, token), nil
+		}
+		return NewGitHubEnterprise(host, apiURL, uploadURL, owner, repository, client, token)
+	case ProviderGitLab:
+		return NewGitLab(host, apiURL, owner, repository, client, token)
+	case ProviderGitea:
+		return NewGitea(host, apiURL, owner, repository, client, token)
+	}
+	return nil, fmt.Errorf("invalid provider: %s (not github, gitlab, or gitea)", kind)
+}
@@ -0,0 +1,307 @@
+package gphr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// A RateLimitError is a (primary) rate limit that is exhausted: there is no
+// point in trying again before Reset.
+type RateLimitError struct {
+	Limit int
+	Reset time.Time
+}
+
+func (err *RateLimitError) Error() string {
+	message := fmt.Sprintf("API rate limit exhausted (%d requests per hour), resets at %s (in %s)",
+		err.Limit, err.Reset.Local().Format("15:04:05"), time.Until(err.Reset).Round(time.Second))
+	if err.Limit <= 60 {
+		message += ", use a token for a higher limit"
+	}
+	return message
+}
+
+// RetryTransport is an http.RoundTripper that retries a request that failed
+// along the way:
+//
+//     A reset connection (or the like), or a 5xx (idempotent requests only)
+//     A secondary rate limit (a 429, or a 403 with Retry-After), which rejects
+//     a request outright
+//
+// It waits as long as Retry-After (or X-RateLimit-Reset) says, or backs off
+// (exponentially, with jitter) otherwise. A request with a body that cannot be
+// had again (an upload) is never retried (see Upload).
+//
+// An exhausted (primary) rate limit is a RateLimitError at once, instead.
+type RetryTransport struct {
+	Transport http.RoundTripper // By default, http.DefaultTransport
+	Retries   int
+	MaxWait   time.Duration // Give up (instead of waiting) if a retry would be any later
+
+	// Log, if not nil, is called before every retry.
+	Log func(format string, arguments ...interface{})
+}
+
+// NewRetryTransport returns a RetryTransport (over <transport>) that retries
+// up to 4 times, waiting up to 2 minutes each.
+func NewRetryTransport(transport http.RoundTripper) *RetryTransport {
+	return &RetryTransport{
+		Transport: transport,
+		Retries:   4,
+		MaxWait:   2 * time.Minute,
+	}
+}
+
+// Backoff returns how long to wait before retry <attempt> (0, 1, ...): a
+// second, doubled every attempt, with (up to half of it) jitter.
+func Backoff(attempt int) time.Duration {
+	if attempt > 10 {
+		attempt = 10
+	}
+	wait := time.Second << uint(attempt)
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+func (transport *RetryTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	roundTripper := transport.Transport
+	if roundTripper == nil {
+		roundTripper = http.DefaultTransport
+	}
+
+	// A request can be sent again if it has no body, or one that can be had again
+	replayable := request.Body == nil || request.Body == http.NoBody || request.GetBody != nil
+
+	try := request
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && request.GetBody != nil {
+			body, err := request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			try = new(http.Request)
+			*try = *request
+			try.Body = body
+		}
+
+		response, err := roundTripper.RoundTrip(try)
+		if err == nil {
+			if err := rateLimited(response); err != nil {
+				response.Body.Close()
+				return nil, err
+			}
+		}
+
+		if request.Context().Err() != nil {
+			return response, err // Cancelled (or past the deadline)
+		}
+
+		wait, retry := transport.retry(request, response, err, attempt)
+		if !retry || !replayable || attempt >= transport.Retries || wait > transport.MaxWait {
+			return response, err
+		}
+
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = response.Status
+			io.Copy(ioutil.Discard, io.LimitReader(response.Body, 1<<16))
+			response.Body.Close()
+		}
+		if transport.Log != nil {
+			transport.Log("%s %s: %s, retrying in %s", request.Method, request.URL, reason, wait.Round(time.Millisecond))
+		}
+		err = sleep(request.Context(), wait)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retry returns whether (and after how long) to retry <request>, given what came of it.
+func (transport *RetryTransport) retry(request *http.Request, response *http.Response, err error, attempt int) (time.Duration, bool) {
+	if err != nil {
+		return Backoff(attempt), idempotent(request.Method) && temporary(err)
+	}
+
+	switch status := response.StatusCode; {
+	case status == 429 || status == 403 && secondaryRateLimited(response):
+		if wait, ok := retryAfter(response); ok {
+			return wait, true
+		}
+		return time.Minute, true // As GitHub recommends, without a Retry-After
+	case status == 500 || status == 502 || status == 503 || status == 504:
+		if wait, ok := retryAfter(response); ok {
+			return wait, idempotent(request.Method)
+		}
+		return Backoff(attempt), idempotent(request.Method)
+	}
+	return 0, false
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// temporary returns whether <err> (from a round trip) is a reset connection
+// (or the like), which may well go away on its own.
+func temporary(err error) bool {
+	for _, tmp := range []error{io.EOF, io.ErrUnexpectedEOF, syscall.ECONNRESET, syscall.ECONNREFUSED, syscall.EPIPE} {
+		if errors.Is(err, tmp) {
+			return true
+		}
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// rateLimited returns a RateLimitError if <response> is for an exhausted
+// (primary) rate limit, or nil.
+func rateLimited(response *http.Response) error {
+	if response.StatusCode != 403 && response.StatusCode != 429 {
+		return nil
+	}
+	if response.Header.Get("X-RateLimit-Remaining") != "0" || response.Header.Get("Retry-After") != "" {
+		return nil
+	}
+	err := &RateLimitError{}
+	err.Limit, _ = strconv.Atoi(response.Header.Get("X-RateLimit-Limit"))
+	if reset, tmp := strconv.ParseInt(response.Header.Get("X-RateLimit-Reset"), 10, 64); tmp == nil {
+		err.Reset = time.Unix(reset, 0)
+	}
+	return err
+}
+
+// secondaryRateLimited returns whether <response> (a 403) is for a secondary
+// rate limit (rather than, say, a lack of permission).
+func secondaryRateLimited(response *http.Response) bool {
+	if response.Header.Get("Retry-After") != "" {
+		return true
+	}
+	content, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1<<16))
+	response.Body.Close()
+	response.Body = ioutil.NopCloser(bytes.NewReader(content))
+	message := strings.ToLower(string(content))
+	return strings.Contains(message, "secondary rate limit") || strings.Contains(message, "abuse")
+}
+
+// retryAfter returns how long <response> says to wait: Retry-After (in seconds,
+// or a date), or until X-RateLimit-Reset.
+func retryAfter(response *http.Response) (time.Duration, bool) {
+	if value := response.Header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return time.Until(date), true
+		}
+	}
+	if value := response.Header.Get("X-RateLimit-Reset"); value != "" {
+		if reset, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.Until(time.Unix(reset, 0)), true
+		}
+	}
+	return 0, false
+}
+
+// Retryable returns whether <err> (from a provider) is worth trying again:
+// a reset connection (or the like), a 5xx, or a 429.
+func Retryable(err error) bool {
+	var response *github.ErrorResponse
+	if errors.As(err, &response) && response.Response != nil {
+		return response.Response.StatusCode >= 500 || response.Response.StatusCode == 429
+	}
+	var apiError *APIError
+	if errors.As(err, &apiError) {
+		return apiError.StatusCode >= 500 || apiError.StatusCode == 429
+	}
+	return temporary(err)
+}
+
+// Upload uploads <file> as <name> to <release> (with <ctx>), trying again (up
+// to <retries> times) if the upload fails along the way. Before trying again,
+// whatever (partial) asset of the same name the failed upload left behind is
+// deleted, as it is if <ctx> is done (cancelled) before the upload is.
+func Upload(ctx context.Context, provider Provider, release *Release, name string, file *os.File, retries int, log func(string, ...interface{})) (*github.ReleaseAsset, error) {
+	provider = provider.WithContext(ctx)
+	for attempt := 0; ; attempt++ {
+		asset, err := provider.UploadAsset(release, name, file)
+		if err == nil {
+			return asset, nil
+		}
+		if ctx.Err() != nil {
+			return nil, cleanUpload(ctx.Err(), provider, release, name)
+		}
+		if attempt >= retries || !Retryable(err) {
+			return nil, err
+		}
+
+		wait := Backoff(attempt)
+		if log != nil {
+			log("upload %s: %v, retrying in %s", name, err, wait.Round(time.Millisecond))
+		}
+		err = sleep(ctx, wait)
+		if err != nil {
+			return nil, cleanUpload(err, provider, release, name)
+		}
+
+		err = deleteAssets(provider, release, name)
+		if err != nil {
+			return nil, err
+		}
+
+		// The client closes a file once it is sent, so open it again
+		tmp, err := os.Open(file.Name())
+		if err != nil {
+			return nil, err
+		}
+		defer tmp.Close()
+		file = tmp
+	}
+}
+
+// cleanUpload deletes what a cancelled upload (of asset <name>) left behind,
+// returning <err> (the error of the context).
+func cleanUpload(err error, provider Provider, release *Release, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if tmp := deleteAssets(provider.WithContext(ctx), release, name); tmp != nil {
+		return fmt.Errorf("%w (and the partial asset %s was not deleted: %v)", err, name, tmp)
+	}
+	return err
+}
+
+// deleteAssets deletes every asset <name> of <release>.
+func deleteAssets(provider Provider, release *Release, name string) error {
+	assets, err := provider.GetReleaseAssets(release.RepositoryRelease)
+	if err != nil {
+		return err
+	}
+	for _, asset := range assets {
+		if stringValue(asset.Name) == name {
+			err := provider.DeleteAsset(release, asset)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package gphr
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// _s3 is a store in an S3-compatible bucket (Amazon S3, MinIO, ...), addressed
+// path-style (<endpoint>/<bucket>/<prefix>/<key>) and signed with AWS Signature
+// Version 4.
+type _s3 struct {
+	endpoint     *url.URL // https://s3.us-east-1.amazonaws.com
+	bucket       string
+	prefix       string
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	client       *http.Client
+}
+
+// newS3 returns the store for <bucket>/<prefix>, configured as the AWS tools are:
+//
+//     AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN (optional)
+//     AWS_REGION or AWS_DEFAULT_REGION (by default, us-east-1)
+//     AWS_ENDPOINT_URL (e.g. http://localhost:9000 for MinIO; by default, Amazon S3)
+//
+// Without credentials, requests are not signed (for a public bucket).
+func newS3(bucket, prefix string, client *http.Client) (*_s3, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	endpoint := os.Getenv("AWS_ENDPOINT_URL")
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	url_, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil || url_.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint: %s", endpoint)
+	}
+
+	return &_s3{
+		endpoint:     url_,
+		bucket:       bucket,
+		prefix:       prefix,
+		region:       region,
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		client:       client,
+	}, nil
+}
+
+func (s3 *_s3) location() string {
+	if s3.prefix == "" {
+		return "s3://" + s3.bucket
+	}
+	return "s3://" + s3.bucket + "/" + s3.prefix
+}
+
+func (s3 *_s3) url(key string) string {
+	path := "/" + s3.bucket + "/" + key
+	if s3.prefix != "" {
+		path = "/" + s3.bucket + "/" + s3.prefix + "/" + key
+	}
+	return s3.endpoint.String() + s3Escape(path)
+}
+
+func (s3 *_s3) do(method, key string, body io.Reader, size int64) (*http.Response, error) {
+	request, err := http.NewRequest(method, s3.url(key), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.ContentLength = size
+		request.Header.Set("Content-Type", "application/octet-stream")
+	}
+	s3.sign(request, time.Now())
+
+	response, err := s3.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		defer response.Body.Close()
+		content, _ := ioutil.ReadAll(io.LimitReader(response.Body, 4096))
+		return response, &APIError{
+			Method:     method,
+			URL:        request.URL.String(),
+			StatusCode: response.StatusCode,
+			Message:    s3Message(content),
+		}
+	}
+	return response, nil
+}
+
+func (s3 *_s3) get(key string) (io.ReadCloser, error) {
+	response, err := s3.do("GET", key, nil, 0)
+	if err != nil {
+		if isNotFound(response) {
+			return nil, os.ErrNotExist
+		}
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+func (s3 *_s3) put(key string, body io.Reader, size int64) error {
+	response, err := s3.do("PUT", key, body, size)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+func (s3 *_s3) delete(key string) error {
+	response, err := s3.do("DELETE", key, nil, 0)
+	if err != nil {
+		if isNotFound(response) {
+			return nil
+		}
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+// sign signs <request> (as of <now>) with AWS Signature Version 4, covering the
+// host and every header already set. The payload is not signed (it may be a
+// binary streamed from disk).
+//
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (s3 *_s3) sign(request *http.Request, now time.Time) {
+	if s3.accessKey == "" {
+		return
+	}
+
+	now = now.UTC()
+	date := now.Format("20060102")
+	request.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	if request.Header.Get("X-Amz-Content-Sha256") == "" {
+		request.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	}
+	if s3.sessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", s3.sessionToken)
+	}
+
+	headers := map[string]string{
+		"host": request.URL.Host,
+	}
+	for key, values := range request.Header {
+		headers[strings.ToLower(key)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := request.URL.Query()
+	var parameters []string
+	for key, values := range query {
+		for _, value := range values {
+			escape := func(input string) string {
+				return strings.Replace(s3Escape(input), "/", "%2F", -1)
+			}
+			parameters = append(parameters, escape(key)+"="+escape(value))
+		}
+	}
+	sort.Strings(parameters)
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		s3Escape(request.URL.Path),
+		strings.Join(parameters, "&"),
+		canonicalHeaders,
+		signedHeaders,
+		request.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	scope := date + "/" + s3.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		request.Header.Get("X-Amz-Date"),
+		scope,
+		hex.EncodeToString(s3Hash([]byte(canonicalRequest))),
+	}, "\n")
+
+	key := []byte("AWS4" + s3.secretKey)
+	for _, value := range []string{date, s3.region, "s3", "aws4_request"} {
+		key = s3HMAC(key, value)
+	}
+	signature := hex.EncodeToString(s3HMAC(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s", s3.accessKey, scope, signedHeaders, signature))
+}
+
+func s3Hash(input []byte) []byte {
+	hash := sha256.Sum256(input)
+	return hash[:]
+}
+
+func s3HMAC(key []byte, input string) []byte {
+	hash := hmac.New(sha256.New, key)
+	hash.Write([]byte(input))
+	return hash.Sum(nil)
+}
+
+// s3Escape escapes everything but the unreserved characters (A-Z, a-z, 0-9, -, ., _, ~) and /.
+func s3Escape(input string) string {
+	output := make([]byte, 0, len(input))
+	for _, chr := range []byte(input) {
+		switch {
+		case 'A' <= chr && chr <= 'Z', 'a' <= chr && chr <= 'z', '0' <= chr && chr <= '9',
+			chr == '-', chr == '.', chr == '_', chr == '~', chr == '/':
+			output = append(output, chr)
+		default:
+			output = append(output, fmt.Sprintf("%%%02X", chr)...)
+		}
+	}
+	return string(output)
+}
+
+// s3Message returns the <Message> of an S3 error response (or the response itself).
+func s3Message(content []byte) string {
+	message := strings.TrimSpace(string(content))
+	if start := strings.Index(message, "<Message>"); start != -1 {
+		if end := strings.Index(message, "</Message>"); end > start {
+			return message[start+len("<Message>") : end]
+		}
+	}
+	return message
+}
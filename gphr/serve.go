@@ -0,0 +1,302 @@
+package gphr
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Redirector is an http.Handler that serves the asset (of a program) for the
+// platform of the client:
+//
+//     /<owner>/<repository>[/<program>][@<version>]
+//
+// The program is, by default, the repository, and the version the newest
+// release (with an asset for the platform). The platform is given with ?os=
+// and ?arch= (linux, amd64, ...), or otherwise guessed from the User-Agent
+// (PowerShell, a browser, or curl/wget with a hint, e.g. "curl/8.0 (linux; amd64)").
+//
+// The asset is served with a redirect to the DownloadURL of the provider, or,
+// if Proxy is true, by the Redirector itself (with the token of the provider, so
+// that the repository need not be public).
+//
+// The releases of a repository are cached (for TTL), so that not every request
+// is a request of the API. So is a failure (e.g. a repository that does not
+// exist), and an entry is evicted once expired.
+type Redirector struct {
+	// Provider returns the provider for <owner>/<repository>.
+	Provider func(owner, repository string) (Provider, error)
+
+	// Owners, if not empty, are the only owners served (anything else is a 404).
+	Owners []string
+
+	Proxy bool
+	TTL   time.Duration
+
+	// Log, if not nil, is called for every request served.
+	Log func(format string, arguments ...interface{})
+
+	mutex sync.Mutex
+	cache map[string]*_redirectorEntry // By owner/repository
+}
+
+type _redirectorEntry struct {
+	mutex    sync.Mutex // Held while the releases are fetched (once, for every request waiting)
+	provider Provider
+	releases []*Release
+	err      error
+	expires  time.Time
+}
+
+// NewRedirector returns a Redirector that gets the provider (of an owner and
+// repository) from <provider>, caching releases for 5 minutes.
+func NewRedirector(provider func(owner, repository string) (Provider, error)) *Redirector {
+	return &Redirector{
+		Provider: provider,
+		TTL:      5 * time.Minute,
+	}
+}
+
+// releases returns the provider (and releases) of <owner>/<repository>, or the
+// failure, from the cache, if not expired.
+func (redirector *Redirector) releases(owner, repository string) (Provider, []*Release, error) {
+	key := owner + "/" + repository
+
+	redirector.mutex.Lock()
+	if redirector.cache == nil {
+		redirector.cache = map[string]*_redirectorEntry{}
+	}
+	entry := redirector.cache[key]
+	if entry == nil {
+		// Evict what has expired (and is not being fetched), before adding another
+		now := time.Now()
+		for key, entry := range redirector.cache {
+			if entry.mutex.TryLock() {
+				if !now.Before(entry.expires) {
+					delete(redirector.cache, key)
+				}
+				entry.mutex.Unlock()
+			}
+		}
+		entry = &_redirectorEntry{}
+		redirector.cache[key] = entry
+	}
+	redirector.mutex.Unlock()
+
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+
+	if time.Now().Before(entry.expires) {
+		return entry.provider, entry.releases, entry.err
+	}
+
+	provider, err := redirector.Provider(owner, repository)
+	var releases []*Release
+	if err == nil {
+		releases, err = provider.GetReleases()
+	}
+	if err != nil {
+		provider = nil
+		if Kind(err) == ErrRateLimited {
+			return nil, nil, err // Not of the repository, so not cached
+		}
+	}
+	entry.provider, entry.releases, entry.err, entry.expires = provider, releases, err, time.Now().Add(redirector.TTL)
+	return provider, releases, err
+}
+
+// Platform returns the platform ($GOOS, $GOARCH) of the client of <request>:
+// from ?os= and ?arch= (or ?goos= and ?goarch=), or else the User-Agent (see
+// PlatformOf). Either is empty if unknown, although $GOARCH is amd64 if only
+// $GOOS is known.
+func Platform(request *http.Request) (string, string) {
+	query := request.URL.Query()
+	goos, goarch := query.Get("os"), query.Get("arch")
+	if goos == "" {
+		goos = query.Get("goos")
+	}
+	if goarch == "" {
+		goarch = query.Get("goarch")
+	}
+	if goos == "" || goarch == "" {
+		os_, arch := PlatformOf(request.Header.Get("User-Agent"))
+		if goos == "" {
+			goos = os_
+		}
+		if goarch == "" {
+			goarch = arch
+		}
+	}
+	if goos != "" && goarch == "" {
+		goarch = "amd64"
+	}
+	return goos, goarch
+}
+
+// PlatformOf returns the platform ($GOOS, $GOARCH) in <userAgent>, as far as it
+// can be told: "Windows NT 10.0; Win64; x64", "Macintosh; Intel Mac OS X",
+// "X11; Linux x86_64", ... Either is empty if unknown (as for a plain curl/8.0).
+func PlatformOf(userAgent string) (string, string) {
+	agent := strings.ToLower(userAgent)
+
+	goos := ""
+	for _, tmp := range []struct {
+		goos    string
+		markers []string
+	}{
+		{"windows", []string{"windows", "win64", "win32", "powershell"}},
+		{"darwin", []string{"mac os x", "macintosh", "darwin", "macos"}},
+		{"freebsd", []string{"freebsd"}},
+		{"openbsd", []string{"openbsd"}},
+		{"netbsd", []string{"netbsd"}},
+		{"dragonfly", []string{"dragonfly"}},
+		{"linux", []string{"linux", "x11"}},
+	} {
+		for _, marker := range tmp.markers {
+			if strings.Contains(agent, marker) {
+				goos = tmp.goos
+				break
+			}
+		}
+		if goos != "" {
+			break
+		}
+	}
+
+	goarch := ""
+	for _, tmp := range []struct {
+		goarch  string
+		markers []string
+	}{
+		{"amd64", []string{"x86_64", "x64", "amd64", "win64", "wow64"}},
+		{"arm64", []string{"aarch64", "arm64"}},
+		{"arm", []string{"armv6", "armv7", "armhf", "arm;", "arm)"}},
+		{"386", []string{"i386", "i686", "x86"}},
+	} {
+		for _, marker := range tmp.markers {
+			if strings.Contains(agent, marker) {
+				goarch = tmp.goarch
+				break
+			}
+		}
+		if goarch != "" {
+			break
+		}
+	}
+
+	return goos, goarch
+}
+
+func (redirector *Redirector) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	status, detail := redirector.serve(response, request)
+	if redirector.Log != nil {
+		redirector.Log("%s %s %d %s", request.Method, request.URL.RequestURI(), status, detail)
+	}
+}
+
+// serve serves <request>, returning the status (and, for the log, a detail).
+func (redirector *Redirector) serve(response http.ResponseWriter, request *http.Request) (int, string) {
+	fail := func(status int, format string, arguments ...interface{}) (int, string) {
+		message := fmt.Sprintf(format, arguments...)
+		http.Error(response, message, status)
+		return status, message
+	}
+
+	if request.Method != "GET" && request.Method != "HEAD" {
+		return fail(405, "method not allowed")
+	}
+
+	// /<owner>/<repository>[/<program>][@<version>]
+	path, version := strings.Trim(request.URL.Path, "/"), ""
+	if index := strings.LastIndex(path, "@"); index != -1 {
+		path, version = path[:index], path[index+1:]
+		if version == "latest" {
+			version = ""
+		}
+	}
+	match := strings.Split(path, "/")
+	if len(match) < 2 || len(match) > 3 || match[0] == "" || match[1] == "" {
+		return fail(404, "not found: /<owner>/<repository>[/<program>][@<version>]")
+	}
+	owner, repository, program := match[0], match[1], match[1]
+	if len(match) == 3 && match[2] != "" {
+		program = match[2]
+	}
+	if len(redirector.Owners) > 0 {
+		allowed := false
+		for _, tmp := range redirector.Owners {
+			if strings.EqualFold(tmp, owner) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fail(404, "not found: %s", owner)
+		}
+	}
+
+	provider, releases, err := redirector.releases(owner, repository)
+	if err != nil {
+		if Kind(err) == ErrRateLimited {
+			return fail(503, "%v", err)
+		}
+		return fail(502, "%s/%s: %v", owner, repository, err)
+	}
+
+	goos, goarch := Platform(request)
+	if goos == "" {
+		// Cannot tell, so say what there is
+		var platforms []string
+		seen := map[string]bool{}
+		for _, release := range releases {
+			for _, asset := range release.Assets {
+				binary := NewBinary(stringValue(asset.Name))
+				if binary.Program == program && !seen[binary.GOOS+"/"+binary.GOARCH] {
+					seen[binary.GOOS+"/"+binary.GOARCH] = true
+					platforms = append(platforms, "?os="+binary.GOOS+"&arch="+binary.GOARCH)
+				}
+			}
+		}
+		sort.Strings(platforms)
+		return fail(400, "cannot tell the platform (from the User-Agent), use any of: %s", strings.Join(platforms, " "))
+	}
+
+	binary := &Binary{Program: program, GOOS: goos, GOARCH: goarch}
+	release, asset := FindAsset(releases, binary, version)
+	if asset == nil {
+		if version != "" {
+			return fail(404, "not found: %s for %s/%s (of %s)", program, goos, goarch, version)
+		}
+		return fail(404, "not found: %s for %s/%s", program, goos, goarch)
+	}
+	tag, name := stringValue(release.TagName), stringValue(asset.Name)
+	url_ := provider.DownloadURL(tag, name)
+
+	if !redirector.Proxy {
+		http.Redirect(response, request, url_, http.StatusFound)
+		return http.StatusFound, url_
+	}
+
+	body, err := Download(request.Context(), provider, release, *asset)
+	if err != nil {
+		return fail(502, "%s %s: %v", tag, name, err)
+	}
+	defer body.Close()
+
+	header := response.Header()
+	header.Set("Content-Type", "application/octet-stream")
+	header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	if asset.Size != nil {
+		header.Set("Content-Length", strconv.Itoa(*asset.Size))
+	}
+	response.WriteHeader(200)
+	if request.Method == "GET" {
+		io.Copy(response, body)
+	}
+	return 200, tag + " " + name
+}
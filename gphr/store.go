@@ -0,0 +1,419 @@
+package gphr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// Store keeps releases in a store (a local directory or an S3-compatible bucket),
+// without GitHub (or any other forge) involved:
+//
+//     <root>/<owner>/<repository>/releases.json
+//     <root>/<owner>/<repository>/<tag>/<asset>
+//
+// releases.json is the index of every release (and its assets). A store has
+// no tags of its own, so the commit of a release is whatever it was created with.
+//
+// Nothing is locked, so two releases of the same repository should not be made
+// at the same time.
+type Store struct {
+	Owner      string
+	Repository string
+	store      _store
+	ctx        context.Context // See WithContext (if nil, context.Background())
+}
+
+type _store interface {
+	// location returns where the store is (e.g. /srv/releases or s3://bucket/prefix).
+	location() string
+
+	// get returns the content of <key>, or os.ErrNotExist if there is no such key.
+	get(key string) (io.ReadCloser, error)
+
+	put(key string, body io.Reader, size int64) error
+
+	// delete deletes <key>. A key that does not exist is not an error.
+	delete(key string) error
+
+	// url returns the URL of <key>.
+	url(key string) string
+}
+
+// NewStore returns a store provider for <owner>/<repository> in <store>: a
+// directory (/srv/releases or file:///srv/releases) or an S3-compatible bucket
+// (s3://bucket/prefix, see newS3).
+func NewStore(store, owner, repository string, client *http.Client) (*Store, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var tmp _store
+	switch {
+	case strings.HasPrefix(store, "s3://"):
+		location := strings.SplitN(strings.TrimPrefix(store, "s3://"), "/", 2)
+		if location[0] == "" {
+			return nil, fmt.Errorf("invalid store: %s: missing bucket", store)
+		}
+		prefix := ""
+		if len(location) > 1 {
+			prefix = strings.Trim(location[1], "/")
+		}
+		s3, err := newS3(location[0], prefix, client)
+		if err != nil {
+			return nil, err
+		}
+		tmp = s3
+	case strings.HasPrefix(store, "file://"):
+		url_, err := url.Parse(store)
+		if err != nil {
+			return nil, fmt.Errorf("invalid store: %s: %v", store, err)
+		}
+		tmp = _directory(filepath.FromSlash(url_.Path))
+	case strings.Contains(store, "://"):
+		return nil, fmt.Errorf("invalid store: %s (not a directory, file://, or s3://)", store)
+	default:
+		root, err := filepath.Abs(store)
+		if err != nil {
+			return nil, err
+		}
+		tmp = _directory(root)
+	}
+
+	return &Store{
+		Owner:      owner,
+		Repository: repository,
+		store:      tmp,
+	}, nil
+}
+
+func (st *Store) Location() string {
+	return fmt.Sprintf("%s/%s/%s", st.store.location(), st.Owner, st.Repository)
+}
+
+func (st *Store) key(path ...string) string {
+	return st.Owner + "/" + st.Repository + "/" + strings.Join(path, "/")
+}
+
+type _storeIndex struct {
+	NextID   int              `json:"next_id"`
+	Releases []*_storeRelease `json:"releases"`
+}
+
+type _storeRelease struct {
+	ID        int            `json:"id"`
+	TagName   string         `json:"tag_name"`
+	Commit    string         `json:"commit"`
+	Name      string         `json:"name"`
+	Body      string         `json:"body"`
+	CreatedAt time.Time      `json:"created_at"`
+	Assets    []*_storeAsset `json:"assets"`
+}
+
+type _storeAsset struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Size      int       `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (st *Store) index() (*_storeIndex, error) {
+	if st.ctx != nil && st.ctx.Err() != nil {
+		return nil, st.ctx.Err()
+	}
+	index := &_storeIndex{NextID: 1}
+	reader, err := st.store.get(st.key("releases.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return index, nil
+		}
+		return nil, err
+	}
+	defer reader.Close()
+	err = json.NewDecoder(reader).Decode(index)
+	if err != nil {
+		return nil, fmt.Errorf("invalid index: %s: %v", st.store.url(st.key("releases.json")), err)
+	}
+	return index, nil
+}
+
+func (st *Store) save(index *_storeIndex) error {
+	content, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return st.store.put(st.key("releases.json"), strings.NewReader(string(content)), int64(len(content)))
+}
+
+// find returns the release with <id> in <index> (or nil).
+func (index *_storeIndex) find(id *int) *_storeRelease {
+	for _, release := range index.Releases {
+		if id != nil && release.ID == *id {
+			return release
+		}
+	}
+	return nil
+}
+
+func (st *Store) asset(release *_storeRelease, asset *_storeAsset) github.ReleaseAsset {
+	return github.ReleaseAsset{
+		ID:        github.Int(asset.ID),
+		Name:      github.String(asset.Name),
+		URL:       github.String(st.DownloadURL(release.TagName, asset.Name)),
+		Size:      github.Int(asset.Size),
+		CreatedAt: &github.Timestamp{Time: asset.CreatedAt},
+	}
+}
+
+func (st *Store) release(release *_storeRelease) *Release {
+	tmp := &Release{}
+	tmp.ID = github.Int(release.ID)
+	tmp.TagName = github.String(release.TagName)
+	tmp.TargetCommitish = github.String(release.Commit)
+	tmp.Name = github.String(release.Name)
+	tmp.Body = github.String(release.Body)
+	tmp.CreatedAt = &github.Timestamp{Time: release.CreatedAt}
+	tmp.PublishedAt = &github.Timestamp{Time: release.CreatedAt}
+	for _, asset := range release.Assets {
+		tmp.Assets = append(tmp.Assets, st.asset(release, asset))
+	}
+	return tmp
+}
+
+func (st *Store) GetReleases() ([]*Release, error) {
+	index, err := st.index()
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []*Release
+	for _, release := range index.Releases {
+		releases = append(releases, st.release(release))
+	}
+
+	sort.Sort(sort.Reverse(_sortReleaseByTime(releases)))
+
+	return releases, nil
+}
+
+// CreateRelease creates <release>, which should have a TargetCommitish (the
+// commit of the tag), since there is nothing else to resolve the tag with.
+func (st *Store) CreateRelease(release *Release) error {
+	index, err := st.index()
+	if err != nil {
+		return err
+	}
+	for _, tmp := range index.Releases {
+		if tmp.TagName == stringValue(release.TagName) {
+			return fmt.Errorf("release already exists: %s", tmp.TagName)
+		}
+	}
+
+	tmp := &_storeRelease{
+		ID:        index.NextID,
+		TagName:   stringValue(release.TagName),
+		Commit:    stringValue(release.TargetCommitish),
+		Name:      stringValue(release.Name),
+		Body:      stringValue(release.Body),
+		CreatedAt: time.Now().UTC(),
+	}
+	index.NextID++
+	index.Releases = append(index.Releases, tmp)
+
+	err = st.save(index)
+	if err != nil {
+		return err
+	}
+	*release = *st.release(tmp)
+	return nil
+}
+
+func (st *Store) EditRelease(release *Release) error {
+	index, err := st.index()
+	if err != nil {
+		return err
+	}
+	tmp := index.find(release.ID)
+	if tmp == nil {
+		return fmt.Errorf("release does not exist: %s", stringValue(release.TagName))
+	}
+	tmp.Name = stringValue(release.Name)
+	tmp.Body = stringValue(release.Body)
+	return st.save(index)
+}
+
+func (st *Store) GetReleaseAssets(release github.RepositoryRelease) ([]github.ReleaseAsset, error) {
+	index, err := st.index()
+	if err != nil {
+		return nil, err
+	}
+	tmp := index.find(release.ID)
+	if tmp == nil {
+		return nil, nil
+	}
+	var assets []github.ReleaseAsset
+	for _, asset := range tmp.Assets {
+		assets = append(assets, st.asset(tmp, asset))
+	}
+	return assets, nil
+}
+
+func (st *Store) UploadAsset(release *Release, name string, file *os.File) (*github.ReleaseAsset, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := st.index()
+	if err != nil {
+		return nil, err
+	}
+	tmp := index.find(release.ID)
+	if tmp == nil {
+		return nil, fmt.Errorf("release does not exist: %s", stringValue(release.TagName))
+	}
+
+	var body io.Reader = file
+	if st.ctx != nil {
+		body = _contextReader{ctx: st.ctx, reader: file}
+	}
+	err = st.store.put(st.key(tmp.TagName, name), body, stat.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	assets := tmp.Assets[:0]
+	for _, asset := range tmp.Assets {
+		if asset.Name != name {
+			assets = append(assets, asset)
+		}
+	}
+	asset := &_storeAsset{
+		ID:        index.NextID,
+		Name:      name,
+		Size:      int(stat.Size()),
+		CreatedAt: time.Now().UTC(),
+	}
+	index.NextID++
+	tmp.Assets = append(assets, asset)
+
+	err = st.save(index)
+	if err != nil {
+		return nil, err
+	}
+	asset_ := st.asset(tmp, asset)
+	return &asset_, nil
+}
+
+func (st *Store) DeleteAsset(release *Release, asset github.ReleaseAsset) error {
+	index, err := st.index()
+	if err != nil {
+		return err
+	}
+	tmp := index.find(release.ID)
+	if tmp == nil {
+		return nil
+	}
+
+	for position, asset_ := range tmp.Assets {
+		if asset.ID != nil && asset_.ID == *asset.ID {
+			err := st.store.delete(st.key(tmp.TagName, asset_.Name))
+			if err != nil {
+				return err
+			}
+			tmp.Assets = append(tmp.Assets[:position], tmp.Assets[position+1:]...)
+			return st.save(index)
+		}
+	}
+	return nil
+}
+
+// ResolveTag returns the commit of the release for <tag>, or ErrTagNotFound if
+// there is no such release (a store has no tags of its own).
+func (st *Store) ResolveTag(tag string) (string, error) {
+	index, err := st.index()
+	if err != nil {
+		return "", err
+	}
+	for _, release := range index.Releases {
+		if release.TagName == tag {
+			return release.Commit, nil
+		}
+	}
+	return "", Errorf(ErrTagNotFound, "%s: no release for tag %q", st.Location(), tag)
+}
+
+func (st *Store) DownloadURL(tag, name string) string {
+	return st.store.url(st.key(tag, name))
+}
+
+// Download returns the content of <asset> (of <release>).
+func (st *Store) Download(release *Release, asset github.ReleaseAsset) (io.ReadCloser, error) {
+	return st.store.get(st.key(stringValue(release.TagName), stringValue(asset.Name)))
+}
+
+// _directory is a store in a local directory.
+type _directory string
+
+func (root _directory) location() string {
+	return string(root)
+}
+
+func (root _directory) path(key string) string {
+	return filepath.Join(string(root), filepath.FromSlash(key))
+}
+
+func (root _directory) get(key string) (io.ReadCloser, error) {
+	return os.Open(root.path(key))
+}
+
+func (root _directory) put(key string, body io.Reader, size int64) error {
+	path := root.path(key)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so that (e.g.) the index is never half-written
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, body)
+	if err == nil {
+		err = file.Chmod(0644)
+	}
+	if tmp := file.Close(); err == nil {
+		err = tmp
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+func (root _directory) delete(key string) error {
+	err := os.Remove(root.path(key))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (root _directory) url(key string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(root.path(key))}).String()
+}
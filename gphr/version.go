@@ -0,0 +1,186 @@
+package gphr
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var MatchVersion = regexp.MustCompile(`^(v?)(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(?:-([0-9A-Za-z.-]+))?(?:\+([0-9A-Za-z.-]+))?$`)
+
+// A Version is a semantic version (http://semver.org/), as found in a tag.
+type Version struct {
+	Tag        string // v1.2.3-rc.1+build
+	Prefix     string // v
+	Major      int    // 1
+	Minor      int    // 2
+	Patch      int    // 3
+	Prerelease string // rc.1
+	Build      string // build
+}
+
+func ParseVersion(tag string) (*Version, error) {
+	match := MatchVersion.FindStringSubmatch(tag)
+	if match == nil {
+		return nil, fmt.Errorf("invalid version: %s", tag)
+	}
+	vr := &Version{
+		Tag:        tag,
+		Prefix:     match[1],
+		Prerelease: match[5],
+		Build:      match[6],
+	}
+	var err error
+	for index, value := range []*int{&vr.Major, &vr.Minor, &vr.Patch} {
+		*value, err = strconv.Atoi(match[2+index])
+		if err != nil {
+			return nil, fmt.Errorf("invalid version: %s: %v", tag, err)
+		}
+	}
+	return vr, nil
+}
+
+func (vr *Version) String() string {
+	version := fmt.Sprintf("%s%d.%d.%d", vr.Prefix, vr.Major, vr.Minor, vr.Patch)
+	if vr.Prerelease != "" {
+		version += "-" + vr.Prerelease
+	}
+	if vr.Build != "" {
+		version += "+" + vr.Build
+	}
+	return version
+}
+
+// Number returns the version without the prefix (e.g. "1.2.3" for "v1.2.3").
+func (vr *Version) Number() string {
+	return strings.TrimPrefix(vr.String(), vr.Prefix)
+}
+
+// Compare returns -1, 0, or 1 if vr is less than, equal to, or greater than other.
+// Build metadata is ignored.
+func (vr *Version) Compare(other *Version) int {
+	for _, tmp := range [][2]int{
+		{vr.Major, other.Major},
+		{vr.Minor, other.Minor},
+		{vr.Patch, other.Patch},
+	} {
+		if tmp[0] != tmp[1] {
+			if tmp[0] < tmp[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return comparePrerelease(vr.Prerelease, other.Prerelease)
+}
+
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1 // 1.0.0 > 1.0.0-rc.1
+	case b == "":
+		return -1
+	}
+	aa, bb := strings.Split(a, "."), strings.Split(b, ".")
+	for index := 0; index < len(aa) && index < len(bb); index++ {
+		an, aerr := strconv.Atoi(aa[index])
+		bn, berr := strconv.Atoi(bb[index])
+		switch {
+		case aerr == nil && berr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aerr == nil:
+			return -1 // Numeric identifiers have lower precedence
+		case berr == nil:
+			return 1
+		case aa[index] != bb[index]:
+			if aa[index] < bb[index] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(aa) < len(bb):
+		return -1
+	case len(aa) > len(bb):
+		return 1
+	}
+	return 0
+}
+
+// SortVersions sorts the versions in ascending order.
+func SortVersions(versions []*Version) {
+	sort.Sort(_sortVersion(versions))
+}
+
+type _sortVersion []*Version
+
+func (a _sortVersion) Len() int           { return len(a) }
+func (a _sortVersion) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a _sortVersion) Less(i, j int) bool { return a[i].Compare(a[j]) < 0 }
+
+// Bump returns the next version after vr, where <part> is one of major, minor,
+// patch, or prerelease. A prerelease is released by bumping the part it is a
+// prerelease of (e.g. 1.3.0-rc.2 => minor => 1.3.0), and a new prerelease
+// starts at <preid>.1 (e.g. 1.2.3 => prerelease => 1.2.4-rc.1). Bumping a
+// prerelease continues it (1.3.0-rc.2 => 1.3.0-rc.3), unless <preid> is
+// another identifier (1.3.0-alpha.3 => beta => 1.3.0-beta.1). Without a
+// <preid>, the identifier is that of the prerelease (or else rc).
+func (vr *Version) Bump(part, preid string) (*Version, error) {
+	next := &Version{
+		Prefix: vr.Prefix,
+		Major:  vr.Major,
+		Minor:  vr.Minor,
+		Patch:  vr.Patch,
+	}
+	switch part {
+	case "major":
+		if vr.Prerelease == "" || vr.Minor != 0 || vr.Patch != 0 {
+			next.Major, next.Minor, next.Patch = vr.Major+1, 0, 0
+		}
+	case "minor":
+		if vr.Prerelease == "" || vr.Patch != 0 {
+			next.Minor, next.Patch = vr.Minor+1, 0
+		}
+	case "patch":
+		if vr.Prerelease == "" {
+			next.Patch = vr.Patch + 1
+		}
+	case "prerelease":
+		if vr.Prerelease == "" {
+			if preid == "" {
+				preid = "rc"
+			}
+			next.Patch = vr.Patch + 1
+			next.Prerelease = preid + ".1"
+			break
+		}
+		identifiers := strings.Split(vr.Prerelease, ".")
+		last := len(identifiers) - 1
+		number, err := strconv.Atoi(identifiers[last])
+		if err != nil {
+			last, number = last+1, 0 // No number (2.0.0-beta)
+		}
+		if preid != "" && preid != strings.Join(identifiers[:last], ".") {
+			next.Prerelease = preid + ".1"
+			if next.Compare(vr) <= 0 {
+				return nil, fmt.Errorf("invalid prerelease identifier: %s (%s would not come after %s)", preid, next, vr)
+			}
+			break
+		}
+		next.Prerelease = strings.Join(append(identifiers[:last], strconv.Itoa(number+1)), ".")
+	default:
+		return nil, fmt.Errorf("invalid version part: %s (not major, minor, patch, or prerelease)", part)
+	}
+	next.Tag = next.String()
+	return next, nil
+}
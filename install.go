@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/google/go-github/github"
+	"github.com/robertkrimen/gphr/gphr"
+)
+
+// _install is what an install script (install.sh, install.ps1), a Homebrew
+// formula, or a Scoop manifest installs: the (gphr) assets of a release.
+type _install struct {
+	Repository string // github.com/alice/example
+	Homepage   string // https://github.com/alice/example (if not in a store)
+	Name       string // example
+	Tag        string
+	Programs   []string
+	Assets     []_installAsset
+}
+
+type _installAsset struct {
+	Name    string // example_linux_386
+	Program string
+	GOOS    string
+	GOARCH  string
+	Digest  string // The sha256 (hex) of the asset
+	URL     string
+}
+
+// installScripts is the name (of the asset) and template of every install script.
+var installScripts = []struct {
+	name     string
+	template string
+}{
+	{"install.sh", installShTemplate},
+	{"install.ps1", installPs1Template},
+}
+
+var installShTemplate = strings.TrimLeft(`
+#!/bin/sh
+# Install {{ join .Programs ", " }} ({{ .Repository }} {{ .Tag }}), generated by gphr:
+#
+#     curl -sSfL {{ url "install.sh" }} | sh
+#     curl -sSfL {{ url "install.sh" }} | sh -s -- -p ~/.local [<program> ...]
+#
+# Every program (of the release), or every <program> given, is installed into
+# <prefix>/bin (-p, or $PREFIX, or /usr/local by default).
+set -e
+
+prefix="${PREFIX:-/usr/local}"
+while [ $# -gt 0 ]; do
+	case "$1" in
+	-p) prefix="$2"; shift 2 ;;
+	-p=*|--prefix=*) prefix="${1#*=}"; shift ;;
+	-h|--help) echo "usage: install.sh [-p <prefix>] [<program> ...]"; exit 0 ;;
+	*) break ;;
+	esac
+done
+programs="$*"
+if [ -z "$programs" ]; then
+	programs="{{ join .Programs " " }}"
+fi
+
+fail() {
+	echo "install.sh: $*" >&2
+	exit 1
+}
+
+case "$(uname -s)" in
+Linux) goos=linux ;;
+Darwin) goos=darwin ;;
+FreeBSD) goos=freebsd ;;
+OpenBSD) goos=openbsd ;;
+NetBSD) goos=netbsd ;;
+DragonFly) goos=dragonfly ;;
+MINGW*|MSYS*|CYGWIN*) goos=windows ;;
+*) fail "unsupported operating system: $(uname -s)" ;;
+esac
+case "$(uname -m)" in
+x86_64|amd64) goarch=amd64 ;;
+i386|i486|i586|i686|x86) goarch=386 ;;
+aarch64|arm64) goarch=arm64 ;;
+arm|armv*) goarch=arm ;;
+*) fail "unsupported architecture: $(uname -m)" ;;
+esac
+extension=""
+if [ "$goos" = windows ]; then
+	extension=".exe"
+fi
+
+# <name> <sha256> <url>
+assets="
+{{ range .Assets }}{{ .Name }} {{ .Digest }} {{ .URL }}
+{{ end }}"
+
+# asset outputs the asset (<name> <sha256> <url>) of <program> for the platform:
+# <program>_$GOOS_$GOARCH, or <program>-$GOOS-$GOARCH (with .exe for windows).
+asset() {
+	for name in "$1_${goos}_$goarch$extension" "$1-$goos-$goarch$extension"; do
+		line="$(echo "$assets" | awk -v name="$name" '$1 == name')"
+		if [ -n "$line" ]; then
+			echo "$line"
+			return
+		fi
+	done
+}
+
+download() {
+	if command -v curl >/dev/null 2>&1; then
+		curl -sSfL -o "$2" "$1"
+	elif command -v wget >/dev/null 2>&1; then
+		wget -q -O "$2" "$1"
+	else
+		fail "cannot download without curl or wget"
+	fi
+}
+
+sha256() {
+	if command -v sha256sum >/dev/null 2>&1; then
+		sha256sum "$1" | cut -d ' ' -f 1
+	elif command -v shasum >/dev/null 2>&1; then
+		shasum -a 256 "$1" | cut -d ' ' -f 1
+	elif command -v openssl >/dev/null 2>&1; then
+		openssl dgst -sha256 "$1" | sed 's/^.* //'
+	else
+		fail "cannot verify a checksum without sha256sum, shasum, or openssl"
+	fi
+}
+
+tmp="$(mktemp -d)"
+trap 'rm -rf "$tmp"' EXIT
+
+mkdir -p "$prefix/bin"
+for program in $programs; do
+	line="$(asset "$program")"
+	if [ -z "$line" ]; then
+		fail "$program ({{ .Tag }}) is not available for $goos/$goarch"
+	fi
+	set -- $line
+	echo "Downloading $1 ({{ .Tag }})" >&2
+	download "$3" "$tmp/$1"
+	if [ "$(sha256 "$tmp/$1")" != "$2" ]; then
+		fail "$1: checksum mismatch"
+	fi
+	chmod 755 "$tmp/$1"
+	mv -f "$tmp/$1" "$prefix/bin/$program$extension"
+	echo "Installed $prefix/bin/$program$extension" >&2
+done
+`, "\n")
+
+var installPs1Template = strings.TrimLeft(`
+# Install {{ join .Programs ", " }} ({{ .Repository }} {{ .Tag }}), generated by gphr:
+#
+#     irm {{ url "install.ps1" }} | iex
+#
+# Every program (of the release), or every program in $env:PROGRAMS (a
+# comma-separated list), is installed into <prefix>\bin ($env:PREFIX, or
+# $env:LOCALAPPDATA\Programs\{{ .Name }} by default).
+$ErrorActionPreference = "Stop"
+
+$prefix = $env:PREFIX
+if (-not $prefix) {
+    $prefix = Join-Path $env:LOCALAPPDATA "Programs\{{ .Name }}"
+}
+$programs = @({{ range $index, $program := .Programs }}{{ if $index }}, {{ end }}"{{ $program }}"{{ end }})
+if ($env:PROGRAMS) {
+    $programs = $env:PROGRAMS -split ","
+}
+
+$architecture = $env:PROCESSOR_ARCHITECTURE
+if ($env:PROCESSOR_ARCHITEW6432) {
+    $architecture = $env:PROCESSOR_ARCHITEW6432 # A 32-bit PowerShell on a 64-bit Windows
+}
+switch ($architecture) {
+    "AMD64" { $goarch = "amd64" }
+    "x86" { $goarch = "386" }
+    "ARM64" { $goarch = "arm64" }
+    "ARM" { $goarch = "arm" }
+    default { throw "unsupported architecture: $architecture" }
+}
+
+$assets = @(
+{{ range .Assets }}{{ if eq .GOOS "windows" }}    @{ Name = "{{ .Name }}"; Digest = "{{ .Digest }}"; URL = "{{ .URL }}" }
+{{ end }}{{ end }})
+
+$bin = Join-Path $prefix "bin"
+New-Item -ItemType Directory -Force -Path $bin | Out-Null
+$tmp = Join-Path ([System.IO.Path]::GetTempPath()) ([System.IO.Path]::GetRandomFileName())
+New-Item -ItemType Directory -Force -Path $tmp | Out-Null
+try {
+    foreach ($program in $programs) {
+        # <program>_windows_$GOARCH.exe, or <program>-windows-$GOARCH.exe
+        $asset = $null
+        foreach ($name in @("${program}_windows_$goarch.exe", "$program-windows-$goarch.exe")) {
+            $asset = $assets | Where-Object { $_.Name -eq $name } | Select-Object -First 1
+            if ($asset) {
+                break
+            }
+        }
+        if (-not $asset) {
+            throw "$program ({{ .Tag }}) is not available for windows/$goarch"
+        }
+        Write-Host "Downloading $($asset.Name) ({{ .Tag }})"
+        $path = Join-Path $tmp $asset.Name
+        Invoke-WebRequest -UseBasicParsing -Uri $asset.URL -OutFile $path
+        if ((Get-FileHash -Algorithm SHA256 -Path $path).Hash.ToLower() -ne $asset.Digest) {
+            throw "$($asset.Name): checksum mismatch"
+        }
+        $target = Join-Path $bin "$program.exe"
+        Move-Item -Force -Path $path -Destination $target
+        Write-Host "Installed $target"
+    }
+} finally {
+    Remove-Item -Recurse -Force -Path $tmp
+}
+`, "\n")
+
+// renderInstallScript returns the install script of <text> (a template) for <install>,
+// with <url> returning the URL of an asset (of the release).
+func renderInstallScript(text string, install *_install, url func(name string) string) ([]byte, error) {
+	tmpl, err := template.New("install").Funcs(template.FuncMap{
+		"join": strings.Join,
+		"url":  url,
+	}).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	output := bytes.NewBuffer(nil)
+	err = tmpl.Execute(output, install)
+	if err != nil {
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
+
+// newInstall returns what the install scripts of <release> (with <assets>) install:
+// every gphr asset, with the digest of each either from <digests> (by name, of a
+// binary just uploaded) or downloaded.
+func newInstall(provider gphr.Provider, repository string, release *gphr.Release, assets []github.ReleaseAsset, digests map[string]string) (*_install, error) {
+	install := &_install{
+		Repository: provider.Location(),
+		Name:       repository,
+		Tag:        stringValue(release.TagName),
+	}
+	if _, ok := provider.(*gphr.Store); !ok {
+		install.Homepage = "https://" + provider.Location()
+	}
+	programs := map[string]bool{}
+	for _, asset := range assets {
+		name := stringValue(asset.Name)
+		binary := gphr.NewBinary(name)
+		if binary.GOOS == "" {
+			continue
+		}
+		digest, ok := digests[name]
+		if !ok {
+			lg.dbg("download asset => %s (for its digest)", name)
+			body, err := gphr.Download(ctx, provider, release, asset)
+			if err != nil {
+				return nil, err
+			}
+			hash := sha256.New()
+			_, err = io.Copy(hash, body)
+			body.Close()
+			if err != nil {
+				return nil, err
+			}
+			digest = "sha256:" + hex.EncodeToString(hash.Sum(nil))
+		}
+		install.Assets = append(install.Assets, _installAsset{
+			Name:    name,
+			Program: binary.Program,
+			GOOS:    binary.GOOS,
+			GOARCH:  binary.GOARCH,
+			Digest:  strings.TrimPrefix(digest, "sha256:"),
+			URL:     provider.DownloadURL(install.Tag, name),
+		})
+		programs[binary.Program] = true
+	}
+	sort.Slice(install.Assets, func(i, j int) bool {
+		return install.Assets[i].Name < install.Assets[j].Name
+	})
+	for program := range programs {
+		install.Programs = append(install.Programs, program)
+	}
+	sort.Strings(install.Programs)
+	return install, nil
+}
+
+// uploadInstallScripts renders the install scripts (install.sh, install.ps1)
+// for <install>, and uploads them to <release> (replacing any already there).
+func uploadInstallScripts(provider gphr.Provider, release *gphr.Release, assets []github.ReleaseAsset, install *_install) ([]github.ReleaseAsset, error) {
+	if len(install.Assets) == 0 {
+		return nil, nil
+	}
+	dir, err := ioutil.TempDir("", "gphr-install")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	url := func(name string) string {
+		return provider.DownloadURL(install.Tag, name)
+	}
+
+	var uploaded []github.ReleaseAsset
+	for _, script := range installScripts {
+		content, err := renderInstallScript(script.template, install, url)
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(dir, script.name)
+		err = ioutil.WriteFile(path, content, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, asset := range assets {
+			if stringValue(asset.Name) == script.name {
+				lg.dbg("delete asset => %s (%s)", *asset.Name, stringValue(asset.URL))
+				err := provider.DeleteAsset(release, asset)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		lg.progress("Uploading %s (%d)", script.name, len(content))
+
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		asset, err := gphr.Upload(ctx, provider, release, script.name, file, 2, lg.err)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		uploaded = append(uploaded, *asset)
+	}
+	return uploaded, nil
+}
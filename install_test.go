@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"./gphr/terst"
+)
+
+var update = flag.Bool("update", false, "update the golden files (testdata/*.golden)")
+
+// testInstall returns the assets (of example and other) of a release, for the
+// golden files of the install scripts, formulas, and manifests.
+func testInstall() *_install {
+	install := &_install{
+		Repository: "github.com/alice/example",
+		Homepage:   "https://github.com/alice/example",
+		Name:       "example",
+		Tag:        "v1.2.0",
+		Programs:   []string{"example", "other"},
+	}
+	for _, name := range []string{"example_darwin_amd64", "example_linux_386", "example_windows_amd64.exe", "other-linux-amd64", "other-windows-386.exe"} {
+		match := matchBinary.FindStringSubmatch(name)
+		install.Assets = append(install.Assets, _installAsset{
+			Name:    name,
+			Program: match[1],
+			GOOS:    match[2],
+			GOARCH:  match[3],
+			Digest:  "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			URL:     "https://github.com/alice/example/releases/download/v1.2.0/" + name,
+		})
+	}
+	return install
+}
+
+// isGolden checks <content> against testdata/<name>.golden (written, with -update).
+func isGolden(name string, content []byte) {
+	golden := filepath.Join("testdata", name+".golden")
+	if *update {
+		is(ioutil.WriteFile(golden, content, 0644), nil)
+	}
+	expect, err := ioutil.ReadFile(golden)
+	is(err, nil)
+	is(string(content), string(expect))
+}
+
+func TestInstallScript(t *testing.T) {
+	terst.Terst(t, func() {
+		install := testInstall()
+		url := func(name string) string {
+			return "https://github.com/alice/example/releases/latest/download/" + name
+		}
+
+		for _, script := range installScripts {
+			content, err := renderInstallScript(script.template, install, url)
+			is(err, nil)
+			isGolden(script.name, content)
+
+			if filepath.Ext(script.name) == ".sh" {
+				if sh, err := exec.LookPath("sh"); err == nil {
+					cmd := exec.Command(sh, "-n")
+					cmd.Stdin = bytes.NewReader(content)
+					output, err := cmd.CombinedOutput()
+					is(string(output), "")
+					is(err, nil)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/robertkrimen/gphr/gphr"
+)
+
+// _listFilter is what list lists: the assets of a program (-program), for a
+// platform (-os, -arch), of a release in a range of tags (-tag) that is (or is
+// not) a prerelease (-prerelease).
+type _listFilter struct {
+	programs   map[string]bool // If empty, any
+	oses       map[string]bool
+	arches     map[string]bool
+	tag        string        // A tag, if not a range
+	from, to   *gphr.Version // A range of tags (inclusive), either may be nil
+	prerelease string        // include, exclude, or only
+}
+
+func newListFilter() (*_listFilter, error) {
+	filter := &_listFilter{
+		programs:   listSet(*flags.list.program),
+		oses:       listSet(*flags.list.os),
+		arches:     listSet(*flags.list.arch),
+		prerelease: *flags.list.prerelease,
+	}
+
+	switch filter.prerelease {
+	case "include", "exclude", "only":
+	case "":
+		filter.prerelease = "include"
+	default:
+		return nil, gphr.Errorf(errUsage, "invalid -prerelease: %s (include, exclude, or only)", filter.prerelease)
+	}
+
+	// -tag=v1.2.0, -tag=v1.0.0..v1.2.0, -tag=v1.0.0.., or -tag=..v1.2.0
+	tag := *flags.list.tag
+	if index := strings.Index(tag, ".."); index == -1 {
+		filter.tag = tag
+	} else {
+		for _, tmp := range []struct {
+			tag     string
+			version **gphr.Version
+		}{
+			{tag[:index], &filter.from},
+			{tag[index+2:], &filter.to},
+		} {
+			if tmp.tag == "" {
+				continue
+			}
+			version, err := gphr.ParseVersion(tmp.tag)
+			if err != nil {
+				return nil, gphr.Errorf(errUsage, "invalid -tag: %s: %v", tag, err)
+			}
+			*tmp.version = version
+		}
+	}
+
+	return filter, nil
+}
+
+// listSet returns the set of (comma-separated) <values>.
+func listSet(values string) map[string]bool {
+	set := map[string]bool{}
+	for _, value := range strings.Split(values, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			set[value] = true
+		}
+	}
+	return set
+}
+
+// release returns whether (the assets of) <release> are to be listed.
+func (filter *_listFilter) release(release *gphr.Release) bool {
+	prerelease := release.Prerelease != nil && *release.Prerelease
+	switch {
+	case filter.prerelease == "exclude" && prerelease:
+		return false
+	case filter.prerelease == "only" && !prerelease:
+		return false
+	}
+
+	tag := stringValue(release.TagName)
+	if filter.tag != "" {
+		return tag == filter.tag
+	}
+	if filter.from != nil || filter.to != nil {
+		version, err := gphr.ParseVersion(tag)
+		if err != nil {
+			return false // Not in any range
+		}
+		if filter.from != nil && version.Compare(filter.from) < 0 {
+			return false
+		}
+		if filter.to != nil && version.Compare(filter.to) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// asset returns whether the asset <name> (a gphr asset) is to be listed.
+func (filter *_listFilter) asset(name string) bool {
+	binary := gphr.NewBinary(name)
+	if binary.GOOS == "" {
+		return false
+	}
+	for _, tmp := range []struct {
+		set   map[string]bool
+		value string
+	}{
+		{filter.programs, binary.Program},
+		{filter.oses, binary.GOOS},
+		{filter.arches, binary.GOARCH},
+	} {
+		if len(tmp.set) > 0 && !tmp.set[tmp.value] {
+			return false
+		}
+	}
+	return true
+}
+
+// listColumns are the columns list can show (-columns).
+var listColumns = map[string]func(asset _asset) string{
+	"name":      func(asset _asset) string { return asset.Name },
+	"tag":       func(asset _asset) string { return asset.Tag },
+	"program":   func(asset _asset) string { return gphr.NewBinary(asset.Name).Program },
+	"platform":  func(asset _asset) string { return platform(asset.Name) },
+	"size":      func(asset _asset) string { return strconv.FormatInt(asset.Size, 10) },
+	"downloads": func(asset _asset) string { return strconv.Itoa(asset.DownloadCount) },
+	"created": func(asset _asset) string {
+		if asset.CreatedAt == nil {
+			return "-"
+		}
+		return asset.CreatedAt.Format("2006-01-02")
+	},
+	"uploader": func(asset _asset) string {
+		if asset.Uploader == "" {
+			return "-"
+		}
+		return asset.Uploader
+	},
+	"url": func(asset _asset) string { return asset.URL },
+}
+
+// getListColumns returns the columns to list (-columns), by default name and
+// tag (and, for a table, size and url).
+func getListColumns(output string) ([]string, error) {
+	value := *flags.list.columns
+	if value == "" {
+		value = "name,tag"
+		if output == "table" || output == "csv" {
+			value = "name,tag,size,url"
+		}
+	}
+	var columns []string
+	for _, column := range strings.Split(value, ",") {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if _, ok := listColumns[column]; !ok {
+			return nil, gphr.Errorf(errUsage, "invalid -columns: %s (name, tag, program, platform, size, downloads, created, uploader, or url)", column)
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// platform returns the platform ($GOOS/$GOARCH) of the asset <name>.
+func platform(name string) string {
+	binary := gphr.NewBinary(name)
+	return binary.GOOS + "/" + binary.GOARCH
+}
+
+// missingPlatforms returns the platforms (of a program, e.g. "example linux/386") that
+// are in the previous release, but not the newest (of <releases>, newest first).
+func missingPlatforms(releases []_release) []string {
+	if len(releases) < 2 {
+		return nil
+	}
+	identifiers := func(release _release) map[string]bool {
+		set := map[string]bool{}
+		for _, asset := range release.Assets {
+			binary := gphr.NewBinary(asset.Name)
+			set[binary.Program+" "+binary.GOOS+"/"+binary.GOARCH] = true
+		}
+		return set
+	}
+	newest := identifiers(releases[0])
+	var missing []string
+	for identifier := range identifiers(releases[1]) {
+		if !newest[identifier] {
+			missing = append(missing, identifier)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// _platform is a platform (and its assets), as output (-output=json -group=platform).
+type _platform struct {
+	Platform string   `json:"platform"`
+	Assets   []_asset `json:"assets"`
+}
+
+// _repositoryListing is a repository (and what list lists of it), as output
+// (-output=json, when listing more than one repository).
+type _repositoryListing struct {
+	Repository string      `json:"repository"`
+	Releases   []_release  `json:"releases,omitempty"`
+	Platforms  []_platform `json:"platforms,omitempty"`
+}
+
+// _listing is a repository to list: its releases (newest first), and what of
+// them is listed.
+type _listing struct {
+	provider gphr.Provider
+	releases []*gphr.Release
+
+	listed []_release // The releases (and assets) that pass the filter
+	assets []_asset   // Every asset of listed, (stably) sorted by platform, if so grouped
+}
+
+// platforms returns the assets of <listing>, grouped by platform.
+func (listing *_listing) platforms() []_platform {
+	platforms := []_platform{}
+	for _, asset := range listing.assets {
+		name := platform(asset.Name)
+		if len(platforms) == 0 || platforms[len(platforms)-1].Platform != name {
+			platforms = append(platforms, _platform{Platform: name})
+		}
+		platforms[len(platforms)-1].Assets = append(platforms[len(platforms)-1].Assets, asset)
+	}
+	return platforms
+}
+
+// listConcurrency is how many repositories list queries at once.
+const listConcurrency = 4
+
+// getListings returns a listing for every repository of <targets>: host/owner/repository,
+// host/owner (every repository of the owner), or "" (the local repository). The
+// releases of the repositories are queried concurrently.
+func getListings(targets []string) ([]*_listing, error) {
+	tokens := map[string]string{} // By host
+	hostToken := func(host string) (string, error) {
+		if token, ok := tokens[host]; ok {
+			return token, nil
+		}
+		token, err := getToken(host)
+		if err != nil {
+			return "", err
+		}
+		tokens[host] = token
+		return token, nil
+	}
+
+	var listings []*_listing
+	for _, target := range targets {
+		host, owner, repository, _ := gphr.MatchTarget(target)
+		if target == "" || repository != "" || owner == "" {
+			var err error
+			host, owner, repository, _, err = getTarget(target)
+			if err != nil {
+				return nil, err
+			}
+		} else if !strings.Contains(host, ".") && !strings.Contains(host, ":") {
+			return nil, fmt.Errorf("invalid target: %s: not a URL (e.g. github.com/alice)", target)
+		}
+
+		token, err := hostToken(host)
+		if err != nil {
+			return nil, err
+		}
+
+		if repository != "" {
+			provider, err := client(host, owner, repository, token)
+			if err != nil {
+				return nil, err
+			}
+			listings = append(listings, &_listing{provider: provider})
+			continue
+		}
+
+		// Every repository of <owner>
+		provider, err := client(host, owner, "", token)
+		if err != nil {
+			return nil, err
+		}
+		lister, ok := provider.(gphr.RepositoryLister)
+		if !ok {
+			return nil, fmt.Errorf("cannot list the repositories of %s/%s (in a store)", host, owner)
+		}
+		repositories, err := lister.GetRepositories()
+		if err != nil {
+			return nil, err
+		}
+		for _, repository := range repositories {
+			provider, err := client(host, owner, repository, token)
+			if err != nil {
+				return nil, err
+			}
+			listings = append(listings, &_listing{provider: provider})
+		}
+	}
+
+	errs := make([]error, len(listings))
+	semaphore := make(chan struct{}, listConcurrency)
+	var wait sync.WaitGroup
+	for index, listing := range listings {
+		wait.Add(1)
+		go func(index int, listing *_listing) {
+			defer wait.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			listing.releases, errs[index] = listing.provider.GetReleases()
+		}(index, listing)
+	}
+	wait.Wait()
+
+	for index, err := range errs {
+		if err != nil {
+			if len(listings) > 1 {
+				return nil, fmt.Errorf("%s: %w", listings[index].provider.Location(), err)
+			}
+			return nil, err
+		}
+	}
+	return listings, nil
+}
+
+// list outputs the (filtered) assets of the releases of every listing, grouped
+// (by release or platform, -group) or not. The repository of each is output
+// too, if there is more than one.
+func list(listings []*_listing, output string) error {
+	filter, err := newListFilter()
+	if err != nil {
+		return err
+	}
+	columns, err := getListColumns(output)
+	if err != nil {
+		return err
+	}
+	group := *flags.list.group
+	switch group {
+	case "", "release", "platform":
+	default:
+		return gphr.Errorf(errUsage, "invalid -group: %s (release, or platform)", group)
+	}
+	many := len(listings) > 1
+
+	for _, listing := range listings {
+		listing.listed = []_release{}
+		for _, release := range listing.releases {
+			if !filter.release(release) {
+				continue
+			}
+			tmp := newRelease(release)
+			for _, asset := range release.Assets {
+				if filter.asset(stringValue(asset.Name)) {
+					tmp.Assets = append(tmp.Assets, newAsset(listing.provider, release, asset))
+				}
+			}
+			listing.listed = append(listing.listed, tmp)
+		}
+
+		missing := missingPlatforms(listing.listed)
+		if len(missing) > 0 {
+			prefix := ""
+			if many {
+				prefix = listing.provider.Location() + ": "
+			}
+			lg.err("%s%s (the newest release) is missing %s (in %s)", prefix, listing.listed[0].Tag, strings.Join(missing, ", "), listing.listed[1].Tag)
+			listing.listed[0].Missing = missing
+		}
+
+		for _, release := range listing.listed {
+			listing.assets = append(listing.assets, release.Assets...)
+		}
+		if group == "platform" {
+			assets := listing.assets
+			sort.SliceStable(assets, func(i, j int) bool {
+				return platform(assets[i].Name) < platform(assets[j].Name)
+			})
+		}
+	}
+
+	row := func(asset _asset) []string {
+		var row []string
+		for _, column := range columns {
+			row = append(row, listColumns[column](asset))
+		}
+		return row
+	}
+
+	switch output {
+	case "json":
+		if many {
+			repositories := []_repositoryListing{}
+			for _, listing := range listings {
+				tmp := _repositoryListing{Repository: listing.provider.Location()}
+				if group == "platform" {
+					tmp.Platforms = listing.platforms()
+				} else {
+					tmp.Releases = listing.listed
+				}
+				repositories = append(repositories, tmp)
+			}
+			return writeJSON(repositories)
+		}
+		if group == "platform" {
+			return writeJSON(listings[0].platforms())
+		}
+		return writeJSON(listings[0].listed)
+
+	case "table", "csv":
+		header := []string{}
+		if many {
+			header = append(header, "REPOSITORY")
+		}
+		for _, column := range columns {
+			header = append(header, strings.ToUpper(column))
+		}
+		var rows [][]string
+		for _, listing := range listings {
+			for _, asset := range listing.assets {
+				if many {
+					rows = append(rows, append([]string{listing.provider.Location()}, row(asset)...))
+				} else {
+					rows = append(rows, row(asset))
+				}
+			}
+		}
+		return writeTable(header, rows)
+	}
+
+	for _, listing := range listings {
+		indent := ""
+		if many {
+			log("%s", listing.provider.Location())
+			indent = "    "
+		}
+
+		if len(listing.releases) == 0 {
+			log("%sThere are no releases for %s", indent, listing.provider.Location())
+			continue
+		}
+		if len(listing.assets) == 0 {
+			log("%sThere are no assets (or no gphr assets) for %s", indent, listing.provider.Location())
+			continue
+		}
+
+		switch group {
+		case "release":
+			for _, release := range listing.listed {
+				if len(release.Assets) == 0 {
+					continue
+				}
+				log("%s%s", indent, release.Tag)
+				for _, asset := range release.Assets {
+					log("%s    %s", indent, strings.Join(row(asset), " "))
+				}
+			}
+		case "platform":
+			last := ""
+			for _, asset := range listing.assets {
+				if name := platform(asset.Name); name != last {
+					log("%s%s", indent, name)
+					last = name
+				}
+				log("%s    %s", indent, strings.Join(row(asset), " "))
+			}
+		default:
+			for _, asset := range listing.assets {
+				log("%s%s", indent, strings.Join(row(asset), " "))
+			}
+		}
+	}
+	return nil
+}
@@ -17,6 +17,16 @@ func (lg _log) log(format string, arguments ...interface{}) {
 	fmt.Fprintf(os.Stdout, format+"\n", arguments...)
 }
 
+// progress logs what is being done (Uploading ..., Downloading ...), to stderr
+// if the output is for a machine (-output=json), so as to leave stdout to it.
+func (lg _log) progress(format string, arguments ...interface{}) {
+	if machine() {
+		fmt.Fprintf(os.Stderr, format+"\n", arguments...)
+		return
+	}
+	lg.log(format, arguments...)
+}
+
 func (lg _log) err(format string, arguments ...interface{}) {
 	fmt.Fprintf(os.Stderr, "gphr: "+format+"\n", arguments...)
 }
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
-	"os/exec"
+	"os/signal"
+	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -25,65 +30,349 @@ func getTarget(target string) (host, owner, repository, program string, err erro
 	if target != "" {
 		return gphr.GetTarget(target)
 	} else {
-		owner, repository, err = gitGetGitHubURL()
+		host, owner, repository, err = gitGetGitHubURL()
 		if err != nil {
 			return "", "", "", "", err
 		}
 		if owner == "" {
 			return "", "", "", "", fmt.Errorf("getTarget: gitGetGitHuBURL: FIXME")
 		}
-		host = "github.com"
 	}
 	return
 }
 
-func client(owner, repository, token string) (*gphr.GitHub, error) {
-	cache := httpcache.NewMemoryCache()
-	client := httpcache.NewTransport(cache).Client()
+// getRepository returns the host/owner/repository of <repository> (alice/example,
+// github.com/alice/example, github.example.com/alice/example, or
+// gitlab.com/group/subgroup/example), or of the local repository if <repository>
+// is empty. As with a remote URL, every segment between the host and the
+// repository is the owner.
+func getRepository(repository string) (string, string, string, error) {
+	if repository != "" {
+		segments := strings.Split(strings.Trim(repository, "/"), "/")
+		for _, segment := range segments {
+			if segment == "" {
+				segments = nil
+			}
+		}
+		switch len(segments) {
+		case 0, 1:
+			return "", "", "", lg.error("cannot determine GitHub repository (owner/repository) from: %s", repository)
+		case 2:
+			return "github.com", segments[0], segments[1], nil // alice/example
+		default:
+			return segments[0], strings.Join(segments[1:len(segments)-1], "/"), segments[len(segments)-1], nil // github.com/alice/example
+		}
+	}
+	host, owner, repository, err := gitGetGitHubURL()
+	if err != nil {
+		return "", "", "", err
+	}
+	if owner == "" {
+		return "", "", "", lg.error("cannot determine GitHub repository from: git config --get remote.origin.url")
+	}
+	return host, owner, repository, nil
+}
 
-	gh := gphr.NewGitHub(owner, repository, client, token)
-	return gh, nil
+// client returns the provider for <owner>/<repository> at <host> (or in the store),
+// with every request cancelled if gphr is interrupted.
+func client(host, owner, repository, token string) (gphr.Provider, error) {
+	provider, err := newProvider(host, owner, repository, token)
+	if err != nil {
+		return nil, err
+	}
+	return provider.WithContext(ctx), nil
 }
 
-var matchBuiltPackage = regexp.MustCompile(`(?m)^#\s*\n^#\s*(.*)\s*\n^#\s*\n`)
+func newProvider(host, owner, repository, token string) (gphr.Provider, error) {
+	if store := getStore(); store != "" {
+		// Not cached (the index changes underneath with every release)
+		return gphr.NewStore(store, owner, repository, nil)
+	}
 
-func getToken() (string, error) {
-	token := *flags.main.token
+	var cache httpcache.Cache = httpcache.NewMemoryCache()
+	if tmp, err := getCache(); err != nil {
+		return nil, err
+	} else if tmp != nil {
+		cache = tmp
+	}
+	retry := gphr.NewRetryTransport(nil)
+	retry.Log = lg.err
+	transport := httpcache.NewTransport(cache)
+	transport.Transport = retry
+	client := &http.Client{Transport: _revalidate{transport}}
+
+	apiURL, uploadURL := *flags.main.apiURL, *flags.main.uploadURL
+	if apiURL == "" {
+		apiURL = os.Getenv("GPHR_API_URL")
+	}
+	if uploadURL == "" {
+		uploadURL = os.Getenv("GPHR_UPLOAD_URL")
+	}
+	if uploadURL == "" {
+		uploadURL = apiURL
+	}
 
-	if token == "" {
-		token = os.ExpandEnv("$GPHR_TOKEN")
+	kind := providerKind(host)
+	if kind == gphr.ProviderGitHub {
+		app, err := getApp()
+		if err != nil {
+			return nil, err
+		}
+		if app != nil {
+			return gphr.NewGitHubApp(host, apiURL, uploadURL, owner, repository, client, app)
+		}
 	}
 
-	lg.dbg("token = %s", token)
+	return gphr.NewProvider(kind, host, apiURL, uploadURL, owner, repository, client, token)
+}
 
-	if token == "" {
-		return "", nil
+// getApp returns the GitHub App to authenticate as (-app-id and -app-key, or
+// GPHR_APP_ID and GPHR_APP_KEY), if any.
+func getApp() (*gphr.App, error) {
+	id, key := *flags.main.appID, *flags.main.appKey
+	if id == "" {
+		id = os.Getenv("GPHR_APP_ID")
+	}
+	if id == "" {
+		return nil, nil
+	}
+
+	var input []byte
+	if key == "" {
+		key = os.Getenv("GPHR_APP_KEY")
+		if strings.HasPrefix(strings.TrimSpace(key), "-----BEGIN") {
+			input = []byte(key) // The key itself
+		}
+	}
+	if key == "" {
+		return nil, lg.error("missing private key for app %s (-app-key or GPHR_APP_KEY)", id)
+	}
+	if input == nil {
+		var err error
+		input, err = ioutil.ReadFile(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	privateKey, err := gphr.ParsePrivateKey(input)
+	if err != nil {
+		return nil, lg.error("app %s: %v", id, err)
+	}
+
+	app := &gphr.App{ID: id, PrivateKey: privateKey}
+	if installation := os.Getenv("GPHR_APP_INSTALLATION_ID"); installation != "" {
+		app.InstallationID, err = strconv.Atoi(installation)
+		if err != nil {
+			return nil, lg.error("invalid GPHR_APP_INSTALLATION_ID: %s", installation)
+		}
+	}
+	lg.dbg("app = %s (installation %d)", app.ID, app.InstallationID)
+	return app, nil
+}
+
+// getCache returns the HTTP cache on disk (GPHR_CACHE, or gphr in the user cache
+// directory), or nil if GPHR_CACHE is "off".
+func getCache() (*gphr.DiskCache, error) {
+	dir := os.Getenv("GPHR_CACHE")
+	if dir == "off" {
+		return nil, nil
+	}
+	if dir == "" {
+		var err error
+		dir, err = gphr.CacheDirectory()
+		if err != nil {
+			lg.dbg("cache = (none): %v", err)
+			return nil, nil
+		}
 	}
 
-	if token[0] != '!' {
-		return token, nil
+	size := int64(gphr.DefaultCacheSize)
+	if tmp := os.Getenv("GPHR_CACHE_SIZE"); tmp != "" {
+		megabytes, err := strconv.Atoi(tmp)
+		if err != nil || megabytes < 0 {
+			return nil, lg.error("invalid GPHR_CACHE_SIZE: %s (not a number of megabytes)", tmp)
+		}
+		size = int64(megabytes) << 20
 	}
 
-	token = token[1:]
-	cmd := exec.Command("/bin/sh", "-c", token)
-	output, err := cmd.Output()
+	lg.dbg("cache = %s (%d)", dir, size)
+	return gphr.NewDiskCache(dir, size), nil
+}
+
+// _revalidate has every (cached) response revalidated (If-None-Match) instead
+// of used as is while still fresh: a 304 does not count against the rate limit,
+// and a new release shows up at once.
+type _revalidate struct {
+	http.RoundTripper
+}
+
+func (transport _revalidate) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Method == "GET" && request.Header.Get("Cache-Control") == "" {
+		tmp := new(http.Request)
+		*tmp = *request
+		tmp.Header = make(http.Header, len(request.Header)+1)
+		for key, values := range request.Header {
+			tmp.Header[key] = values
+		}
+		tmp.Header.Set("Cache-Control", "max-age=0")
+		request = tmp
+	}
+	return transport.RoundTripper.RoundTrip(request)
+}
+
+// getStore returns the store (a directory or s3://bucket/prefix) to keep releases
+// in, instead of GitHub, if any.
+func getStore() string {
+	if store := *flags.main.store; store != "" {
+		return store
+	}
+	return os.Getenv("GPHR_STORE")
+}
+
+// providerKind returns the kind of provider (github, gitlab, ...) for <host>.
+func providerKind(host string) string {
+	if kind := *flags.main.provider; kind != "" {
+		return kind
+	}
+	return gphr.ProviderKind(host)
+}
+
+// rate returns the GitHub client of <provider> (if any), for its rate limit.
+func rate(provider gphr.Provider) *github.Client {
+	if gh, ok := provider.(*gphr.GitHub); ok {
+		return gh.Client
+	}
+	return nil
+}
+
+var matchBuiltPackage = regexp.MustCompile(`(?m)^#\s*\n^#\s*(.*)\s*\n^#\s*\n`)
+
+// getCredential returns the token for <host> (and where it came from): -token,
+// GPHR_TOKEN, or any of the usual sources (see gphr.CredentialSources). It
+// returns nil if there is no token.
+func getCredential(host string) (*gphr.Credential, error) {
+	sources := []gphr.CredentialSource{
+		&gphr.TokenCredential{Token: *flags.main.token, Source: "-token"},
+		&gphr.TokenCredential{Token: os.Getenv("GPHR_TOKEN"), Source: "GPHR_TOKEN"},
+	}
+	sources = append(sources, gphr.CredentialSources(providerKind(host), host)...)
+	return gphr.GetCredential(host, sources...)
+}
+
+func getToken(host string) (string, error) {
+	if getStore() != "" {
+		return "", nil // A store does not need a token
+	}
+
+	credential, err := getCredential(host)
 	if err != nil {
 		return "", err
 	}
-	return strings.TrimSpace(string(output)), nil
+	if credential == nil {
+		lg.dbg("token = (none)")
+		return "", nil
+	}
+
+	lg.dbg("token = %s (%s)", maskToken(credential.Token), credential.Source)
+
+	return credential.Token, nil
+}
+
+// tokenVariable returns the (usual) environment variable with the token for <host>.
+func tokenVariable(host string) string {
+	switch providerKind(host) {
+	case gphr.ProviderGitLab:
+		return "GITLAB_TOKEN"
+	case gphr.ProviderGitea:
+		return "GITEA_TOKEN"
+	}
+	if host != "github.com" {
+		return "GH_ENTERPRISE_TOKEN"
+	}
+	return "GITHUB_TOKEN"
+}
+
+// maskToken returns <token> with all but the (non-secret) prefix and the last 4
+// characters masked, e.g. ghp_****************************wxyz
+func maskToken(token string) string {
+	if len(token) < 12 {
+		return strings.Repeat("*", len(token))
+	}
+	prefix := 0
+	if index := strings.Index(token, "_"); index != -1 && index < 12 {
+		prefix = index + 1
+	}
+	return token[:prefix] + strings.Repeat("*", len(token)-prefix-4) + token[len(token)-4:]
 }
 
 var matchBinary = gphr.MatchBinary
 
 func main() {
-	flags.main_.Parse(os.Args[1:])
+	err := run(os.Args[1:])
+	if err != nil {
+		lg.err("%s", err.Error())
+		os.Exit(exitCode(err))
+	}
+}
+
+var (
+	errUsage       = errors.New("usage")
+	errInterrupted = errors.New("interrupted")
+)
+
+// exitCode returns the exit status of gphr for <err> (see the usage).
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	switch {
+	case errors.Is(err, errUsage):
+		return 2
+	case errors.Is(err, errInterrupted):
+		return 130
+	}
+	switch gphr.Kind(err) {
+	case gphr.ErrNotTagged:
+		return 3
+	case gphr.ErrTagNotFound:
+		return 4
+	case gphr.ErrTagMismatch:
+		return 5
+	case gphr.ErrAssetExists:
+		return 6
+	case gphr.ErrAuth:
+		return 7
+	case gphr.ErrRateLimited:
+		return 8
+	case gphr.ErrNoMatchingAsset:
+		return 9
+	case gphr.ErrIncomplete:
+		return 10
+	}
+	return 1
+}
+
+// ctx is done once gphr is interrupted (SIGINT), to cancel whatever it is doing.
+var ctx = context.Background()
+
+// run runs gphr with <arguments> (os.Args[1:]).
+func run(arguments []string) error {
+	var stop context.CancelFunc
+	ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	flags.main_.Parse(arguments)
 	if *flags.main.dryRun {
 		*flags.main.debug = true
 	}
+	output, err := getOutput()
+	if err != nil {
+		return err
+	}
 
 	var cl *github.Client
 
-	err := func() error {
+	err = func() error {
 		switch command := flags.main_.Arg(0); command {
 
 		case "release":
@@ -91,15 +380,6 @@ func main() {
 
 			flags.release_.Parse(flags.main_.Args()[1:])
 
-			token, err := getToken()
-			if err != nil {
-				return err
-			}
-
-			if token == "" {
-				return lg.error("cannot release without -token or GPHR_TOKEN")
-			}
-
 			// 0. Make sure the asset arguments actually look like assets.
 			// (Are in the form of *_$GOOOS_$GOARCH, etc.)
 			var binaries []*gphr.Binary
@@ -119,35 +399,44 @@ func main() {
 				return lg.error("no binaries to upload")
 			}
 
+			config, err := getConfig()
+			if err != nil {
+				return err
+			}
+			formats, err := getPackageFormats(config)
+			if err != nil {
+				return err
+			}
+			requirements, err := getRequirements(config, *flags.release.require)
+			if err != nil {
+				return err
+			}
+			requirements.partial = requirements.partial || *flags.release.partial
+
 			// 1. Determine the GitHub owner/repository from the local repository (if not explicity given).
-			owner, repository := "", ""
-			repository = *flags.release.repository
-			if repository != "" {
-				match := strings.SplitN(repository, "/", 4)
-				switch len(match) {
-				case 2:
-					owner, repository = match[0], match[1] // alice/example
-				default:
-					owner, repository = match[1], match[2] // github.com/alice/example
-				case 0, 1:
-					return lg.error("cannot determine GitHub repository (owner/repository) from: %s", repository)
-				}
-			} else {
-				var err error
-				owner, repository, err = gitGetGitHubURL()
-				if err != nil {
-					return err
-				}
-				if owner == "" {
-					return lg.error("cannot determine GitHub repository from: git config --get remote.origin.url")
-				}
+			host, owner, repository, err := getRepository(*flags.release.repository)
+			if err != nil {
+				return err
 			}
+			requirements.program = repository
 
-			gh, err := client(owner, repository, token)
+			token, err := getToken(host)
 			if err != nil {
 				return err
 			}
-			cl = gh.Client
+			app, err := getApp()
+			if err != nil {
+				return err
+			}
+			if token == "" && app == nil && getStore() == "" {
+				return gphr.Errorf(gphr.ErrAuth, "cannot release without a token (-token, GPHR_TOKEN, %s, ...), see: gphr auth status", tokenVariable(host))
+			}
+
+			provider, err := client(host, owner, repository, token)
+			if err != nil {
+				return err
+			}
+			cl = rate(provider)
 
 			// 2. Determine the tag for HEAD in the local repository.
 			tag, err := gitGetTag()
@@ -158,22 +447,28 @@ func main() {
 
 			if tag == "" {
 				commit, _ := gitGetTagCommit("HEAD")
-				return lg.error("HEAD (%s) is not tagged", commit)
+				return gphr.Errorf(gphr.ErrNotTagged, "HEAD (%s) is not tagged", commit)
 			}
 
-			// 3. Determine the commit for the target tag.
+			// 3. Check the local repository (uncommitted changes, unpushed HEAD/tag, ...).
+			err = preflight(tag, binaries)
+			if err != nil {
+				return err
+			}
+
+			// 4. Determine the commit for the target tag.
 			tagCommit, err := gitGetTagCommit(tag)
 			if err != nil {
 				return err
 			}
 			lg.dbg("tagCommit = %s", tagCommit)
 
-			releases, err := gh.GetReleases()
+			releases, err := provider.GetReleases()
 			if err != nil {
 				return err
 			}
 
-			// 4. Find the release that matches the target tag.
+			// 5. Find the release that matches the target tag.
 			var release *gphr.Release
 			for _, tmp := range releases {
 				if *tmp.TagName == tag {
@@ -182,21 +477,31 @@ func main() {
 				}
 			}
 
+			// 5b. Check the binaries (and the assets of the release) against the programs of .gphr (and -require).
+			missing, err := checkRelease(requirements, binaries, release)
+			if err != nil {
+				return err
+			}
+
 			checkTag := func(tag string) error {
-				commit, _, err := gh.GetCommit(tag)
+				commit, err := provider.ResolveTag(tag)
+				if errors.Is(err, gphr.ErrTagNotFound) {
+					if _, ok := provider.(*gphr.Store); ok {
+						return nil // A store has no tags, only releases (created with the local commit)
+					}
+					return gphr.Errorf(gphr.ErrTagNotFound, "tag %q does not exist in the remote repository", tag)
+				}
 				if err != nil {
 					return err
 				}
-				if commit == "" {
-					return lg.error("tag %q does not exist in the remote repository", tag)
-				}
 				if tagCommit != commit {
-					return lg.error("tag %q (%s) does not match %q in the local repository", commit, tag, tagCommit)
+					return gphr.Errorf(gphr.ErrTagMismatch, "tag %q (%s) does not match %q in the local repository", tag, commit, tagCommit)
 				}
 				return nil
 			}
 
-			// 5. If no release was found, then create a release for the target tag.
+			// 6. If no release was found, then create a release for the target tag.
+			pretend := false // The release a -dry-run would have created (so there is nothing to ask of it)
 			if release == nil {
 				err := checkTag(tag)
 				if err != nil {
@@ -205,28 +510,56 @@ func main() {
 
 				lg.dbg("create release => %s", tag)
 
-				if *flags.main.dryRun {
-					return nil
-				}
-
 				release = &gphr.Release{}
 				release.TagName = &tag
-				release_, _, err := gh.Client.Repositories.CreateRelease(owner, repository, &release.RepositoryRelease)
-				if err != nil {
-					return err
+				if *flags.main.dryRun {
+					pretend = true
+				} else {
+					name, body, err := getReleaseNotes(tag)
+					if err != nil {
+						return err
+					}
+
+					release.TargetCommitish = &tagCommit
+					release.Name = &name
+					release.Body = &body
+					err = provider.CreateRelease(release)
+					if err != nil {
+						return err
+					}
 				}
-				release.ID = release_.ID
 			} else {
 				// Otherwise, we found a release, make sure the commit matches what we have for the tag
 				err := checkTag(*release.TagName)
 				if err != nil {
 					return err
 				}
+
+				if *flags.release.editNotes {
+					name, body, err := getReleaseNotes(tag)
+					if err != nil {
+						return err
+					}
+
+					lg.dbg("edit release => %s", tag)
+
+					if !*flags.main.dryRun {
+						release.Name = &name
+						release.Body = &body
+						err = provider.EditRelease(release)
+						if err != nil {
+							return err
+						}
+					}
+				}
 			}
 
-			assets, err := gh.GetReleaseAssets(release.RepositoryRelease)
-			if err != nil {
-				return err
+			var assets []github.ReleaseAsset
+			if !pretend {
+				assets, err = provider.GetReleaseAssets(release.RepositoryRelease)
+				if err != nil {
+					return err
+				}
 			}
 
 			err = nil
@@ -237,7 +570,7 @@ func main() {
 							binary.Asset = asset
 						} else {
 							lg.err("%s: an asset of the same kind already exists (%s)", binary.Name, *asset.Name)
-							err = lg.error("1 or more assets with the same name already exist")
+							err = gphr.Errorf(gphr.ErrAssetExists, "1 or more assets with the same name already exist")
 						}
 					}
 				}
@@ -246,7 +579,21 @@ func main() {
 				return err
 			}
 
-			// 6. Upload assets to the target release.
+			released := _released{
+				Repository: provider.Location(),
+				Tag:        tag,
+				Uploaded:   []_asset{},
+				Deleted:    []_asset{},
+				Programs:   []*_releasedProgram{},
+			}
+			for _, binary := range binaries {
+				released.program(binary.Program)
+			}
+			for _, program := range programNames(missing) {
+				released.program(program).Missing = missing[program]
+			}
+
+			// 7. Upload assets to the target release.
 			for _, binary := range binaries {
 				file, err := os.Open(binary.Path)
 				if err != nil {
@@ -257,12 +604,11 @@ func main() {
 				if binary.Asset.ID != nil {
 					lg.dbg("delete asset => %s (%s)", *binary.Asset.Name, *binary.Asset.URL)
 					if !*flags.main.dryRun {
-						response, err := gh.Client.Repositories.DeleteReleaseAsset(owner, repository, *binary.Asset.ID)
+						err := provider.DeleteAsset(release, binary.Asset)
 						if err != nil {
-							if response == nil || response.StatusCode != 404 {
-								return err
-							}
+							return err
 						}
+						released.Deleted = append(released.Deleted, newAsset(provider, release, binary.Asset))
 					}
 				}
 
@@ -275,39 +621,116 @@ func main() {
 					continue
 				}
 
-				log("Uploading %s (%d)", binary.Path, size)
+				lg.progress("Uploading %s (%d)", binary.Path, size)
 
 				// TODO Make sure binary.Name is well-formed
-				//asset, _, err := gh.UploadReleaseAsset(owner, repository, *release.ID, binary.Name, file)
-				asset, _, err := gh.Client.Repositories.UploadReleaseAsset(owner, repository, *release.ID, &github.UploadOptions{Name: binary.Name}, file)
+				asset, err := gphr.Upload(ctx, provider, release, binary.Name, file, 2, lg.err)
 				if err != nil {
 					return err
 				}
 				binary.Asset = *asset
+
+				uploaded := newAsset(provider, release, binary.Asset)
+				uploaded.Size = size
+				uploaded.Digest, err = digest(binary.Path)
+				if err != nil {
+					return err
+				}
+				released.Uploaded = append(released.Uploaded, uploaded)
+				program := released.program(binary.Program)
+				program.Uploaded = append(program.Uploaded, binary.Name)
 			}
 
-			table := tabwriter.NewWriter(os.Stdout, 0, 8, 2, '\t', 0)
-			for _, binary := range binaries {
-				fmt.Fprintf(table, "%s\t%s\n", binary.Name, "https://"+gh.Location()+"/releases/download/"+*release.TagName+"/"+*binary.Asset.Name)
+			// 7a. Build (and upload) a package (.deb, .rpm, .apk) of every linux binary.
+			if len(formats) > 0 && !*flags.main.dryRun {
+				assets, err := provider.GetReleaseAssets(release.RepositoryRelease)
+				if err != nil {
+					return err
+				}
+				err = uploadPackages(provider, release, assets, binaries, newPackage(provider, owner, tag, config), formats, &released)
+				if err != nil {
+					return err
+				}
+			}
+
+			// 7b. Generate (and upload) the install scripts for the assets of the release.
+			// 7c. Generate the Homebrew formula and Scoop manifest (of every program).
+			var scripts []github.ReleaseAsset
+			if (*flags.release.installScript || *flags.release.homebrew != "" || *flags.release.scoop != "") && !*flags.main.dryRun {
+				assets, err := provider.GetReleaseAssets(release.RepositoryRelease)
+				if err != nil {
+					return err
+				}
+				digests := map[string]string{}
+				for _, asset := range released.Uploaded {
+					digests[asset.Name] = asset.Digest
+				}
+				install, err := newInstall(provider, repository, release, assets, digests)
+				if err != nil {
+					return err
+				}
+				if *flags.release.installScript {
+					scripts, err = uploadInstallScripts(provider, release, assets, install)
+					if err != nil {
+						return err
+					}
+					for _, asset := range scripts {
+						released.Uploaded = append(released.Uploaded, newAsset(provider, release, asset))
+					}
+				}
+				released.Manifests, err = writeManifests(install)
+				if err != nil {
+					return err
+				}
+			}
+
+			if output == "text" {
+				table := tabwriter.NewWriter(os.Stdout, 0, 8, 2, '\t', 0)
+				url := map[string]string{}
+				for _, asset := range released.Uploaded {
+					url[asset.Name] = asset.URL
+				}
+				for _, program := range released.Programs {
+					// Grouped by program, if there is more than one (or any is missing a platform)
+					indent := ""
+					if len(released.Programs) > 1 || len(program.Missing) > 0 {
+						line := program.Name
+						if len(program.Missing) > 0 {
+							line += " (missing " + strings.Join(program.Missing, ", ") + ")"
+						}
+						fmt.Fprintln(table, line)
+						indent = "  "
+					}
+					for _, name := range program.Uploaded {
+						fmt.Fprintf(table, "%s%s\t%s\n", indent, name, url[name])
+					}
+				}
+				for _, asset := range scripts {
+					fmt.Fprintf(table, "%s\t%s\n", *asset.Name, provider.DownloadURL(*release.TagName, *asset.Name))
+				}
+				table.Flush()
 			}
-			table.Flush()
 
 			if !*flags.release.keep {
-				// 7. Delete matching assets from other releases.
+				// 8. Delete matching assets from other releases.
 				err = nil
 				for _, release := range releases {
 					for _, asset := range release.Assets {
 						for _, binary := range binaries {
-							if *binary.Asset.ID == *asset.ID {
+							// (In a -dry-run, nothing was uploaded, so a binary may have no asset)
+							if binary.Asset.ID != nil && *binary.Asset.ID == *asset.ID {
 								break
 							} else if binary.Match(*asset.Name) {
-								lg.dbg("delete asset => %s (%s)", *binary.Asset.Name, *binary.Asset.URL)
-								response, err := gh.Client.Repositories.DeleteReleaseAsset(owner, repository, *asset.ID)
-								if err != nil {
-									if response == nil || response.StatusCode != 404 {
-										lg.err("unable to delete (legacy) asset")
-										err = lg.error("1 or more (legacy) assets were not deleted")
-									}
+								lg.dbg("delete asset => %s (%s)", *asset.Name, *release.TagName)
+								if *flags.main.dryRun {
+									continue
+								}
+								tmp := provider.DeleteAsset(release, asset)
+								if tmp != nil {
+									lg.err("unable to delete (legacy) asset: %s (%s): %v", *asset.Name, *release.TagName, tmp)
+									err = lg.error("1 or more (legacy) assets were not deleted")
+								} else {
+									released.Deleted = append(released.Deleted, newAsset(provider, release, asset))
 								}
 							}
 						}
@@ -318,14 +741,28 @@ func main() {
 				}
 			}
 
+			switch output {
+			case "json":
+				return writeJSON(released)
+			case "table", "csv":
+				var rows [][]string
+				for _, tmp := range []struct {
+					action string
+					assets []_asset
+				}{
+					{"uploaded", released.Uploaded},
+					{"deleted", released.Deleted},
+				} {
+					for _, asset := range tmp.assets {
+						rows = append(rows, []string{tmp.action, asset.Name, asset.Tag, strconv.FormatInt(asset.Size, 10), asset.Digest, asset.URL})
+					}
+				}
+				return writeTable([]string{"ACTION", "NAME", "TAG", "SIZE", "DIGEST", "URL"}, rows)
+			}
+
 		case "get":
 			flags.get_.Parse(flags.main_.Args()[1:])
 
-			token, err := getToken()
-			if err != nil {
-				return err
-			}
-
 			// FIXME This is confusing...
 			// What cases are we handling?
 			//
@@ -347,7 +784,7 @@ func main() {
 				targetOrProgram = "" // targetOrProgram is a target
 			}
 
-			_, owner, repository, program, err := getTarget(target)
+			host, owner, repository, program, err := getTarget(target)
 			if err != nil {
 				return err
 			}
@@ -364,21 +801,50 @@ func main() {
 				binary.GOARCH = runtime.GOARCH
 			}
 
-			base := "https://github.com/" + owner + "/" + repository
+			base := "https://" + host + "/" + owner + "/" + repository
 
-			response, err := http.Get(base + "/releases/latest")
-			if err != nil {
-				return err
+			got := _got{Repository: host + "/" + owner + "/" + repository}
+			found := func(name, to string, size int64) {
+				got.Asset, got.Path, got.Size = name, to, size
+				if path, err := filepath.Abs(to); err == nil {
+					got.Path = path
+				}
 			}
-			if response.StatusCode != 200 {
-				return lg.error("!200")
+
+			// done outputs what was downloaded (unless the output is text, where
+			// "Downloading ..." is enough)
+			done := func() error {
+				switch output {
+				case "json":
+					return writeJSON(got)
+				case "table", "csv":
+					return writeTable([]string{"VERSION", "ASSET", "PATH", "SIZE"}, [][]string{
+						{got.Version, got.Asset, got.Path, strconv.FormatInt(got.Size, 10)},
+					})
+				}
+				return nil
 			}
 
+			save := func(reader io.Reader, name, to string, size int64) error {
+				lg.progress("Downloading %s => %s (%d)", name, to, size)
+				file, err := os.OpenFile(to, os.O_CREATE|os.O_WRONLY, 0755)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+
+				size, err = io.Copy(file, reader)
+				found(name, to, size)
+				return err
+			}
+
+			failed := "" // The response of the last try that was not a 200
+
 			try := func(from, name, to string, asset bool) (bool, error) {
 				if name == "" {
 					name = to
 				}
-				request, err := http.NewRequest("GET", from, nil)
+				request, err := http.NewRequestWithContext(ctx, "GET", from, nil)
 				if err != nil {
 					return false, err
 				}
@@ -386,28 +852,26 @@ func main() {
 					request.Header.Add("Accept", "application/octet-stream")
 				}
 				response, err := new(http.Client).Do(request)
+				if err != nil {
+					return false, err
+				}
+				defer response.Body.Close()
 				if response.StatusCode != 200 {
+					failed = fmt.Sprintf("GET %s: %s", request.URL, response.Status)
 					return false, nil
 				}
-				if err != nil {
-					return false, err
+
+				if got.URL == "" {
+					got.URL = from
 				}
 
 				if *flags.main.dryRun {
 					lg.dbg("download asset => %s => %s", name, to)
+					found(name, to, response.ContentLength)
 					return true, nil
 				}
 
-				defer response.Body.Close()
-
-				log("Downloading %s => %s (%d)", name, to, response.ContentLength)
-				file, err := os.OpenFile(to, os.O_CREATE|os.O_WRONLY, 0755)
-				if err != nil {
-					return false, err
-				}
-				defer file.Close()
-
-				_, err = io.Copy(file, response.Body)
+				err = save(response.Body, name, to, response.ContentLength)
 				if err != nil {
 					return false, err
 				}
@@ -415,131 +879,370 @@ func main() {
 				return true, nil
 			}
 
-			if match := regexp.MustCompile(`/[^/]+/[^/]+/releases/[^/]+/([^/]+)$`).FindStringSubmatch(response.Request.URL.Path); match != nil {
-				name := match[1]
-				base := base + "/releases/download/" + name + "/"
-
-				// An explicit get, ...
-				// gphr get github.com/alice/example/example_linux_386
-				if binary.Name != "" {
-					done, err := try(base+binary.Name, "", binary.Name, false)
-					if err != nil {
-						return err
-					}
-					if done {
-						return nil
-					}
+			// The releases of GitLab (or a store) are not laid out like GitHub (or Gitea), so go straight to the API
+			if kind := providerKind(host); kind != gphr.ProviderGitLab && getStore() == "" {
+				request, err := http.NewRequestWithContext(ctx, "GET", base+"/releases/latest", nil)
+				if err != nil {
+					return err
+				}
+				response, err := http.DefaultClient.Do(request)
+				if err != nil {
+					return err
+				}
+				if response.StatusCode != 200 {
+					response.Body.Close()
+					return lg.error("GET %s: %s", request.URL, response.Status)
 				}
 
-				// An implicit get, make a guess...
-				// gphr get github.com/alice/example
-				// gphr get github.com/alice/example/example
-				{
-					done, err := try(base+binary.Underscore(), "", binary.Underscore(), false)
-					if err != nil {
-						return err
-					}
-					if done {
-						return nil
-					}
+				if match := regexp.MustCompile(`/[^/]+/[^/]+/releases/[^/]+/([^/]+)$`).FindStringSubmatch(response.Request.URL.Path); match != nil {
+					name := match[1]
+					got.Version = name
+					base := base + "/releases/download/" + name + "/"
 
-					done, err = try(base+binary.Dash(), "", binary.Dash(), false)
-					if err != nil {
-						return err
+					// An explicit get, ...
+					// gphr get github.com/alice/example/example_linux_386
+					if binary.Name != "" {
+						ok, err := try(base+binary.Name, "", binary.Name, false)
+						if err != nil {
+							return err
+						}
+						if ok {
+							return done()
+						}
 					}
-					if done {
-						return nil
+
+					// An implicit get, make a guess...
+					// gphr get github.com/alice/example
+					// gphr get github.com/alice/example/example
+					{
+						ok, err := try(base+binary.Underscore(), "", binary.Underscore(), false)
+						if err != nil {
+							return err
+						}
+						if ok {
+							return done()
+						}
+
+						ok, err = try(base+binary.Dash(), "", binary.Dash(), false)
+						if err != nil {
+							return err
+						}
+						if ok {
+							return done()
+						}
 					}
 				}
 			}
 
-			gh, err := client(owner, repository, token)
+			token, err := getToken(host)
 			if err != nil {
 				return err
 			}
-			cl = gh.Client
 
-			releases, err := gh.GetReleases()
+			provider, err := client(host, owner, repository, token)
 			if err != nil {
-				return nil
+				return err
+			}
+			cl = rate(provider)
+
+			releases, err := provider.GetReleases()
+			if err != nil {
+				return err
 			}
 
 			for _, release := range releases {
 				for _, asset := range release.Assets {
 					if binary.Match(*asset.Name) {
 						filename := *asset.Name
+						got.Version = *release.TagName
+						got.URL = provider.DownloadURL(*release.TagName, *asset.Name)
 						if !*flags.get.preserve {
 							if binary.GOOS == runtime.GOOS && binary.GOARCH == runtime.GOARCH {
 								filename = binary.Program
 							}
 						}
 
-						_, err := try(*asset.URL, *asset.Name, filename, true)
+						if store, ok := provider.(*gphr.Store); ok {
+							if *flags.main.dryRun {
+								lg.dbg("download asset => %s => %s", *asset.Name, filename)
+								found(*asset.Name, filename, int64(*asset.Size))
+								return done()
+							}
+							reader, err := store.Download(release, asset)
+							if err != nil {
+								return err
+							}
+							defer reader.Close()
+							err = save(reader, *asset.Name, filename, int64(*asset.Size))
+							if err != nil {
+								return err
+							}
+							return done()
+						}
+
+						ok, err := try(*asset.URL, *asset.Name, filename, true)
 						if err != nil {
 							return err
 						}
+						if !ok {
+							return lg.error("cannot download %s (%s)", *asset.Name, failed)
+						}
 
-						return nil
+						return done()
 					}
 				}
 			}
 
-			log("Nothing found for %s in %s", binary.Identifier(), gh.Location())
+			return gphr.Errorf(gphr.ErrNoMatchingAsset, "nothing found for %s in %s", binary.Identifier(), provider.Location())
 
 		case "list":
-			flags.get_.Parse(flags.main_.Args()[1:])
+			flags.list_.Parse(flags.main_.Args()[1:])
+
+			targets := flags.list_.Args()
+			if len(targets) == 0 {
+				targets = []string{""} // The local repository
+			}
+
+			listings, err := getListings(targets)
+			if err != nil {
+				return err
+			}
+			for _, listing := range listings {
+				cl = rate(listing.provider)
+			}
 
-			token, err := getToken()
+			return list(listings, output)
+
+		case "stats":
+			flags.stats_.Parse(flags.main_.Args()[1:])
+
+			targets := flags.stats_.Args()
+			if len(targets) == 0 {
+				targets = []string{""} // The local repository
+			}
+
+			listings, err := getListings(targets)
 			if err != nil {
 				return err
 			}
+			for _, listing := range listings {
+				cl = rate(listing.provider)
+			}
+
+			return stats(listings, output)
+
+		case "serve":
+			flags.serve_.Parse(flags.main_.Args()[1:])
 
-			_, owner, repository, _, err := getTarget(flags.main_.Arg(1))
+			owners := listSet(*flags.serve.owner)
+			if len(owners) == 0 {
+				// Otherwise, anything the token can see (with -proxy, even private) is served
+				return gphr.Errorf(errUsage, "missing -owner: the owners of the repositories served")
+			}
+
+			host := *flags.serve.host
+			token, err := getToken(host)
 			if err != nil {
 				return err
 			}
 
-			gh, err := client(owner, repository, token)
+			redirector := gphr.NewRedirector(func(owner, repository string) (gphr.Provider, error) {
+				return client(host, owner, repository, token)
+			})
+			for owner := range owners {
+				redirector.Owners = append(redirector.Owners, owner)
+			}
+			redirector.Proxy = *flags.serve.proxy
+			redirector.TTL = *flags.serve.ttl
+			redirector.Log = lg.err
+
+			server := &http.Server{
+				Addr:    *flags.serve.listen,
+				Handler: redirector,
+			}
+			go func() {
+				<-ctx.Done()
+				server.Shutdown(context.Background())
+			}()
+
+			lg.err("Listening on %s (for %s)", server.Addr, host)
+			err = server.ListenAndServe()
+			if err == http.ErrServerClosed {
+				return ctx.Err()
+			}
+			return err
+
+		case "check":
+			var err error
+
+			flags.check_.Parse(flags.main_.Args()[1:])
+
+			tag := flags.check_.Arg(0)
+			if tag == "" {
+				tag, err = gitGetTag()
+				if err != nil {
+					return err
+				}
+				if tag == "" {
+					return gphr.Errorf(gphr.ErrNotTagged, "no tag given (and HEAD is not tagged)")
+				}
+			}
+
+			host, owner, repository, err := getRepository(*flags.check.repository)
 			if err != nil {
 				return err
 			}
-			cl = gh.Client
 
-			releases, err := gh.GetReleases()
+			token, err := getToken(host)
 			if err != nil {
 				return err
 			}
 
-			if len(releases) == 0 {
-				log("There are no releases for %s", gh.Location())
+			provider, err := client(host, owner, repository, token)
+			if err != nil {
+				return err
+			}
+			cl = rate(provider)
+
+			config, err := getConfig()
+			if err != nil {
+				return err
+			}
+			requirements, err := getRequirements(config, *flags.check.require)
+			if err != nil {
+				return err
+			}
+			requirements.program = repository
+			return check(provider, requirements, tag, output)
+
+		case "changelog":
+			flags.changelog_.Parse(flags.main_.Args()[1:])
+
+			host, owner, repository, err := getRepository(*flags.changelog.repository)
+			if err != nil {
+				return err
+			}
+
+			token, err := getToken(host)
+			if err != nil {
+				return err
+			}
+
+			provider, err := client(host, owner, repository, token)
+			if err != nil {
+				return err
+			}
+			cl = rate(provider)
+
+			return changelog(provider, *flags.changelog.file)
+
+		case "cache":
+			flags.cache_.Parse(flags.main_.Args()[1:])
+
+			if subcommand := flags.cache_.Arg(0); subcommand != "clean" {
+				return lg.error("invalid cache command: %s (not clean)", subcommand)
+			}
+
+			cache, err := getCache()
+			if err != nil {
+				return err
+			}
+			if cache == nil {
+				log("There is no cache (GPHR_CACHE=off)")
 				return nil
 			}
+			count, size, err := cache.Clean()
+			if err != nil {
+				return err
+			}
+			log("Removed %d responses (%d bytes) from %s", count, size, cache.Dir)
 
-			found := false
-			for _, release := range releases {
-				for _, asset := range release.Assets {
-					if matchBinary.MatchString(*asset.Name) {
-						found = true
-						log("%v %v", *asset.Name, *release.TagName)
-					}
+		case "auth":
+			flags.auth_.Parse(flags.main_.Args()[1:])
+
+			if subcommand := flags.auth_.Arg(0); subcommand != "status" {
+				return lg.error("invalid auth command: %s (not status)", subcommand)
+			}
+
+			host, owner, repository := flags.auth_.Arg(1), "", ""
+			if host == "" {
+				host, owner, repository, _ = gitGetGitHubURL()
+				if host == "" {
+					host = "github.com"
 				}
 			}
 
-			if !found {
-				log("There are no assets (or no gphr assets) for %s", gh.Location())
+			app, err := getApp()
+			if err != nil {
+				return err
+			}
+			if app != nil && providerKind(host) == gphr.ProviderGitHub {
+				log("%s", host)
+				log("  App: %s", app.ID)
+				if owner == "" && app.InstallationID == 0 {
+					return lg.error("cannot find the installation of app %s without a repository (run from within one, or set GPHR_APP_INSTALLATION_ID)", app.ID)
+				}
+				provider, err := client(host, owner, repository, "")
+				if err != nil {
+					return err
+				}
+				token, expires, err := provider.(*gphr.GitHub).App.Token(false)
+				if err != nil {
+					return err
+				}
+				log("  Token: %s (installation token, expires %s)", maskToken(token), expires.Local().Format(time.RFC3339))
 				return nil
 			}
 
-		case "test":
-			flags.get_.Parse(flags.main_.Args()[1:])
+			credential, err := getCredential(host)
+			if err != nil {
+				return err
+			}
+
+			log("%s", host)
+			if credential == nil {
+				log("  Token: (none)")
+				return gphr.Errorf(gphr.ErrAuth, "no token for %s (-token, GPHR_TOKEN, %s, git credential fill, ~/.netrc, or gh auth login)", host, tokenVariable(host))
+			}
+			log("  Token: %s (from %s)", maskToken(credential.Token), credential.Source)
 
-			token, err := getToken()
+			provider, err := client(host, owner, repository, credential.Token)
 			if err != nil {
 				return err
 			}
+			cl = rate(provider)
 
-			owner, repository, err := gitGetGitHubURL()
-			log("owner=%s repository=%s err=%v\n", owner, repository, err)
+			gh, ok := provider.(*gphr.GitHub)
+			if !ok {
+				log("  Scopes: (unknown, not GitHub)")
+				return nil
+			}
+			login, scopes, err := gh.GetAuthenticatedUser()
+			if err != nil {
+				return err
+			}
+			log("  Login: %s", login)
+			if len(scopes) == 0 {
+				log("  Scopes: (none, or a fine-grained token)")
+			} else {
+				log("  Scopes: %s", strings.Join(scopes, ", "))
+			}
+
+		case "tag":
+			flags.tag_.Parse(flags.main_.Args()[1:])
+
+			part := flags.tag_.Arg(0)
+			if part == "" {
+				part = "patch"
+			}
+
+			return tag(part)
+
+		case "test":
+			flags.get_.Parse(flags.main_.Args()[1:])
+
+			host, owner, repository, err := gitGetGitHubURL()
+			log("host=%s owner=%s repository=%s err=%v\n", host, owner, repository, err)
 
 			tag, err := gitGetTag()
 			log("tag=%s err=%v\n", tag, err)
@@ -547,14 +1250,19 @@ func main() {
 			commit, err := gitGetTagCommit(tag)
 			log("commit=%s err=%v\n", commit, err)
 
-			gh, err := client(owner, repository, token)
+			token, err := getToken(host)
 			if err != nil {
 				return err
 			}
-			cl = gh.Client
+
+			provider, err := client(host, owner, repository, token)
+			if err != nil {
+				return err
+			}
+			cl = rate(provider)
 
 			if tag != "" {
-				commit, _, err = gh.GetCommit(tag)
+				commit, err = provider.ResolveTag(tag)
 				log("commit=%s err=%v\n", commit, err)
 			}
 
@@ -563,7 +1271,7 @@ func main() {
 			return nil
 
 		default:
-			return lg.error("invalid command: %s", command)
+			return gphr.Errorf(errUsage, "invalid command: %s", command)
 		}
 
 		return nil
@@ -575,8 +1283,8 @@ func main() {
 		}
 	}
 
-	if err != nil {
-		lg.err("%s", err.Error())
-		os.Exit(1)
+	if err != nil && errors.Is(err, context.Canceled) && ctx.Err() != nil {
+		return errInterrupted
 	}
+	return err
 }
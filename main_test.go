@@ -0,0 +1,726 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"./gphr/gphrtest"
+	"./gphr/terst"
+)
+
+// _endToEnd is a local repository (pushed to a bare "origin") for a repository on a
+// fake GitHub, with gphr run from within it.
+type _endToEnd struct {
+	t      *testing.T
+	server *gphrtest.Server
+	dir    string // The temporary directory (the bare remote, the repository, and binaries)
+	target string // 127.0.0.1:49152/alice/example
+
+	cwd       string
+	transport http.RoundTripper
+	environ   []string
+}
+
+func newEndToEnd(t *testing.T) *_endToEnd {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "gphr-e2e-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, _ = filepath.EvalSymlinks(dir)
+
+	server := gphrtest.NewServer()
+	server.Token = "xyzzy"
+	server.PerPage = 1 // Everything is paginated
+	server.Repository("alice", "example")
+
+	test := &_endToEnd{
+		t:         t,
+		server:    server,
+		dir:       dir,
+		target:    server.Host() + "/alice/example",
+		transport: http.DefaultTransport,
+	}
+	test.cwd, _ = os.Getwd()
+
+	// No token from anywhere but -token (the environment, ~/.netrc, gh, ...)
+	test.environ = os.Environ()
+	for _, name := range []string{"GPHR_TOKEN", "GH_TOKEN", "GITHUB_TOKEN", "GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN", "GPHR_APP_ID", "GPHR_APP_KEY", "GPHR_APP_INSTALLATION_ID", "GPHR_CACHE_SIZE", "NETRC", "GH_CONFIG_DIR", "XDG_CONFIG_HOME", "XDG_CACHE_HOME", "XDG_DATA_HOME", "GPHR_STATS", "GIT_CONFIG_GLOBAL"} {
+		os.Unsetenv(name)
+	}
+	os.Setenv("HOME", dir)
+	os.Setenv("GPHR_CACHE", filepath.Join(dir, "cache"))
+
+	// get downloads with http.DefaultClient
+	http.DefaultTransport = server.Transport()
+
+	test.git(dir, "init", "-q", "--bare", "origin.git")
+	test.git(dir, "init", "-q", "example")
+	test.git(test.repository(), "config", "url."+filepath.Join(dir, "origin.git")+".insteadOf", "https://"+test.target+".git")
+	test.git(test.repository(), "remote", "add", "origin", "https://"+test.target+".git")
+
+	err = os.Chdir(test.repository())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return test
+}
+
+func (test *_endToEnd) close() {
+	os.Chdir(test.cwd)
+	os.Clearenv()
+	for _, variable := range test.environ {
+		if index := strings.Index(variable, "="); index > 0 {
+			os.Setenv(variable[:index], variable[index+1:])
+		}
+	}
+	http.DefaultTransport = test.transport
+	test.server.Close()
+	os.RemoveAll(test.dir)
+}
+
+func (test *_endToEnd) repository() string {
+	return filepath.Join(test.dir, "example")
+}
+
+func (test *_endToEnd) git(dir string, arguments ...string) string {
+	cmd := exec.Command("git", arguments...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Alice", "GIT_AUTHOR_EMAIL=alice@example.com",
+		"GIT_COMMITTER_NAME=Alice", "GIT_COMMITTER_EMAIL=alice@example.com",
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		test.t.Fatalf("git %s: %v: %s", strings.Join(arguments, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// tag commits, tags (and pushes) <tag>, also tagging it on the fake GitHub.
+func (test *_endToEnd) tag(tag string) {
+	repository := test.repository()
+	test.git(repository, "commit", "-q", "--allow-empty", "-m", "feat: "+tag)
+	test.git(repository, "tag", "-a", "-m", "Release "+tag, tag)
+	test.git(repository, "push", "-q", "origin", "HEAD:refs/heads/master", tag)
+	test.git(repository, "fetch", "-q", "origin")
+	test.server.Repository("alice", "example").Tag(tag, test.git(repository, "rev-parse", "HEAD"))
+}
+
+// binary writes a binary <name> (outside of the repository), returning its path.
+func (test *_endToEnd) binary(name, content string) string {
+	path := filepath.Join(test.dir, "dist", name)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	err := ioutil.WriteFile(path, []byte(content), 0755)
+	if err != nil {
+		test.t.Fatal(err)
+	}
+	return path
+}
+
+// run runs gphr with <arguments>, returning what was written to stdout.
+func (test *_endToEnd) run(arguments ...string) (string, error) {
+	flags = newFlags()
+
+	output, err := ioutil.TempFile(test.dir, "stdout-")
+	if err != nil {
+		test.t.Fatal(err)
+	}
+	defer output.Close()
+
+	stdout := os.Stdout
+	os.Stdout = output
+	err = run(append([]string{"-token=xyzzy"}, arguments...))
+	os.Stdout = stdout
+
+	content, _ := ioutil.ReadFile(output.Name())
+	return string(content), err
+}
+
+// assets returns the assets of every release (tag:name, ...), newest first.
+func (test *_endToEnd) assets() string {
+	repository := test.server.Repository("alice", "example")
+	var assets []string
+	for index := len(repository.Releases) - 1; index >= 0; index-- {
+		release := repository.Releases[index]
+		for _, asset := range release.Assets {
+			assets = append(assets, *release.TagName+":"+*asset.Name+"="+string(asset.Content))
+		}
+	}
+	return strings.Join(assets, " ")
+}
+
+// released creates (on the fake GitHub) the releases that list, get, and stats
+// start from: v1.0.0 (example_darwin_amd64) and v1.1.0 (example_linux_386).
+func (test *_endToEnd) released() {
+	repository := test.server.Repository("alice", "example")
+	repository.CreateRelease("v1.0.0").CreateAsset("example_darwin_amd64", []byte("darwin-1"))
+	repository.CreateRelease("v1.1.0").CreateAsset("example_linux_386", []byte("linux-3"))
+}
+
+// config writes (and commits) .gphr with <content>.
+func (test *_endToEnd) config(content string) {
+	err := ioutil.WriteFile(filepath.Join(test.repository(), ".gphr"), []byte(content), 0644)
+	if err != nil {
+		test.t.Fatal(err)
+	}
+	test.git(test.repository(), "add", ".gphr")
+	test.git(test.repository(), "commit", "-q", "-m", "chore: .gphr")
+}
+
+func TestEndToEndRelease(t *testing.T) {
+	test := newEndToEnd(t)
+	defer test.close()
+
+	terst.Terst(t, func() {
+		repository := test.server.Repository("alice", "example")
+		linux, darwin := test.binary("example_linux_386", "linux-1"), test.binary("example_darwin_amd64", "darwin-1")
+
+		// release (a new release)
+		test.tag("v1.0.0")
+		output, err := test.run("release", "-repository="+test.target, linux, darwin)
+		is(err, nil)
+		is(output, strings.Join([]string{
+			"Uploading " + linux + " (7)",
+			"Uploading " + darwin + " (8)",
+			"example_linux_386\t" + test.server.URL + "/alice/example/releases/download/v1.0.0/example_linux_386",
+			"example_darwin_amd64\t" + test.server.URL + "/alice/example/releases/download/v1.0.0/example_darwin_amd64",
+			"",
+		}, "\n"))
+		is(test.assets(), "v1.0.0:example_linux_386=linux-1 v1.0.0:example_darwin_amd64=darwin-1")
+		release := repository.Release("v1.0.0")
+		is(*release.Name, "v1.0.0")
+		is(*release.Body, "Release v1.0.0")
+
+		// release (an existing asset)
+		_, err = test.run("release", "-repository="+test.target, linux)
+		is(err, "1 or more assets with the same name already exist")
+		is(exitCode(err), 6)
+		test.binary("example_linux_386", "linux-2")
+		_, err = test.run("release", "-repository="+test.target, "-force", linux)
+		is(err, nil)
+		is(test.assets(), "v1.0.0:example_darwin_amd64=darwin-1 v1.0.0:example_linux_386=linux-2")
+
+		// release (a new release, deleting the asset of the same kind from the old release)
+		test.tag("v1.1.0")
+		test.binary("example_linux_386", "linux-3")
+		output, err = test.run("-output=json", "release", "-repository="+test.target, linux)
+		is(err, nil)
+		is(test.assets(), "v1.1.0:example_linux_386=linux-3 v1.0.0:example_darwin_amd64=darwin-1")
+		var released _released
+		is(json.Unmarshal([]byte(output), &released), nil)
+		is(released.Tag, "v1.1.0")
+		is(len(released.Uploaded), 1)
+		is(released.Uploaded[0].Name, "example_linux_386")
+		is(released.Uploaded[0].URL, test.server.URL+"/alice/example/releases/download/v1.1.0/example_linux_386")
+		is(released.Uploaded[0].Size, 7)
+		is(released.Uploaded[0].Digest, "sha256:be44024bb06ae28e2de53c3a5925b33e979f4430f8c2a39722569337c02cb6d9")
+		is(len(released.Deleted), 1)
+		is(released.Deleted[0].Name, "example_linux_386")
+		is(released.Deleted[0].Tag, "v1.0.0")
+
+		// release -dry-run (a new asset for an existing release: nothing is uploaded, or deleted)
+		_, err = test.run("-dry-run", "release", "-repository="+test.target, darwin)
+		is(err, nil)
+		is(test.assets(), "v1.1.0:example_linux_386=linux-3 v1.0.0:example_darwin_amd64=darwin-1")
+
+		// release -dry-run (a new release: nothing is created, but the output is still written)
+		test.tag("v1.2.0")
+		output, err = test.run("-dry-run", "-output=json", "release", "-repository="+test.target, linux)
+		is(err, nil)
+		released = _released{}
+		is(json.Unmarshal([]byte(output), &released), nil)
+		is(released.Tag, "v1.2.0")
+		is(len(released.Uploaded), 0)
+		is(len(released.Deleted), 0)
+		is(len(released.Programs), 1)
+		is(repository.Release("v1.2.0") == nil, true)
+		is(test.assets(), "v1.1.0:example_linux_386=linux-3 v1.0.0:example_darwin_amd64=darwin-1")
+
+		// release (the remote tag does not match)
+		repository.Tag("v1.2.0", "0123456789abcdef0123456789abcdef01234567")
+		_, err = test.run("release", "-repository="+test.target, linux)
+		is(err, `tag "v1.2.0" (0123456789abcdef0123456789abcdef01234567) does not match "`+test.git(test.repository(), "rev-parse", "HEAD")+`" in the local repository`)
+		is(exitCode(err), 5)
+
+		// release (no token)
+		flags = newFlags()
+		err = run([]string{"release", linux})
+		is(err, "cannot release without a token (-token, GPHR_TOKEN, GH_ENTERPRISE_TOKEN, ...), see: gphr auth status")
+		is(exitCode(err), 7)
+	})
+}
+
+func TestEndToEndWithoutGit(t *testing.T) {
+	test := newEndToEnd(t)
+	defer test.close()
+
+	path := os.Getenv("PATH")
+	withoutGit := func() {
+		os.Setenv("PATH", filepath.Join(test.dir, "bin")) // Nothing there
+		_, err := exec.LookPath("git")
+		if err == nil {
+			t.Fatal("git is still in PATH")
+		}
+	}
+
+	terst.Terst(t, func() {
+		repository := test.server.Repository("alice", "example")
+		linux := test.binary("example_linux_386", "linux-1")
+
+		// release (the notes from the message of the annotated tag)
+		test.tag("v1.0.0")
+		withoutGit()
+		_, err := test.run("release", "-repository="+test.target, linux)
+		is(err, nil)
+		is(*repository.Release("v1.0.0").Body, "Release v1.0.0")
+
+		// release (the notes from the commits since the previous tag)
+		os.Setenv("PATH", path)
+		test.git(test.repository(), "commit", "-q", "--allow-empty", "-m", "fix: One")
+		test.git(test.repository(), "commit", "-q", "--allow-empty", "-m", "feat: Two")
+		test.git(test.repository(), "tag", "v1.1.0")
+		test.git(test.repository(), "push", "-q", "origin", "HEAD:refs/heads/master", "v1.1.0")
+		repository.Tag("v1.1.0", test.git(test.repository(), "rev-parse", "HEAD"))
+		withoutGit()
+		_, err = test.run("release", "-repository="+test.target, linux)
+		is(err, nil)
+		is(*repository.Release("v1.1.0").Body, "### Features\n\n* Two\n\n### Bug Fixes\n\n* One\n\nv1.0.0...v1.1.0")
+
+		// changelog
+		_, err = test.run("changelog", "-repository="+test.target)
+		is(err, nil)
+		content, err := ioutil.ReadFile("CHANGELOG.md")
+		is(err, nil)
+		is(strings.Contains(string(content), "\n### Added\n- Two\n\n### Fixed\n- One\n"), true)
+
+		// tag (which does need git)
+		_, err = test.run("tag", "patch")
+		is(err, "cannot tag without git (-git=native): tag runs git tag and git push")
+	})
+}
+
+func TestEndToEndAuth(t *testing.T) {
+	test := newEndToEnd(t)
+	defer test.close()
+
+	terst.Terst(t, func() {
+		output, err := test.run("auth", "status", test.server.Host())
+		is(err, nil)
+		is(output, test.server.Host()+"\n  Token: ***** (from -token)\n  Login: alice\n  Scopes: repo\n")
+
+		os.Setenv("GH_ENTERPRISE_TOKEN", "ghp_0123456789abcdefghij")
+		flags = newFlags()
+		err = run([]string{"auth", "status", test.server.Host()})
+		is(err, "GET "+test.server.URL+"/api/v3/user: 401 Bad credentials []")
+		is(exitCode(err), 7)
+		os.Unsetenv("GH_ENTERPRISE_TOKEN")
+
+		// As a GitHub App
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		is(err, nil)
+		test.server.AppID, test.server.AppKey = "12345", &key.PublicKey
+		os.Setenv("GPHR_APP_ID", "12345")
+		os.Setenv("GPHR_APP_KEY", string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})))
+		output, err = test.run("auth", "status")
+		is(err, nil)
+		is(strings.HasPrefix(output, test.server.Host()+"\n  App: 12345\n  Token: ghs_********************************0001 (installation token, expires "), true)
+	})
+}
+
+func TestEndToEndList(t *testing.T) {
+	test := newEndToEnd(t)
+	defer test.close()
+	test.released()
+
+	terst.Terst(t, func() {
+		// Twice, the second time revalidated from the cache
+		output, err := test.run("list", test.target)
+		is(err, nil)
+		is(output, "example_linux_386 v1.1.0\nexample_darwin_amd64 v1.0.0\n")
+		notModified := test.server.NotModified
+		output, err = test.run("list", test.target)
+		is(err, nil)
+		is(output, "example_linux_386 v1.1.0\nexample_darwin_amd64 v1.0.0\n")
+		is(test.server.NotModified > notModified, true)
+
+		output, err = test.run("-output=json", "list", test.target)
+		is(err, nil)
+		var releases []_release
+		is(json.Unmarshal([]byte(output), &releases), nil)
+		is(len(releases), 2)
+		is(releases[0].Tag, "v1.1.0")
+		is(len(releases[0].Assets), 1)
+		is(releases[0].Assets[0].Name, "example_linux_386")
+		is(releases[1].Assets[0].Name, "example_darwin_amd64")
+		is(releases[0].Missing, []string{"example darwin/amd64"})
+
+		output, err = test.run("-output=table", "list", test.target)
+		is(err, nil)
+		is(strings.Split(output, "\n")[0], "NAME                  TAG     SIZE  URL")
+
+		_, err = test.run("-output=xyzzy", "list", test.target)
+		is(err, "invalid -output: xyzzy (json, table, csv, or text)")
+		is(exitCode(err), 2)
+
+		// Filtered, with columns, grouped
+		output, err = test.run("list", "-os=linux", "-columns=name,tag,size,downloads,uploader", test.target)
+		is(err, nil)
+		is(output, "example_linux_386 v1.1.0 7 0 alice\n")
+		output, err = test.run("list", "-tag=v1.0.0..v1.0.9", test.target)
+		is(err, nil)
+		is(output, "example_darwin_amd64 v1.0.0\n")
+		output, err = test.run("list", "-tag=v1.1.0", "-group=release", test.target)
+		is(err, nil)
+		is(output, "v1.1.0\n    example_linux_386 v1.1.0\n")
+		output, err = test.run("list", "-group=platform", "-columns=name", test.target)
+		is(err, nil)
+		is(output, "darwin/amd64\n    example_darwin_amd64\nlinux/386\n    example_linux_386\n")
+		output, err = test.run("list", "-prerelease=only", test.target)
+		is(err, nil)
+		is(output, "There are no assets (or no gphr assets) for "+test.target+"\n")
+		_, err = test.run("list", "-columns=xyzzy", test.target)
+		is(err, "invalid -columns: xyzzy (name, tag, program, platform, size, downloads, created, uploader, or url)")
+
+		// More than one repository, or every repository of the owner
+		other := test.server.Repository("alice", "other")
+		other.CreateRelease("v0.1.0").CreateAsset("other_linux_amd64", []byte("other-1"))
+		output, err = test.run("list", "-os=linux", test.target, test.server.Host()+"/alice/other")
+		is(err, nil)
+		is(output, test.target+"\n    example_linux_386 v1.1.0\n"+test.server.Host()+"/alice/other\n    other_linux_amd64 v0.1.0\n")
+		output, err = test.run("-output=table", "list", "-columns=name", test.server.Host()+"/alice")
+		is(err, nil)
+		is(output, strings.Join([]string{
+			"REPOSITORY" + strings.Repeat(" ", len(test.target)-len("REPOSITORY")) + "  NAME",
+			test.target + "  example_linux_386",
+			test.target + "  example_darwin_amd64",
+			test.server.Host() + "/alice/other    other_linux_amd64",
+			"",
+		}, "\n"))
+		output, err = test.run("-output=json", "list", test.server.Host()+"/alice")
+		is(err, nil)
+		var repositories []_repositoryListing
+		is(json.Unmarshal([]byte(output), &repositories), nil)
+		is(len(repositories), 2)
+		is(repositories[1].Repository, test.server.Host()+"/alice/other")
+		is(repositories[1].Releases[0].Assets[0].Name, "other_linux_amd64")
+		_, err = test.run("list", test.server.Host()+"/xyzzy")
+		is(err, "GET "+test.server.URL+"/api/v3/users/xyzzy/repos: 404 Not Found []")
+	})
+}
+
+func TestEndToEndGet(t *testing.T) {
+	test := newEndToEnd(t)
+	defer test.close()
+	test.released()
+
+	terst.Terst(t, func() {
+		is(os.Chdir(test.dir), nil)
+
+		// From the latest release
+		_, err := test.run("get", test.target+"/example_linux_386")
+		is(err, nil)
+		content, err := ioutil.ReadFile(filepath.Join(test.dir, "example_linux_386"))
+		is(err, nil)
+		is(string(content), "linux-3")
+
+		// From an older release, via the API
+		output, err := test.run("-output=json", "get", test.target+"/example_darwin_amd64")
+		is(err, nil)
+		filename := "example_darwin_amd64"
+		if runtime.GOOS == "darwin" && runtime.GOARCH == "amd64" {
+			filename = "example"
+		}
+		var got _got
+		is(json.Unmarshal([]byte(output), &got), nil)
+		is(got.Version, "v1.0.0")
+		is(got.Asset, "example_darwin_amd64")
+		is(got.Path, filepath.Join(test.dir, filename))
+		is(got.Size, 8)
+		content, err = ioutil.ReadFile(filepath.Join(test.dir, filename))
+		is(err, nil)
+		is(string(content), "darwin-1")
+
+		// A download that fails (the API of a private repository, without the token)
+		test.server.Private = true
+		_, err = test.run("-output=json", "get", test.target+"/example_darwin_amd64")
+		is(strings.HasPrefix(err.Error(), "cannot download example_darwin_amd64 (GET "+test.server.URL+"/api/v3/repos/alice/example/releases/assets/"), true)
+		is(strings.HasSuffix(err.Error(), ": 404 Not Found)"), true)
+		test.server.Private = false
+
+		// Nothing
+		_, err = test.run("get", test.target+"/example_windows_386")
+		is(err, "nothing found for example-windows-386 in "+test.target)
+		is(exitCode(err), 9)
+	})
+}
+
+func TestEndToEndStats(t *testing.T) {
+	test := newEndToEnd(t)
+	defer test.close()
+	test.released()
+
+	terst.Terst(t, func() {
+		download := func(tag, name string) {
+			response, err := http.Get(test.server.URL + "/alice/example/releases/download/" + tag + "/" + name)
+			is(err, nil)
+			response.Body.Close()
+		}
+		download("v1.1.0", "example_linux_386")
+		download("v1.0.0", "example_darwin_amd64")
+
+		// Twice, with a download in between
+		output, err := test.run("-output=json", "stats", test.target)
+		is(err, nil)
+		var stats _stats
+		is(json.Unmarshal([]byte(output), &stats), nil)
+		is(stats.Downloads, 2)
+		is(stats.Since, nil)
+		is(len(stats.Versions), 2)
+		is(stats.Programs[0].Key, "example")
+		is(stats.Programs[0].Downloads, 2)
+		_, err = os.Stat(filepath.Join(test.dir, ".local/share/gphr/stats", strings.Replace(test.target, ":", "_", -1)+".json"))
+		is(err, nil)
+
+		download("v1.1.0", "example_linux_386")
+		output, err = test.run("stats", "-by=platform", test.target)
+		is(err, nil)
+		lines := strings.Split(output, "\n")
+		is(strings.HasPrefix(lines[0], test.target+": 3 downloads (+1 since "), true)
+		is(lines[2:5], []string{
+			"    PLATFORM      DOWNLOADS  GROWTH",
+			"    linux/386     2          +1",
+			"    darwin/amd64  1          +0",
+		})
+
+		output, err = test.run("-output=csv", "stats", "-by=program", "-save=false", test.target)
+		is(err, nil)
+		is(output, "REPOSITORY,BY,KEY,DOWNLOADS,GROWTH\n"+test.target+",program,example,3,+0\n")
+	})
+}
+
+func TestEndToEndCacheClean(t *testing.T) {
+	test := newEndToEnd(t)
+	defer test.close()
+	test.released()
+
+	terst.Terst(t, func() {
+		_, err := test.run("list", test.target)
+		is(err, nil)
+
+		output, err := test.run("cache", "clean")
+		is(err, nil)
+		is(strings.HasPrefix(output, "Removed "), true)
+		is(strings.HasSuffix(output, " from "+filepath.Join(test.dir, "cache")+"\n"), true)
+		output, err = test.run("cache", "clean")
+		is(err, nil)
+		is(output, "Removed 0 responses (0 bytes) from "+filepath.Join(test.dir, "cache")+"\n")
+	})
+}
+
+func TestEndToEndServe(t *testing.T) {
+	test := newEndToEnd(t)
+	defer test.close()
+
+	terst.Terst(t, func() {
+		_, err := test.run("serve", "-host="+test.server.Host())
+		is(err, "missing -owner: the owners of the repositories served")
+		is(exitCode(err), 2)
+	})
+}
+
+func TestEndToEndInstallScript(t *testing.T) {
+	test := newEndToEnd(t)
+	defer test.close()
+
+	terst.Terst(t, func() {
+		// Twice, the second time with the digest of the asset already there downloaded
+		test.tag("v1.3.0")
+		linux, windows := test.binary("example_linux_386", "linux-4"), test.binary("example_windows_amd64.exe", "windows-1")
+		output, err := test.run("release", "-repository="+test.target, "-install-script", linux)
+		is(err, nil)
+		is(strings.Contains(output, "\t"+test.server.URL+"/alice/example/releases/download/v1.3.0/install.sh\n"), true)
+		_, err = test.run("release", "-repository="+test.target, "-install-script", windows)
+		is(err, nil)
+		release := test.server.Repository("alice", "example").Release("v1.3.0")
+		var names []string
+		for _, asset := range release.Assets {
+			names = append(names, *asset.Name)
+		}
+		is(names, []string{"example_linux_386", "example_windows_amd64.exe", "install.sh", "install.ps1"})
+		script := string(release.Asset("install.sh").Content)
+		is(strings.Contains(script, "\nexample_linux_386 "+digestOf("linux-4")+" "+test.server.URL+"/alice/example/releases/download/v1.3.0/example_linux_386\n"), true)
+		is(strings.Contains(script, "\nexample_windows_amd64.exe "+digestOf("windows-1")+" "), true)
+		script = string(release.Asset("install.ps1").Content)
+		is(strings.Contains(script, `@{ Name = "example_windows_amd64.exe"; Digest = "`+digestOf("windows-1")+`"; `), true)
+		is(strings.Contains(script, "example_linux_386"), false)
+	})
+}
+
+func TestEndToEndManifest(t *testing.T) {
+	test := newEndToEnd(t)
+	defer test.close()
+
+	terst.Terst(t, func() {
+		test.tag("v1.3.0")
+		linux, windows := test.binary("example_linux_386", "linux-4"), test.binary("example_windows_amd64.exe", "windows-1")
+		_, err := test.run("release", "-repository="+test.target, linux, windows)
+		is(err, nil)
+
+		// -homebrew (to a directory) -scoop (committed to a bucket), twice
+		bucket := test.server.Repository("alice", "scoop-bucket")
+		tap := filepath.Join(test.dir, "tap")
+		for range []int{0, 1} {
+			output, err := test.run("-output=json", "release", "-repository="+test.target, "-force", "-homebrew="+tap, "-scoop="+test.server.Host()+"/alice/scoop-bucket", windows)
+			is(err, nil)
+			var released _released
+			is(json.Unmarshal([]byte(output), &released), nil)
+			is(released.Manifests, []string{filepath.Join(tap, "example.rb"), test.server.Host() + "/alice/scoop-bucket/bucket/example.json"})
+		}
+		is(bucket.Commits, []string{"example 1.3.0"}) // Once, the second time it is up to date
+		content, err := ioutil.ReadFile(filepath.Join(tap, "example.rb"))
+		is(err, nil)
+		is(strings.Contains(string(content), "\n  on_linux do\n    if Hardware::CPU.intel? && !Hardware::CPU.is_64_bit?\n      url \""+test.server.URL+"/alice/example/releases/download/v1.3.0/example_linux_386\"\n      sha256 \""+digestOf("linux-4")+"\"\n"), true)
+		var manifest _scoop
+		is(json.Unmarshal(bucket.Files["bucket/example.json"], &manifest), nil)
+		is(manifest.Version, "1.3.0")
+		is(manifest.Architecture["64bit"].Hash, digestOf("windows-1"))
+	})
+}
+
+func TestEndToEndPackage(t *testing.T) {
+	test := newEndToEnd(t)
+	defer test.close()
+
+	terst.Terst(t, func() {
+		// With the rest from .gphr
+		test.config("[package]\n\tformats = deb, apk\n\tmaintainer = Alice <alice@example.com>\n")
+		test.tag("v1.4.0-rc.1")
+		linux, windows := test.binary("example_linux_amd64", "linux-5"), test.binary("example_windows_amd64.exe", "windows-2")
+		output, err := test.run("-output=json", "release", "-repository="+test.target, "-license=MIT", linux, windows)
+		is(err, nil)
+		var released _released
+		is(json.Unmarshal([]byte(output), &released), nil)
+		var names []string
+		for _, asset := range released.Uploaded {
+			names = append(names, asset.Name)
+		}
+		is(names, []string{"example_linux_amd64", "example_windows_amd64.exe", "example_1.4.0~rc.1_amd64.deb", "example-1.4.0_rc1-r0.x86_64.apk"})
+		release := test.server.Repository("alice", "example").Release("v1.4.0-rc.1")
+		is(released.Uploaded[2].Digest, "sha256:"+digestOf(string(release.Asset("example_1.4.0~rc.1_amd64.deb").Content)))
+		decompressor, err := gzip.NewReader(bytes.NewReader(release.Asset("example-1.4.0_rc1-r0.x86_64.apk").Content))
+		is(err, nil)
+		content, err := ioutil.ReadAll(decompressor)
+		is(err, nil)
+		is(strings.Contains(string(content), "\nmaintainer = Alice <alice@example.com>\n"), true)
+		is(strings.Contains(string(content), "\nlicense = MIT\n"), true)
+
+		_, err = test.run("release", "-repository="+test.target, "-force", "-package=msi", linux)
+		is(err, "invalid package format: msi (not deb, rpm, apk)")
+		is(exitCode(err), 2)
+	})
+}
+
+// programs is the .gphr of the tests of the programs (and platforms) of a release.
+const programs = "[program \"server\"]\n\tplatforms = linux/amd64\n[program \"cli\"]\n\tplatforms = linux/amd64, windows/amd64\n"
+
+func TestEndToEndPrograms(t *testing.T) {
+	test := newEndToEnd(t)
+	defer test.close()
+
+	terst.Terst(t, func() {
+		repository := test.server.Repository("alice", "example")
+		test.config(programs)
+		test.tag("v1.5.0")
+		server, cli := test.binary("server_linux_amd64", "server-1"), test.binary("cli_linux_amd64", "cli-1")
+		_, err := test.run("release", "-repository="+test.target, server, cli, test.binary("agent_linux_amd64", "agent-1"))
+		is(err, "1 or more binaries are unexpected (not of a program, or platform, in .gphr), not releasing")
+		_, err = test.run("release", "-repository="+test.target, server, cli)
+		is(err, "1 or more binaries are missing (of the programs in .gphr, or -require), not releasing (override with -partial)")
+		is(exitCode(err), 10)
+		is(repository.Release("v1.5.0") == nil, true)
+
+		output, err := test.run("release", "-repository="+test.target, "-partial", server, cli)
+		is(err, nil)
+		is(strings.Contains(output, "\nserver\n  server_linux_amd64\t"), true)
+		is(strings.Contains(output, "\ncli (missing windows/amd64)\n  cli_linux_amd64\t"), true)
+
+		output, err = test.run("-output=json", "release", "-repository="+test.target, test.binary("cli_windows_amd64.exe", "cli-2"))
+		is(err, nil)
+		var released _released
+		is(json.Unmarshal([]byte(output), &released), nil)
+		is(len(released.Programs), 1)
+		is(*released.Programs[0], _releasedProgram{Name: "cli", Uploaded: []string{"cli_windows_amd64.exe"}})
+	})
+}
+
+func TestEndToEndCheck(t *testing.T) {
+	test := newEndToEnd(t)
+	defer test.close()
+
+	terst.Terst(t, func() {
+		test.config(programs)
+		test.tag("v1.5.0")
+		binaries := []string{test.binary("server_linux_amd64", "server-1"), test.binary("cli_linux_amd64", "cli-1"), test.binary("cli_windows_amd64.exe", "cli-2")}
+		_, err := test.run(append([]string{"release", "-repository=" + test.target}, binaries...)...)
+		is(err, nil)
+
+		// The release, against .gphr, and -require
+		output, err := test.run("-output=json", "check", "-repository="+test.target, "v1.5.0")
+		is(err, nil)
+		var checked _checked
+		is(json.Unmarshal([]byte(output), &checked), nil)
+		is(checked.Programs, []_checkedProgram{
+			{Name: "cli", Platforms: []string{"linux/amd64", "windows/amd64"}},
+			{Name: "server", Platforms: []string{"linux/amd64"}},
+		})
+		output, err = test.run("check", "-repository="+test.target, "-require=darwin/arm64", "v1.5.0")
+		is(err, "1 or more binaries are missing (of the programs in .gphr, or -require)")
+		is(exitCode(err), 10)
+		is(strings.Contains(output, "(missing darwin/arm64)\n"), true)
+		test.config(programs + "[release]\n\tpartial = true\n")
+		_, err = test.run("check", "-repository="+test.target, "-require=darwin/arm64", "v1.5.0")
+		is(exitCode(err), 10) // Even with release.partial
+		test.config(programs)
+		_, err = test.run("check", "-repository="+test.target, "-require=darwin/arm65", "v1.5.0")
+		is(err, "invalid platform (required): darwin/arm65 (not $GOOS/$GOARCH)")
+		is(exitCode(err), 2)
+		_, err = test.run("check", "-repository="+test.target, "-require=darwin/arm64", "v9.9.9")
+		is(err, "no release for v9.9.9 in "+test.target)
+		is(exitCode(err), 4)
+
+		// release -require
+		test.tag("v1.6.0")
+		_, err = test.run(append([]string{"release", "-repository=" + test.target, "-require=darwin/arm64"}, binaries...)...)
+		is(err, "1 or more binaries are missing (of the programs in .gphr, or -require), not releasing (override with -partial)")
+		binaries = append(binaries, test.binary("server_darwin_arm64", "server-2"), test.binary("cli_darwin_arm64", "cli-4"))
+		_, err = test.run(append([]string{"release", "-repository=" + test.target, "-require=darwin/arm64"}, binaries...)...)
+		is(err, nil)
+		_, err = test.run("check", "-repository="+test.target, "-require=darwin/arm64", "v1.6.0")
+		is(err, nil)
+	})
+}
+
+func digestOf(content string) string {
+	hash := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(hash[:])
+}
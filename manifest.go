@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/robertkrimen/gphr/gphr"
+)
+
+// _formula is a Homebrew formula (of a program), with an asset for each
+// platform (on_macos, on_linux) it is available for.
+type _formula struct {
+	*_install
+	Program string
+	Class   string // Example (for example)
+	Version string // 1.2.0 (for v1.2.0)
+	MacOS   []_formulaAsset
+	Linux   []_formulaAsset
+}
+
+type _formulaAsset struct {
+	_installAsset
+	CPU string // Hardware::CPU.intel? && Hardware::CPU.is_64_bit?
+}
+
+var homebrewTemplate = strings.TrimLeft(`
+# Generated by gphr ({{ .Repository }} {{ .Tag }})
+class {{ .Class }} < Formula
+{{ if .Homepage }}  homepage "{{ .Homepage }}"
+{{ end }}  version "{{ .Version }}"
+{{ if .MacOS }}
+  on_macos do
+{{ range .MacOS }}    if {{ .CPU }}
+      url "{{ .URL }}"
+      sha256 "{{ .Digest }}"
+    end
+{{ end }}  end
+{{ end }}{{ if .Linux }}
+  on_linux do
+{{ range .Linux }}    if {{ .CPU }}
+      url "{{ .URL }}"
+      sha256 "{{ .Digest }}"
+    end
+{{ end }}  end
+{{ end }}
+  def install
+    bin.install Dir["{{ .Program }}[-_]*"].first => "{{ .Program }}"
+  end
+end
+`, "\n")
+
+// homebrewCPU is the (Homebrew) condition for the CPU of every $GOARCH.
+var homebrewCPU = map[string]string{
+	"amd64": "Hardware::CPU.intel? && Hardware::CPU.is_64_bit?",
+	"386":   "Hardware::CPU.intel? && !Hardware::CPU.is_64_bit?",
+	"arm":   "Hardware::CPU.arm? && !Hardware::CPU.is_64_bit?",
+	"arm64": "Hardware::CPU.arm? && Hardware::CPU.is_64_bit?",
+}
+
+// homebrewClass returns the (Ruby) class of the formula for <program>: Example
+// for example, ExampleCli for example-cli, ...
+func homebrewClass(program string) string {
+	class := ""
+	for _, word := range strings.FieldsFunc(program, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		class += strings.ToUpper(word[:1]) + word[1:]
+	}
+	if class == "" || !unicode.IsLetter(rune(class[0])) {
+		class = "Formula" + class
+	}
+	return class
+}
+
+// renderHomebrew returns the Homebrew formula for <program> (of <install>), or
+// nil if there is no darwin or linux asset of it.
+func renderHomebrew(install *_install, program string) ([]byte, error) {
+	formula := &_formula{
+		_install: install,
+		Program:  program,
+		Class:    homebrewClass(program),
+		Version:  strings.TrimPrefix(install.Tag, "v"),
+	}
+	for _, asset := range install.Assets {
+		cpu := homebrewCPU[asset.GOARCH]
+		if asset.Program != program || cpu == "" {
+			continue
+		}
+		switch asset.GOOS {
+		case "darwin":
+			formula.MacOS = append(formula.MacOS, _formulaAsset{asset, cpu})
+		case "linux":
+			formula.Linux = append(formula.Linux, _formulaAsset{asset, cpu})
+		}
+	}
+	if len(formula.MacOS) == 0 && len(formula.Linux) == 0 {
+		return nil, nil
+	}
+
+	tmpl, err := template.New("homebrew").Parse(homebrewTemplate)
+	if err != nil {
+		return nil, err
+	}
+	output := bytes.NewBuffer(nil)
+	err = tmpl.Execute(output, formula)
+	if err != nil {
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
+
+// _scoop is a Scoop manifest (of a program).
+type _scoop struct {
+	Comment      string                        `json:"##"`
+	Version      string                        `json:"version"`
+	Homepage     string                        `json:"homepage,omitempty"`
+	Architecture map[string]_scoopArchitecture `json:"architecture"`
+}
+
+type _scoopArchitecture struct {
+	URL  string     `json:"url"`
+	Hash string     `json:"hash"`
+	Bin  [][]string `json:"bin"` // [[<asset>, <program>]], the program (a shim) for the asset
+}
+
+// scoopArchitecture is the (Scoop) architecture of every $GOARCH.
+var scoopArchitecture = map[string]string{
+	"amd64": "64bit",
+	"386":   "32bit",
+	"arm64": "arm64",
+}
+
+// renderScoop returns the Scoop manifest for <program> (of <install>), or nil
+// if there is no windows asset of it.
+func renderScoop(install *_install, program string) ([]byte, error) {
+	manifest := &_scoop{
+		Comment:      fmt.Sprintf("Generated by gphr (%s %s)", install.Repository, install.Tag),
+		Version:      strings.TrimPrefix(install.Tag, "v"),
+		Homepage:     install.Homepage,
+		Architecture: map[string]_scoopArchitecture{},
+	}
+	for _, asset := range install.Assets {
+		architecture := scoopArchitecture[asset.GOARCH]
+		if asset.Program != program || asset.GOOS != "windows" || architecture == "" {
+			continue
+		}
+		manifest.Architecture[architecture] = _scoopArchitecture{
+			URL:  asset.URL,
+			Hash: asset.Digest,
+			Bin:  [][]string{{asset.Name, program}},
+		}
+	}
+	if len(manifest.Architecture) == 0 {
+		return nil, nil
+	}
+
+	content, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append(content, '\n'), nil
+}
+
+// isDirectory returns whether <target> (of -homebrew or -scoop) is a directory
+// (an existing one, or a path starting with . or /) rather than a repository.
+func isDirectory(target string) bool {
+	if strings.HasPrefix(target, ".") || filepath.IsAbs(target) {
+		return true
+	}
+	stat, err := os.Stat(target)
+	return err == nil && stat.IsDir()
+}
+
+// writeManifests writes the Homebrew formula (-homebrew) and Scoop manifest
+// (-scoop) of every program of <install>, to a directory or (committed to) a
+// repository, returning where each was written.
+func writeManifests(install *_install) ([]string, error) {
+	var written []string
+	for _, kind := range []struct {
+		target string
+		dir    string // In a repository (a tap or bucket)
+		ext    string
+		render func(*_install, string) ([]byte, error)
+	}{
+		{*flags.release.homebrew, "Formula", ".rb", renderHomebrew},
+		{*flags.release.scoop, "bucket", ".json", renderScoop},
+	} {
+		if kind.target == "" {
+			continue
+		}
+		for _, program := range install.Programs {
+			content, err := kind.render(install, program)
+			if err != nil {
+				return nil, err
+			}
+			if content == nil {
+				continue
+			}
+
+			if isDirectory(kind.target) {
+				path := filepath.Join(kind.target, program+kind.ext)
+				lg.progress("Writing %s", path)
+				err := os.MkdirAll(kind.target, 0755)
+				if err != nil {
+					return nil, err
+				}
+				err = ioutil.WriteFile(path, content, 0644)
+				if err != nil {
+					return nil, err
+				}
+				written = append(written, path)
+				continue
+			}
+
+			location, err := writeRepositoryFile(kind.target, kind.dir+"/"+program+kind.ext, content, program+" "+strings.TrimPrefix(install.Tag, "v"))
+			if err != nil {
+				return nil, err
+			}
+			written = append(written, location)
+		}
+	}
+	return written, nil
+}
+
+// writeRepositoryFile commits <content> to <path> in the repository <target>
+// (host/owner/repository) with <message>, returning its location.
+func writeRepositoryFile(target, path string, content []byte, message string) (string, error) {
+	host, owner, repository, _, err := gphr.GetTarget(target)
+	if err != nil {
+		return "", err
+	}
+	if repository == "" {
+		return "", fmt.Errorf("invalid target: %s: not a repository (or a directory)", target)
+	}
+	token, err := getToken(host)
+	if err != nil {
+		return "", err
+	}
+	provider, err := client(host, owner, repository, token)
+	if err != nil {
+		return "", err
+	}
+	writer, ok := provider.(gphr.FileWriter)
+	if !ok {
+		return "", fmt.Errorf("cannot commit to %s/%s/%s (in a store)", host, owner, repository)
+	}
+
+	location := provider.Location() + "/" + path
+	lg.progress("Committing %s", location)
+	committed, err := writer.WriteFile(path, content, message)
+	if err != nil {
+		return "", err
+	}
+	if !committed {
+		lg.progress("%s is up to date", location)
+	}
+	return location, nil
+}
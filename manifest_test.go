@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"./gphr/terst"
+)
+
+func TestManifest(t *testing.T) {
+	terst.Terst(t, func() {
+		install := testInstall()
+
+		for _, program := range install.Programs {
+			content, err := renderHomebrew(install, program)
+			is(err, nil)
+			isGolden(program+".rb", content)
+
+			content, err = renderScoop(install, program)
+			is(err, nil)
+			isGolden(program+".json", content)
+			var manifest _scoop
+			is(json.Unmarshal(content, &manifest), nil)
+		}
+
+		// Nothing (for the platforms) to install
+		install.Assets = install.Assets[2:3] // example_windows_amd64.exe
+		content, err := renderHomebrew(install, "example")
+		is(err, nil)
+		is(content, nil)
+		content, err = renderScoop(install, "other")
+		is(err, nil)
+		is(content, nil)
+
+		is(homebrewClass("example"), "Example")
+		is(homebrewClass("example-cli"), "ExampleCli")
+		is(homebrewClass("example_cli.v2"), "ExampleCliV2")
+		is(homebrewClass("3d"), "Formula3d")
+	})
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// Release notes come from (in order of preference):
+//
+//     -notes=<file>
+//     The message of the annotated tag
+//     The commit subjects since the previous tag, grouped by Conventional Commit type
+
+type _notes struct {
+	Tag      string // v1.2.0
+	Previous string // v1.1.0 (or "" if there is no previous tag)
+	Message  string // The message of the annotated tag (or "" if the tag is lightweight)
+	Commits  []*_notesCommit
+	Groups   []*_notesGroup
+}
+
+type _notesCommit struct {
+	Subject  string // feat(get)!: Download from the latest release
+	Type     string // feat
+	Scope    string // get
+	Breaking bool   // true
+	Summary  string // Download from the latest release
+}
+
+type _notesGroup struct {
+	Type    string // feat
+	Title   string // Features
+	Commits []*_notesCommit
+}
+
+// https://www.conventionalcommits.org/
+var matchConventionalCommit = regexp.MustCompile(`^([a-zA-Z]+)(?:\(([^)]*)\))?(!)?:\s*(.+)$`)
+
+var notesGroupTitle = []struct {
+	Type  string
+	Title string
+}{
+	{"!", "Breaking Changes"},
+	{"feat", "Features"},
+	{"fix", "Bug Fixes"},
+	{"perf", "Performance"},
+	{"refactor", "Refactoring"},
+	{"docs", "Documentation"},
+	{"test", "Tests"},
+	{"build", "Build"},
+	{"ci", "Continuous Integration"},
+	{"style", "Style"},
+	{"chore", "Chores"},
+	{"revert", "Reverts"},
+	{"", "Other Changes"},
+}
+
+func newNotesCommit(subject string) *_notesCommit {
+	commit := &_notesCommit{
+		Subject: subject,
+		Summary: subject,
+	}
+	if match := matchConventionalCommit.FindStringSubmatch(subject); match != nil {
+		commit.Type = strings.ToLower(match[1])
+		commit.Scope = match[2]
+		commit.Breaking = match[3] == "!"
+		commit.Summary = match[4]
+	}
+	return commit
+}
+
+func groupNotesCommits(commits []*_notesCommit) []*_notesGroup {
+	known := map[string]bool{}
+	for _, tmp := range notesGroupTitle {
+		known[tmp.Type] = true
+	}
+
+	group := map[string]*_notesGroup{}
+	for _, commit := range commits {
+		kind := commit.Type
+		if commit.Breaking {
+			kind = "!"
+		} else if !known[kind] {
+			kind = ""
+		}
+		if group[kind] == nil {
+			group[kind] = &_notesGroup{Type: kind}
+		}
+		group[kind].Commits = append(group[kind].Commits, commit)
+	}
+
+	var groups []*_notesGroup
+	for _, tmp := range notesGroupTitle {
+		if group := group[tmp.Type]; group != nil {
+			group.Title = tmp.Title
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+var notesTemplate = strings.TrimSpace(`
+{{ if .Message }}{{ .Message }}{{ else }}{{ range .Groups }}### {{ .Title }}
+{{ range .Commits }}
+* {{ if .Scope }}**{{ .Scope }}:** {{ end }}{{ .Summary }}{{ end }}
+
+{{ end }}{{ if .Previous }}{{ .Previous }}...{{ .Tag }}{{ end }}{{ end }}
+`)
+
+func getNotes(tag string) (*_notes, error) {
+	notes := &_notes{
+		Tag: tag,
+	}
+
+	message, err := gitGetTagMessage(tag)
+	if err != nil {
+		return nil, err
+	}
+	notes.Message = message
+
+	previous, err := gitGetPreviousTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	notes.Previous = previous
+
+	subjects, err := gitGetSubjects(previous, tag)
+	if err != nil {
+		return nil, err
+	}
+	for _, subject := range subjects {
+		notes.Commits = append(notes.Commits, newNotesCommit(subject))
+	}
+	notes.Groups = groupNotesCommits(notes.Commits)
+
+	return notes, nil
+}
+
+// getReleaseNotes returns the name and body for the release of <tag>.
+func getReleaseNotes(tag string) (string, string, error) {
+	if filename := *flags.release.notes; filename != "" {
+		body, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return "", "", err
+		}
+		return tag, strings.TrimSpace(string(body)), nil
+	}
+
+	notes, err := getNotes(tag)
+	if err != nil {
+		return "", "", err
+	}
+
+	text := notesTemplate
+	if filename := *flags.release.notesTemplate; filename != "" {
+		tmp, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return "", "", err
+		}
+		text = string(tmp)
+	}
+
+	body, err := renderNotes(text, notes)
+	if err != nil {
+		return "", "", err
+	}
+	return tag, body, nil
+}
+
+// renderNotes renders <notes> with the template <text> (-notes-template, or
+// notesTemplate).
+func renderNotes(text string, notes *_notes) (string, error) {
+	tmpl, err := template.New("notes").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	body := bytes.NewBuffer(nil)
+	err = tmpl.Execute(body, notes)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(body.String()), nil
+}
+
+// git for-each-ref --format=%(objecttype)%00%(contents) refs/tags/<tag>
+
+func gitGetTagMessage(tag string) (string, error) {
+	if useNativeGit() {
+		repo, err := openGitRepository(".")
+		if err != nil {
+			return "", err
+		}
+		return repo.tagMessage(tag)
+	}
+
+	cmd := exec.Command("git", "for-each-ref", "--format=%(objecttype)%00%(contents)", "refs/tags/"+tag)
+	output, err := cmd.CombinedOutput()
+	lg.dbg("git for-each-ref refs/tags/%s:\n%s---", tag, string(output))
+	if err != nil {
+		return "", fmt.Errorf("git: %v: %s", err, firstLine(output))
+	}
+	fields := strings.SplitN(string(output), "\x00", 2)
+	if len(fields) != 2 || fields[0] != "tag" {
+		return "", nil // A lightweight tag (or no tag at all)
+	}
+	message := fields[1]
+	if index := strings.Index(message, "-----BEGIN PGP SIGNATURE-----"); index != -1 {
+		message = message[:index]
+	}
+	return strings.TrimSpace(message), nil
+}
+
+// git rev-parse --quiet --verify <tag>^
+// git describe --tags --abbrev=0 <tag>^
+
+func gitGetPreviousTag(tag string) (string, error) {
+	if useNativeGit() {
+		repo, err := openGitRepository(".")
+		if err != nil {
+			return "", err
+		}
+		return repo.previousTag(tag)
+	}
+
+	cmd := exec.Command("git", "rev-parse", "--quiet", "--verify", tag+"^")
+	output, err := cmd.Output()
+	lg.dbg("git rev-parse --quiet --verify %s^:\n%s---", tag, string(output))
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return "", nil // <tag> is the root commit (--quiet: no parent is exit status 1, not a message)
+		}
+		return "", fmt.Errorf("git: %v", err)
+	}
+
+	cmd = exec.Command("git", "describe", "--tags", "--abbrev=0", tag+"^")
+	cmd.Env = append(os.Environ(), "LC_ALL=C") // The error messages below are not localized
+	output, err = cmd.CombinedOutput()
+	lg.dbg("git describe --tags --abbrev=0 %s^:\n%s---", tag, string(output))
+	if err != nil {
+		if bytes.HasPrefix(output, []byte("fatal: No names found")) ||
+			bytes.HasPrefix(output, []byte("fatal: No tags can describe")) {
+			return "", nil
+		}
+		return "", fmt.Errorf("git: %v: %s", err, firstLine(output))
+	}
+	return firstLine(output), nil
+}
+
+// git log --format=%s <previous>..<tag>
+
+func gitGetSubjects(previous, tag string) ([]string, error) {
+	if useNativeGit() {
+		repo, err := openGitRepository(".")
+		if err != nil {
+			return nil, err
+		}
+		return repo.subjects(previous, tag)
+	}
+
+	revision := tag
+	if previous != "" {
+		revision = previous + ".." + tag
+	}
+	cmd := exec.Command("git", "log", "--format=%s", revision)
+	output, err := cmd.CombinedOutput()
+	lg.dbg("git log --format=%%s %s:\n%s---", revision, string(output))
+	if err != nil {
+		return nil, fmt.Errorf("git: %v: %s", err, firstLine(output))
+	}
+	var subjects []string
+	for _, subject := range strings.Split(string(output), "\n") {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			subjects = append(subjects, subject)
+		}
+	}
+	return subjects, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"./gphr/terst"
+)
+
+func TestNotesCommit(t *testing.T) {
+	terst.Terst(t, func() {
+		for _, test := range []struct {
+			subject string
+			commit  _notesCommit
+		}{
+			{"feat: Add get", _notesCommit{Type: "feat", Summary: "Add get"}},
+			{"fix(get): Follow redirects", _notesCommit{Type: "fix", Scope: "get", Summary: "Follow redirects"}},
+			{"feat(get)!: Download from the latest release", _notesCommit{Type: "feat", Scope: "get", Breaking: true, Summary: "Download from the latest release"}},
+			{"refactor!:Drop -old", _notesCommit{Type: "refactor", Breaking: true, Summary: "Drop -old"}},
+			{"FEAT: Shout", _notesCommit{Type: "feat", Summary: "Shout"}},
+			{"Merge branch 'master'", _notesCommit{Summary: "Merge branch 'master'"}},
+			{"fix : Not quite", _notesCommit{Summary: "fix : Not quite"}},
+			{"v1.2.0: Not a type", _notesCommit{Summary: "v1.2.0: Not a type"}},
+		} {
+			test.commit.Subject = test.subject
+			is(*newNotesCommit(test.subject), test.commit)
+		}
+	})
+}
+
+func TestGroupNotesCommits(t *testing.T) {
+	terst.Terst(t, func() {
+		var commits []*_notesCommit
+		for _, subject := range []string{
+			"chore: Bump",
+			"feat: Add get",
+			"xyzzy: Unknown",
+			"fix(get)!: Refuse http",
+			"Untyped",
+			"feat(list): Add -os",
+		} {
+			commits = append(commits, newNotesCommit(subject))
+		}
+
+		var groups []string
+		for _, group := range groupNotesCommits(commits) {
+			var subjects []string
+			for _, commit := range group.Commits {
+				subjects = append(subjects, commit.Subject)
+			}
+			groups = append(groups, group.Type+"|"+group.Title+"|"+strings.Join(subjects, ", "))
+		}
+		is(groups, []string{
+			"!|Breaking Changes|fix(get)!: Refuse http", // Breaking, whatever the type
+			"feat|Features|feat: Add get, feat(list): Add -os",
+			"chore|Chores|chore: Bump",
+			"|Other Changes|xyzzy: Unknown, Untyped", // Unknown (or no) type
+		})
+
+		is(len(groupNotesCommits(nil)), 0)
+	})
+}
+
+func TestRenderNotes(t *testing.T) {
+	terst.Terst(t, func() {
+		notes := &_notes{
+			Tag:      "v1.2.0",
+			Previous: "v1.1.0",
+		}
+		for _, subject := range []string{"feat(get): Add -o", "fix: Trailing slash", "feat!: Drop -old"} {
+			notes.Commits = append(notes.Commits, newNotesCommit(subject))
+		}
+		notes.Groups = groupNotesCommits(notes.Commits)
+
+		for _, test := range []struct {
+			text    string
+			message string
+			body    string
+		}{
+			{notesTemplate, "", "### Breaking Changes\n\n* Drop -old\n\n### Features\n\n* **get:** Add -o\n\n### Bug Fixes\n\n* Trailing slash\n\nv1.1.0...v1.2.0"},
+			{notesTemplate, "The message of the tag", "The message of the tag"},
+			{"{{ .Tag }} ({{ len .Commits }} commits)\n\n", "", "v1.2.0 (3 commits)"},
+		} {
+			notes.Message = test.message
+			body, err := renderNotes(test.text, notes)
+			is(err, nil)
+			is(body, test.body)
+		}
+
+		_, err := renderNotes("{{ .Xyzzy }}", notes)
+		is(err != nil, true)
+
+		// The first tag (no previous tag, so no compare line)
+		body, err := renderNotes(notesTemplate, &_notes{Tag: "v1.0.0", Groups: groupNotesCommits([]*_notesCommit{newNotesCommit("Initial")})})
+		is(err, nil)
+		is(body, "### Other Changes\n\n* Initial")
+	})
+}
+
+func TestReleaseNotes(t *testing.T) {
+	test := newEndToEnd(t)
+	defer test.close()
+
+	// Not English (the messages of git describe are matched in English)
+	os.Setenv("LANG", "de_DE.UTF-8")
+	os.Setenv("LANGUAGE", "de")
+
+	terst.Terst(t, func() {
+		repository := test.repository()
+		notes := func(arguments ...string) (string, string, error) {
+			flags = newFlags()
+			is(flags.release_.Parse(arguments), nil)
+			return getReleaseNotes("v1.1.0")
+		}
+
+		// The first tag is on the root commit (a lightweight tag, so from the commits)
+		test.git(repository, "commit", "-q", "--allow-empty", "-m", "feat: Initial")
+		test.git(repository, "tag", "v1.0.0")
+		previous, err := gitGetPreviousTag("v1.0.0")
+		is(err, nil)
+		is(previous, "")
+		flags = newFlags()
+		name, body, err := getReleaseNotes("v1.0.0")
+		is(err, nil)
+		is(name, "v1.0.0")
+		is(body, "### Features\n\n* Initial")
+
+		test.git(repository, "commit", "-q", "--allow-empty", "-m", "fix(get): Follow redirects")
+		test.git(repository, "commit", "-q", "--allow-empty", "-m", "docs: Usage")
+		test.git(repository, "tag", "v1.1.0")
+		previous, err = gitGetPreviousTag("v1.1.0")
+		is(err, nil)
+		is(previous, "v1.0.0")
+		_, body, err = notes()
+		is(err, nil)
+		is(body, "### Bug Fixes\n\n* **get:** Follow redirects\n\n### Documentation\n\n* Usage\n\nv1.0.0...v1.1.0")
+
+		// -notes-template
+		template := filepath.Join(test.dir, "notes.tmpl")
+		is(ioutil.WriteFile(template, []byte("{{ range .Commits }}- {{ .Subject }}\n{{ end }}"), 0644), nil)
+		_, body, err = notes("-notes-template=" + template)
+		is(err, nil)
+		is(body, "- docs: Usage\n- fix(get): Follow redirects")
+
+		// -notes (rather than the template)
+		file := filepath.Join(test.dir, "NOTES.md")
+		is(ioutil.WriteFile(file, []byte("\nThe notes\n\n"), 0644), nil)
+		_, body, err = notes("-notes="+file, "-notes-template="+template)
+		is(err, nil)
+		is(body, "The notes")
+
+		_, _, err = notes("-notes=" + filepath.Join(test.dir, "xyzzy"))
+		is(err != nil, true)
+
+		// The message of an annotated tag (rather than the commits)
+		test.git(repository, "tag", "-f", "-a", "-m", "Release v1.1.0\n\nWith a message", "v1.1.0")
+		_, body, err = notes()
+		is(err, nil)
+		is(body, "Release v1.1.0\n\nWith a message")
+	})
+}
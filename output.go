@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/robertkrimen/gphr/gphr"
+)
+
+// getOutput returns the kind of output (-output): json, table, csv, or text.
+func getOutput() (string, error) {
+	switch output := *flags.main.output; output {
+	case "json", "table", "csv", "text":
+		return output, nil
+	case "":
+		return "text", nil
+	default:
+		return "", gphr.Errorf(errUsage, "invalid -output: %s (json, table, csv, or text)", output)
+	}
+}
+
+// machine returns whether the output is for a machine (not text), in which
+// case anything but the output (progress, ...) goes to stderr.
+func machine() bool {
+	output, _ := getOutput()
+	return output != "text"
+}
+
+// writeJSON writes <v> (indented) to stdout.
+func writeJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// writeTable writes <rows> (under <header>) as a table (or CSV, -output=csv) to stdout.
+func writeTable(header []string, rows [][]string) error {
+	if output, _ := getOutput(); output == "csv" {
+		writer := csv.NewWriter(os.Stdout)
+		writer.Write(header)
+		writer.WriteAll(rows)
+		return writer.Error()
+	}
+	table := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(table, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(table, strings.Join(row, "\t"))
+	}
+	return table.Flush()
+}
+
+// digest returns the digest (sha256:<hex>) of the file at <path>.
+func digest(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// _asset is an asset, as output (-output=json).
+type _asset struct {
+	Name          string     `json:"name"`
+	Tag           string     `json:"tag,omitempty"`
+	URL           string     `json:"url,omitempty"`
+	Size          int64      `json:"size"`
+	Digest        string     `json:"digest,omitempty"`
+	DownloadCount int        `json:"download_count"`
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+	Uploader      string     `json:"uploader,omitempty"`
+}
+
+func newAsset(provider gphr.Provider, release *gphr.Release, asset github.ReleaseAsset) _asset {
+	tmp := _asset{
+		Name: stringValue(asset.Name),
+		Tag:  stringValue(release.TagName),
+		URL:  provider.DownloadURL(stringValue(release.TagName), stringValue(asset.Name)),
+	}
+	if asset.Size != nil {
+		tmp.Size = int64(*asset.Size)
+	}
+	if asset.DownloadCount != nil {
+		tmp.DownloadCount = *asset.DownloadCount
+	}
+	if asset.CreatedAt != nil && !asset.CreatedAt.Time.IsZero() {
+		tmp.CreatedAt = &asset.CreatedAt.Time
+	}
+	if asset.Uploader != nil {
+		tmp.Uploader = stringValue(asset.Uploader.Login)
+	}
+	return tmp
+}
+
+// _release is a release (and its assets), as output (-output=json).
+type _release struct {
+	Tag        string     `json:"tag"`
+	Name       string     `json:"name,omitempty"`
+	Prerelease bool       `json:"prerelease"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	Assets     []_asset   `json:"assets"`
+	Missing    []string   `json:"missing,omitempty"` // The platforms (of the previous release) it is missing, if the newest
+}
+
+func newRelease(release *gphr.Release) _release {
+	tmp := _release{
+		Tag:    stringValue(release.TagName),
+		Name:   stringValue(release.Name),
+		Assets: []_asset{},
+	}
+	if release.Prerelease != nil {
+		tmp.Prerelease = *release.Prerelease
+	}
+	if release.CreatedAt != nil && !release.CreatedAt.Time.IsZero() {
+		tmp.CreatedAt = &release.CreatedAt.Time
+	}
+	return tmp
+}
+
+// _released is what release did (-output=json).
+type _released struct {
+	Repository string              `json:"repository"`
+	Tag        string              `json:"tag"`
+	Uploaded   []_asset            `json:"uploaded"`
+	Deleted    []_asset            `json:"deleted"`
+	Manifests  []string            `json:"manifests,omitempty"` // Where the Homebrew formula and Scoop manifest (of each program) were written
+	Programs   []*_releasedProgram `json:"programs"`
+}
+
+// _releasedProgram is what release uploaded of a program (and what is still
+// missing of it, with -partial).
+type _releasedProgram struct {
+	Name     string   `json:"name"`
+	Uploaded []string `json:"uploaded"`          // The names of the assets (binaries, packages)
+	Missing  []string `json:"missing,omitempty"` // The platforms ($GOOS/$GOARCH) in neither
+}
+
+// program returns the program <name> of the release, adding it if need be.
+func (released *_released) program(name string) *_releasedProgram {
+	for _, program := range released.Programs {
+		if program.Name == name {
+			return program
+		}
+	}
+	program := &_releasedProgram{Name: name, Uploaded: []string{}}
+	released.Programs = append(released.Programs, program)
+	return program
+}
+
+// _checked is what check found of a release (-output=json).
+type _checked struct {
+	Repository string            `json:"repository"`
+	Tag        string            `json:"tag"`
+	Programs   []_checkedProgram `json:"programs"`
+	Unexpected []string          `json:"unexpected,omitempty"` // The assets not of a program, or platform, in .gphr
+}
+
+type _checkedProgram struct {
+	Name      string   `json:"name"`
+	Platforms []string `json:"platforms"`         // The platforms ($GOOS/$GOARCH) of its assets
+	Missing   []string `json:"missing,omitempty"` // The platforms required, but without an asset
+}
+
+// _got is what get downloaded (-output=json).
+type _got struct {
+	Repository string `json:"repository"`
+	Version    string `json:"version"`
+	Asset      string `json:"asset"`
+	URL        string `json:"url,omitempty"`
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
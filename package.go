@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/robertkrimen/gphr/gphr"
+)
+
+// getPackageFormats returns the formats (-package, or formats in [package] of
+// .gphr) of the packages to build, checking each is deb, rpm, or apk.
+func getPackageFormats(config _config) ([]string, error) {
+	formats := config.list(*flags.release.packages, "package.formats")
+	for _, format := range formats {
+		if gphr.PackageArch(format, "amd64") == "" {
+			return nil, gphr.Errorf(errUsage, "invalid package format: %s (not %s)", format, strings.Join(gphr.PackageFormats, ", "))
+		}
+	}
+	return formats, nil
+}
+
+// newPackage returns the package (metadata) for <tag>, from the flags
+// (-maintainer, -description, -license) or [package] of .gphr, with the owner
+// as the maintainer (by default).
+func newPackage(provider gphr.Provider, owner, tag string, config _config) *gphr.Package {
+	pkg := &gphr.Package{
+		Version:     tag,
+		Maintainer:  config.get(*flags.release.maintainer, "package.maintainer"),
+		Description: config.get(*flags.release.description, "package.description"),
+		License:     config.get(*flags.release.license, "package.license"),
+		Homepage:    config.get("", "package.homepage"),
+	}
+	if pkg.Maintainer == "" {
+		pkg.Maintainer = owner
+	}
+	if _, ok := provider.(*gphr.Store); !ok && pkg.Homepage == "" {
+		pkg.Homepage = "https://" + provider.Location()
+	}
+	return pkg
+}
+
+// uploadPackages builds the package (in each of <formats>) of every linux binary,
+// and uploads it to <release> (replacing any already there), adding it to
+// <released> (with its program).
+func uploadPackages(provider gphr.Provider, release *gphr.Release, assets []github.ReleaseAsset, binaries []*gphr.Binary, pkg *gphr.Package, formats []string, released *_released) error {
+	dir, err := ioutil.TempDir("", "gphr-package")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	for _, binary := range binaries {
+		if binary.GOOS != "linux" {
+			continue
+		}
+		for _, format := range formats {
+			name := pkg.Filename(format, binary)
+			path := filepath.Join(dir, name)
+			lg.dbg("build package => %s (%s)", name, binary.Name)
+			file, err := os.Create(path)
+			if err != nil {
+				return err
+			}
+			err = pkg.Build(format, binary, file)
+			if err == nil {
+				_, err = file.Seek(0, 0)
+			}
+			if err != nil {
+				file.Close()
+				return err
+			}
+
+			for _, asset := range assets {
+				if stringValue(asset.Name) == name {
+					lg.dbg("delete asset => %s (%s)", *asset.Name, stringValue(asset.URL))
+					err := provider.DeleteAsset(release, asset)
+					if err != nil {
+						file.Close()
+						return err
+					}
+				}
+			}
+
+			stat, _ := file.Stat()
+			lg.progress("Uploading %s (%d)", name, stat.Size())
+
+			asset, err := gphr.Upload(ctx, provider, release, name, file, 2, lg.err)
+			file.Close()
+			if err != nil {
+				return err
+			}
+			tmp := newAsset(provider, release, *asset)
+			tmp.Size = stat.Size()
+			tmp.Digest, err = digest(path)
+			if err != nil {
+				return err
+			}
+			released.Uploaded = append(released.Uploaded, tmp)
+			program := released.program(binary.Program)
+			program.Uploaded = append(program.Uploaded, name)
+		}
+	}
+	return nil
+}
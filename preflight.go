@@ -0,0 +1,117 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/robertkrimen/gphr/gphr"
+)
+
+// preflight checks the local repository before anything is released from it:
+//
+//	There are no uncommitted changes
+//	There are no untracked files next to the binaries (other than the binaries themselves)
+//	HEAD has been pushed to the remote (is on a branch of the remote, as it is now, without fetching it)
+//	The tag has been pushed to the remote, and is the same tag (object) as the local one
+//
+// With -allow-dirty, any problem is reported, but is not an error. Without git
+// (-git=native), nothing is checked, with a warning.
+func preflight(tag string, binaries []*gphr.Binary) error {
+	if useNativeGit() {
+		lg.err("not checking for uncommitted changes, untracked files, or an unpushed HEAD/tag (without git, -git=native)")
+		return nil
+	}
+
+	var problems []string
+	problem := func(format string, arguments ...interface{}) {
+		problems = append(problems, lg.error(format, arguments...).Error())
+	}
+
+	changes, err := gitGetChanges()
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		problem("uncommitted change: %s", change)
+	}
+
+	root, err := gitGetTopLevel()
+	if err != nil {
+		return err
+	}
+	binary := map[string]bool{}
+	var paths []string
+	for _, tmp := range binaries {
+		path, err := filepath.Abs(tmp.Path)
+		if err != nil {
+			return err
+		}
+		binary[path] = true
+		if relative, err := filepath.Rel(root, filepath.Dir(path)); err != nil || strings.HasPrefix(relative, "..") {
+			continue // Not in the repository (e.g. built in a temporary directory)
+		}
+		paths = append(paths, filepath.Dir(tmp.Path))
+	}
+	var untracked []string
+	if len(paths) > 0 {
+		untracked, err = gitGetUntracked(paths...)
+		if err != nil {
+			return err
+		}
+	}
+	for _, path := range untracked {
+		if binary[filepath.Join(root, filepath.FromSlash(path))] {
+			continue
+		}
+		problem("untracked file: %s", path)
+	}
+
+	remote, _, _, _, err := gitGetGitHubRemote()
+	if err != nil {
+		return err
+	}
+	if remote == "" {
+		lg.dbg("preflight: no GitHub remote, skipping push checks")
+	} else {
+		head, err := gitGetTagCommit("HEAD")
+		if err != nil {
+			return err
+		}
+		pushed, unknown, err := gitIsPushed(remote, head)
+		if err != nil {
+			return err
+		}
+		if len(unknown) > 0 {
+			problem("cannot verify that HEAD (%s) has been pushed to %s (%s not fetched: git fetch %s)", head, remote, strings.Join(unknown, ", "), remote)
+		} else if !pushed {
+			problem("HEAD (%s) has not been pushed to %s", head, remote)
+		}
+
+		local, err := gitGetTagObject(tag)
+		if err != nil {
+			return err
+		}
+		remote_, err := gitGetRemoteTagObject(remote, tag)
+		if err != nil {
+			return err
+		}
+		if remote_ == "" {
+			problem("tag %q has not been pushed to %s (git push %s %s)", tag, remote, remote, tag)
+		} else if remote_ != local {
+			problem("tag %q (%s) in %s does not match the local tag (%s)", tag, remote_, remote, local)
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	for _, problem := range problems {
+		lg.err("%s", problem)
+	}
+	if *flags.release.allowDirty {
+		lg.err("ignoring %d preflight problem(s) (-allow-dirty)", len(problems))
+		return nil
+	}
+	return lg.error("%d preflight problem(s), not releasing (override with -allow-dirty)", len(problems))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"./gphr/terst"
+	"github.com/robertkrimen/gphr/gphr"
+)
+
+func TestPreflight(t *testing.T) {
+	test := newEndToEnd(t)
+	defer test.close()
+
+	terst.Terst(t, func() {
+		repository := test.repository()
+		origin := filepath.Join(test.dir, "origin.git")
+		write := func(path, content string) string {
+			path = filepath.Join(repository, path)
+			is(ioutil.WriteFile(path, []byte(content), 0644), nil)
+			return path
+		}
+		binary := filepath.Join(repository, "example_linux_386")
+		binaries := []*gphr.Binary{gphr.NewBinary(binary)}
+		preflight := func(arguments ...string) error {
+			flags = newFlags()
+			is(flags.release_.Parse(arguments), nil)
+			return preflight("v1.0.0", binaries)
+		}
+
+		write(".gitignore", "/example_*\n")
+		test.git(repository, "add", ".gitignore")
+		test.tag("v1.0.0")
+		write("example_linux_386", "linux-1")
+		is(preflight(), nil)
+
+		// Uncommitted changes (and -allow-dirty)
+		write(".gitignore", "/example_*\n/dist\n")
+		is(preflight(), "1 preflight problem(s), not releasing (override with -allow-dirty)")
+		is(preflight("-allow-dirty"), nil)
+		test.git(repository, "checkout", "--", ".gitignore")
+
+		// An untracked file next to the binary (but not the binary itself)
+		write("notes.txt", "")
+		is(preflight(), "1 preflight problem(s), not releasing (override with -allow-dirty)")
+		is(preflight("-allow-dirty"), nil)
+		test.git(repository, "add", "notes.txt")
+		is(preflight(), "1 preflight problem(s), not releasing (override with -allow-dirty)") // Now an uncommitted change
+		test.git(repository, "reset", "-q", "notes.txt")
+		write(".gitignore", "/example_*\n/notes.txt\n")
+		test.git(repository, "commit", "-q", "-a", "-m", "chore: Ignore notes.txt")
+
+		// HEAD not pushed (and the tag no longer of HEAD, but that is for release to check)
+		head := test.git(repository, "rev-parse", "HEAD")
+		pushed, unknown, err := gitIsPushed("origin", head)
+		is(err, nil)
+		is(pushed, false)
+		is(len(unknown), 0)
+		is(preflight(), "1 preflight problem(s), not releasing (override with -allow-dirty)")
+
+		// Pushed, without updating origin/master (so only the remote knows)
+		test.git(repository, "push", "-q", origin, "HEAD:refs/heads/master")
+		pushed, _, err = gitIsPushed("origin", head)
+		is(err, nil)
+		is(pushed, true)
+		is(preflight(), nil)
+
+		// Behind the remote (a commit pushed from elsewhere, not fetched here, and not fetched by preflight)
+		clone := filepath.Join(test.dir, "clone")
+		test.git(test.dir, "clone", "-q", origin, clone)
+		test.git(clone, "commit", "-q", "--allow-empty", "-m", "feat: Elsewhere")
+		test.git(clone, "push", "-q", "origin", "HEAD:refs/heads/master")
+		pushed, unknown, err = gitIsPushed("origin", head)
+		is(err, nil)
+		is(pushed, false)
+		is(unknown, []string{"master"})
+		is(preflight(), "1 preflight problem(s), not releasing (override with -allow-dirty)")
+		is(gitHasCommit(test.git(clone, "rev-parse", "HEAD")), false) // Still
+		test.git(repository, "fetch", "-q", "origin")
+		is(preflight(), nil)
+
+		// Gone from the remote (origin/master still has it, but the remote does not)
+		test.git(origin, "update-ref", "refs/heads/master", test.git(repository, "rev-parse", "HEAD^"))
+		test.git(repository, "update-ref", "refs/remotes/origin/master", head)
+		is(preflight(), "1 preflight problem(s), not releasing (override with -allow-dirty)")
+		test.git(repository, "push", "-q", "-f", "origin", "HEAD:refs/heads/master")
+		is(preflight(), nil)
+
+		// The tag moved on the remote, or never pushed
+		test.git(origin, "tag", "-f", "v1.0.0", head)
+		is(preflight(), "1 preflight problem(s), not releasing (override with -allow-dirty)")
+		test.git(origin, "tag", "-d", "v1.0.0")
+		is(preflight(), "1 preflight problem(s), not releasing (override with -allow-dirty)")
+
+		// Everything at once
+		write(".gitignore", "")
+		write("notes.txt", "")
+		test.git(repository, "commit", "-q", "--allow-empty", "-m", "chore: Unpushed")
+		is(preflight(), "4 preflight problem(s), not releasing (override with -allow-dirty)")
+		is(preflight("-allow-dirty"), nil)
+	})
+}
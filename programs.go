@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/robertkrimen/gphr/gphr"
+)
+
+// The programs of a release (of a repository with more than one), and the
+// platforms of each, can be declared in .gphr, as can the platforms required of
+// every program (-require):
+//
+//     [program "server"]
+//         platforms = linux/amd64, linux/arm
+//     [program "cli"]
+//         platforms = linux/amd64, darwin/amd64, windows/amd64
+//     [release]
+//         require = linux/amd64, windows/amd64
+//
+// Before uploading anything, release then checks that every binary is of a
+// program (and a platform) declared, and that every platform of every program
+// is either being uploaded or already in the release. check does the same for
+// a release that is already done.
+
+// _requirements is what a release must have: the programs (of .gphr, if any),
+// each with its platforms, and the platforms required of every program.
+type _requirements struct {
+	programs map[string][]string
+	require  []string
+	partial  bool   // Release anyway (with a warning) if anything is missing
+	program  string // The program if there is none otherwise (the repository)
+}
+
+// validPlatform returns whether <platform> ($GOOS/$GOARCH) is one gphr knows.
+func validPlatform(platform string) bool {
+	return gphr.MatchBinary.MatchString("program_" + strings.Replace(platform, "/", "_", 1))
+}
+
+// getRequirements returns the requirements of a release, from .gphr and
+// <require> (-require, if given, rather than require in [release] of .gphr).
+func getRequirements(config _config, require string) (*_requirements, error) {
+	rq := &_requirements{
+		programs: map[string][]string{},
+		require:  config.list(require, "release.require"),
+		partial:  config["release.partial"] == "true",
+	}
+	for _, platform := range rq.require {
+		if !validPlatform(platform) {
+			return nil, gphr.Errorf(errUsage, "invalid platform (required): %s (not $GOOS/$GOARCH)", platform)
+		}
+	}
+	for name := range config {
+		if !strings.HasPrefix(name, "program.") || !strings.HasSuffix(name, ".platforms") {
+			continue
+		}
+		program := strings.TrimSuffix(strings.TrimPrefix(name, "program."), ".platforms")
+		platforms := config.list("", name)
+		for _, platform := range platforms {
+			if !validPlatform(platform) {
+				return nil, gphr.Errorf(errUsage, "invalid platform (of %s): %s (not $GOOS/$GOARCH)", program, platform)
+			}
+		}
+		rq.programs[program] = platforms
+	}
+	return rq, nil
+}
+
+func (rq *_requirements) empty() bool {
+	return len(rq.programs) == 0 && len(rq.require) == 0
+}
+
+// platforms returns the platforms of <program>: its own (of .gphr), and those
+// required of every program.
+func (rq *_requirements) platforms(program string) []string {
+	var platforms []string
+	seen := map[string]bool{}
+	for _, platform := range append(append([]string{}, rq.programs[program]...), rq.require...) {
+		if !seen[platform] {
+			seen[platform] = true
+			platforms = append(platforms, platform)
+		}
+	}
+	return platforms
+}
+
+// check returns the binaries (of <binaries>) that are unexpected (of a program,
+// or a platform, not in .gphr), and the platforms of each program (of .gphr, or
+// else of <binaries> and <assets>, or else rq.program) missing from both
+// <binaries> and <assets>.
+func (rq *_requirements) check(binaries, assets []*gphr.Binary) ([]string, map[string][]string) {
+	have := map[string]bool{}
+	programs := map[string]bool{}
+	for program := range rq.programs {
+		programs[program] = true
+	}
+	for _, binary := range append(append([]*gphr.Binary{}, binaries...), assets...) {
+		if binary.GOOS == "" {
+			continue // Not a binary (install.sh, a package, ...)
+		}
+		have[binary.Program+" "+binary.GOOS+"/"+binary.GOARCH] = true
+		if len(rq.programs) == 0 {
+			programs[binary.Program] = true
+		}
+	}
+	if len(programs) == 0 && rq.program != "" {
+		programs[rq.program] = true // Otherwise, nothing would be missing of a release with no binaries
+	}
+
+	var unexpected []string
+	for _, binary := range binaries {
+		if binary.GOOS == "" || len(rq.programs) == 0 {
+			continue
+		}
+		platform := binary.GOOS + "/" + binary.GOARCH
+		if !programs[binary.Program] {
+			unexpected = append(unexpected, fmt.Sprintf("%s (%s is not a program in .gphr)", binary.Name, binary.Program))
+			continue
+		}
+		found := false
+		for _, tmp := range rq.platforms(binary.Program) {
+			found = found || tmp == platform
+		}
+		if !found {
+			unexpected = append(unexpected, fmt.Sprintf("%s (%s is not a platform of %s in .gphr)", binary.Name, platform, binary.Program))
+		}
+	}
+
+	missing := map[string][]string{}
+	for program := range programs {
+		for _, platform := range rq.platforms(program) {
+			if !have[program+" "+platform] {
+				missing[program] = append(missing[program], platform)
+			}
+		}
+	}
+	return unexpected, missing
+}
+
+// report reports (to stderr) what is unexpected and missing, returning an error
+// if anything is (unless only missing, with partial). With <releasing>, the
+// error says so.
+func (rq *_requirements) report(unexpected []string, missing map[string][]string, releasing bool) error {
+	for _, binary := range unexpected {
+		lg.err("unexpected binary: %s", binary)
+	}
+	count := 0
+	for _, program := range programNames(missing) {
+		lg.err("missing binary: %s (%s)", program, strings.Join(missing[program], ", "))
+		count += len(missing[program])
+	}
+
+	suffix := ""
+	if releasing {
+		suffix = ", not releasing"
+	}
+	if len(unexpected) > 0 {
+		return lg.error("1 or more binaries are unexpected (not of a program, or platform, in .gphr)%s", suffix)
+	}
+	if count > 0 {
+		if !rq.partial {
+			if releasing {
+				suffix += " (override with -partial)"
+			}
+			return gphr.Errorf(gphr.ErrIncomplete, "1 or more binaries are missing (of the programs in .gphr, or -require)%s", suffix)
+		}
+		lg.err("ignoring %d missing binaries (-partial)", count)
+	}
+	return nil
+}
+
+// checkRelease checks <binaries> (and the assets already in <release>, if any)
+// against the requirements, returning the platforms still missing (of each
+// program) if releasing anyway (-partial).
+func checkRelease(rq *_requirements, binaries []*gphr.Binary, release *gphr.Release) (map[string][]string, error) {
+	if rq.empty() {
+		return nil, nil
+	}
+	var assets []*gphr.Binary
+	if release != nil {
+		for _, asset := range release.Assets {
+			assets = append(assets, gphr.NewBinary(stringValue(asset.Name)))
+		}
+	}
+	unexpected, missing := rq.check(binaries, assets)
+	err := rq.report(unexpected, missing, true)
+	if err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+// programNames returns the programs of <programs> (each with its platforms), sorted.
+func programNames(programs map[string][]string) []string {
+	var names []string
+	for name := range programs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// check audits the release for <tag> against the requirements: every asset is
+// expected, and no platform (of any program) is missing (whatever partial is).
+func check(provider gphr.Provider, rq *_requirements, tag, output string) error {
+	if rq.empty() {
+		return gphr.Errorf(errUsage, "nothing to check (no programs in .gphr, and no -require)")
+	}
+	releases, err := provider.GetReleases()
+	if err != nil {
+		return err
+	}
+	var release *gphr.Release
+	for _, tmp := range releases {
+		if stringValue(tmp.TagName) == tag {
+			release = tmp
+			break
+		}
+	}
+	if release == nil {
+		return gphr.Errorf(gphr.ErrTagNotFound, "no release for %s in %s", tag, provider.Location())
+	}
+
+	var assets []*gphr.Binary
+	have := map[string][]string{}
+	for _, asset := range release.Assets {
+		binary := gphr.NewBinary(stringValue(asset.Name))
+		if binary.GOOS == "" {
+			continue
+		}
+		assets = append(assets, binary)
+		have[binary.Program] = append(have[binary.Program], binary.GOOS+"/"+binary.GOARCH)
+	}
+	unexpected, missing := rq.check(assets, nil)
+
+	checked := _checked{
+		Repository: provider.Location(),
+		Tag:        tag,
+		Programs:   []_checkedProgram{},
+		Unexpected: unexpected,
+	}
+	names := map[string][]string{}
+	for program := range rq.programs {
+		names[program] = nil
+	}
+	for program := range have {
+		names[program] = nil
+	}
+	for program := range missing {
+		names[program] = nil
+	}
+	for _, program := range programNames(names) {
+		platforms := have[program]
+		sort.Strings(platforms)
+		checked.Programs = append(checked.Programs, _checkedProgram{
+			Name:      program,
+			Platforms: append([]string{}, platforms...),
+			Missing:   missing[program],
+		})
+	}
+
+	switch output {
+	case "json":
+		err = writeJSON(checked)
+	case "table", "csv":
+		var rows [][]string
+		for _, program := range checked.Programs {
+			rows = append(rows, []string{program.Name, strings.Join(program.Platforms, " "), strings.Join(program.Missing, " ")})
+		}
+		err = writeTable([]string{"PROGRAM", "PLATFORMS", "MISSING"}, rows)
+	default:
+		table := tabwriter.NewWriter(os.Stdout, 0, 8, 2, '\t', 0)
+		for _, program := range checked.Programs {
+			line := program.Name + "\t" + strings.Join(program.Platforms, ", ")
+			if len(program.Missing) > 0 {
+				line += "\t(missing " + strings.Join(program.Missing, ", ") + ")"
+			}
+			fmt.Fprintln(table, line)
+		}
+		err = table.Flush()
+	}
+	if err != nil {
+		return err
+	}
+	audit := *rq
+	audit.partial = false // Incomplete is incomplete, even if it was released anyway
+	return audit.report(unexpected, missing, false)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	"./gphr/terst"
+	"github.com/robertkrimen/gphr/gphr"
+)
+
+func TestRequirementsCheck(t *testing.T) {
+	terst.Terst(t, func() {
+		binaries := func(names ...string) []*gphr.Binary {
+			var tmp []*gphr.Binary
+			for _, name := range names {
+				tmp = append(tmp, gphr.NewBinary(name))
+			}
+			return tmp
+		}
+
+		for _, test := range []struct {
+			config     _config
+			require    string
+			binaries   []string
+			assets     []string
+			unexpected []string
+			missing    map[string][]string
+		}{
+			// Nothing declared (and nothing required): anything goes
+			{nil, "", []string{"example_linux_amd64", "other_darwin_arm64"}, nil, nil, map[string][]string{}},
+			// Required of every program (of the binaries, and assets)
+			{nil, "linux/amd64, windows/amd64", []string{"example_linux_amd64"}, []string{"other_windows_amd64.exe", "install.sh"}, nil, map[string][]string{
+				"example": {"windows/amd64"},
+				"other":   {"linux/amd64"},
+			}},
+			// Declared in .gphr, and complete (with what is already in the release)
+			{_config{"program.server.platforms": "linux/amd64, linux/arm"}, "", []string{"server_linux_amd64"}, []string{"server_linux_arm"}, nil, map[string][]string{}},
+			// A program (and a platform) that is not in .gphr
+			{_config{"program.server.platforms": "linux/amd64"}, "", []string{"server_linux_amd64", "server_linux_arm", "cli_linux_amd64"}, nil, []string{
+				"server_linux_arm (linux/arm is not a platform of server in .gphr)",
+				"cli_linux_amd64 (cli is not a program in .gphr)",
+			}, map[string][]string{}},
+			// Missing, of a program with no binary at all, and of -require (as well as .gphr)
+			{_config{"program.server.platforms": "linux/amd64", "program.cli.platforms": "darwin/amd64"}, "windows/amd64", []string{"server_linux_amd64"}, nil, nil, map[string][]string{
+				"server": {"windows/amd64"},
+				"cli":    {"darwin/amd64", "windows/amd64"},
+			}},
+			// -require rather than require of .gphr
+			{_config{"release.require": "linux/arm64"}, "linux/amd64", []string{"example_linux_amd64"}, nil, nil, map[string][]string{}},
+		} {
+			rq, err := getRequirements(test.config, test.require)
+			is(err, nil)
+			unexpected, missing := rq.check(binaries(test.binaries...), binaries(test.assets...))
+			is(unexpected, test.unexpected)
+			is(missing, test.missing)
+		}
+
+		// Required, of a release with no binaries (of any program): of the repository
+		rq, err := getRequirements(nil, "linux/amd64, windows/amd64")
+		is(err, nil)
+		rq.program = "example"
+		unexpected, missing := rq.check(nil, binaries("install.sh", "example_1.0.0_amd64.deb"))
+		is(len(unexpected), 0)
+		is(missing, map[string][]string{"example": {"linux/amd64", "windows/amd64"}})
+
+		_, err = getRequirements(_config{"program.server.platforms": "linux/amd65"}, "")
+		is(err, "invalid platform (of server): linux/amd65 (not $GOOS/$GOARCH)")
+		is(exitCode(err), 2)
+	})
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/robertkrimen/gphr/gphr"
+)
+
+// statsSnapshots is how many snapshots are kept (per repository).
+const statsSnapshots = 1000
+
+// _snapshot is the download count of every asset (tag/name) of a repository, at a time.
+type _snapshot struct {
+	Time      time.Time      `json:"time"`
+	Downloads map[string]int `json:"downloads"`
+}
+
+// _statsRow is the downloads of a version, platform, or program (and how many
+// more there are than in the snapshot compared with).
+type _statsRow struct {
+	Key       string `json:"key"`
+	Downloads int    `json:"downloads"`
+	Growth    *int   `json:"growth,omitempty"`
+}
+
+// _stats is the downloads of a repository, by version, platform, and program
+// (-output=json).
+type _stats struct {
+	Repository string      `json:"repository"`
+	Time       time.Time   `json:"time"`
+	Since      *time.Time  `json:"since,omitempty"` // The time of the snapshot compared with, if any
+	Downloads  int         `json:"downloads"`
+	Growth     *int        `json:"growth,omitempty"`
+	Versions   []_statsRow `json:"versions,omitempty"`
+	Platforms  []_statsRow `json:"platforms,omitempty"`
+	Programs   []_statsRow `json:"programs,omitempty"`
+}
+
+// getStatsDirectory returns the directory of the snapshots: GPHR_STATS, or
+// gphr/stats in $XDG_DATA_HOME (or ~/.local/share).
+func getStatsDirectory() (string, error) {
+	if dir := os.Getenv("GPHR_STATS"); dir != "" {
+		return dir, nil
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "gphr", "stats"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "gphr", "stats"), nil
+}
+
+// snapshotPath returns the path of the snapshots of the repository at <location>
+// (host/owner/repository).
+func snapshotPath(dir, location string) string {
+	return filepath.Join(dir, filepath.FromSlash(strings.Replace(location, ":", "_", -1))+".json")
+}
+
+// readSnapshots returns the snapshots at <path>, oldest first.
+func readSnapshots(path string) ([]_snapshot, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var snapshots []_snapshot
+	err = json.Unmarshal(content, &snapshots)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshots: %s: %v", path, err)
+	}
+	return snapshots, nil
+}
+
+// writeSnapshots writes <snapshots> (only the last statsSnapshots) to <path>.
+func writeSnapshots(path string, snapshots []_snapshot) error {
+	if len(snapshots) > statsSnapshots {
+		snapshots = snapshots[len(snapshots)-statsSnapshots:]
+	}
+	content, err := json.Marshal(snapshots)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, content, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// getStatsBy returns what to aggregate the downloads by (-by): version,
+// platform, and/or program.
+func getStatsBy() (map[string]bool, error) {
+	by := listSet(*flags.stats.by)
+	for key := range by {
+		switch key {
+		case "version", "platform", "program":
+		default:
+			return nil, gphr.Errorf(errUsage, "invalid -by: %s (version, platform, or program)", key)
+		}
+	}
+	if len(by) == 0 {
+		return nil, gphr.Errorf(errUsage, "invalid -by: nothing to aggregate by")
+	}
+	return by, nil
+}
+
+// newStats returns the downloads of the (gphr) assets of <listing>, compared
+// with <since> (a snapshot, if not nil), along with a snapshot of them now.
+func newStats(listing *_listing, now time.Time, since *_snapshot, by map[string]bool) (*_stats, _snapshot) {
+	stats := &_stats{
+		Repository: listing.provider.Location(),
+		Time:       now,
+	}
+	snapshot := _snapshot{
+		Time:      now,
+		Downloads: map[string]int{},
+	}
+	if since != nil {
+		stats.Since = &since.Time
+		stats.Growth = new(int)
+	}
+
+	type _key struct {
+		by, key string
+	}
+	rows := map[_key]*_statsRow{}
+	var order []_key // Of versions (newest first), as the releases are
+	add := func(by, key string, downloads int, growth int) {
+		row := rows[_key{by, key}]
+		if row == nil {
+			row = &_statsRow{Key: key}
+			if since != nil {
+				row.Growth = new(int)
+			}
+			rows[_key{by, key}] = row
+			order = append(order, _key{by, key})
+		}
+		row.Downloads += downloads
+		if row.Growth != nil {
+			*row.Growth += growth
+		}
+	}
+
+	for _, release := range listing.releases {
+		tag := stringValue(release.TagName)
+		for _, asset := range release.Assets {
+			name := stringValue(asset.Name)
+			binary := gphr.NewBinary(name)
+			if binary.GOOS == "" {
+				continue
+			}
+			downloads := 0
+			if asset.DownloadCount != nil {
+				downloads = *asset.DownloadCount
+			}
+			snapshot.Downloads[tag+"/"+name] = downloads
+
+			// Growth counts only assets that are still there (a deleted asset
+			// takes its downloads with it, which is not a decline)
+			growth := 0
+			if since != nil {
+				growth = downloads - since.Downloads[tag+"/"+name]
+				*stats.Growth += growth
+			}
+			stats.Downloads += downloads
+			add("version", tag, downloads, growth)
+			add("platform", binary.GOOS+"/"+binary.GOARCH, downloads, growth)
+			add("program", binary.Program, downloads, growth)
+		}
+	}
+
+	for _, key := range order {
+		if !by[key.by] {
+			continue
+		}
+		row := *rows[key]
+		switch key.by {
+		case "version":
+			stats.Versions = append(stats.Versions, row)
+		case "platform":
+			stats.Platforms = append(stats.Platforms, row)
+		case "program":
+			stats.Programs = append(stats.Programs, row)
+		}
+	}
+	for _, rows := range [][]_statsRow{stats.Platforms, stats.Programs} {
+		sort.SliceStable(rows, func(i, j int) bool {
+			if rows[i].Downloads != rows[j].Downloads {
+				return rows[i].Downloads > rows[j].Downloads
+			}
+			return rows[i].Key < rows[j].Key
+		})
+	}
+
+	return stats, snapshot
+}
+
+// statsSince returns the newest of <snapshots> (oldest first) that is at least
+// <since> before <now>, or nil if there is none.
+func statsSince(snapshots []_snapshot, now time.Time, since time.Duration) *_snapshot {
+	for index := len(snapshots) - 1; index >= 0; index-- {
+		if !snapshots[index].Time.After(now.Add(-since)) {
+			return &snapshots[index]
+		}
+	}
+	return nil
+}
+
+// formatGrowth returns <growth> as +N (or -N), or "-" if there is none.
+func formatGrowth(growth *int) string {
+	if growth == nil {
+		return "-"
+	}
+	if *growth >= 0 {
+		return "+" + strconv.Itoa(*growth)
+	}
+	return strconv.Itoa(*growth)
+}
+
+// stats outputs the downloads of every listing (by version, platform, and
+// program), compared with a snapshot (from -since ago, or the last one), and
+// saves a snapshot of them (unless -save=false).
+func stats(listings []*_listing, output string) error {
+	by, err := getStatsBy()
+	if err != nil {
+		return err
+	}
+	dir, err := getStatsDirectory()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+
+	var all []*_stats
+	for _, listing := range listings {
+		path := snapshotPath(dir, listing.provider.Location())
+		snapshots, err := readSnapshots(path)
+		if err != nil {
+			return err
+		}
+		stats, snapshot := newStats(listing, now, statsSince(snapshots, now, *flags.stats.since), by)
+		all = append(all, stats)
+
+		if *flags.stats.save && !*flags.main.dryRun {
+			err := writeSnapshots(path, append(snapshots, snapshot))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	sections := func(stats *_stats) []struct {
+		by   string
+		rows []_statsRow
+	} {
+		return []struct {
+			by   string
+			rows []_statsRow
+		}{
+			{"version", stats.Versions},
+			{"platform", stats.Platforms},
+			{"program", stats.Programs},
+		}
+	}
+
+	switch output {
+	case "json":
+		if len(all) == 1 {
+			return writeJSON(all[0])
+		}
+		return writeJSON(all)
+
+	case "table", "csv":
+		var rows [][]string
+		for _, stats := range all {
+			for _, section := range sections(stats) {
+				for _, row := range section.rows {
+					rows = append(rows, []string{stats.Repository, section.by, row.Key, strconv.Itoa(row.Downloads), formatGrowth(row.Growth)})
+				}
+			}
+		}
+		return writeTable([]string{"REPOSITORY", "BY", "KEY", "DOWNLOADS", "GROWTH"}, rows)
+	}
+
+	for index, stats := range all {
+		if index > 0 {
+			log("")
+		}
+		since := ""
+		if stats.Since != nil {
+			since = fmt.Sprintf(" (%s since %s)", formatGrowth(stats.Growth), stats.Since.Local().Format("2006-01-02 15:04"))
+		}
+		log("%s: %d downloads%s", stats.Repository, stats.Downloads, since)
+
+		for _, section := range sections(stats) {
+			if len(section.rows) == 0 {
+				continue
+			}
+			log("")
+			table := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintf(table, "    %s\tDOWNLOADS\tGROWTH\n", strings.ToUpper(section.by))
+			for _, row := range section.rows {
+				fmt.Fprintf(table, "    %s\t%d\t%s\n", row.Key, row.Downloads, formatGrowth(row.Growth))
+			}
+			table.Flush()
+		}
+	}
+	return nil
+}
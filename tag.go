@@ -0,0 +1,152 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/robertkrimen/gphr/gphr"
+)
+
+// getNextVersion returns the latest (semver) tag of the local repository, along
+// with the version that comes after it.
+func getNextVersion(part string) (*gphr.Version, *gphr.Version, error) {
+	versions, err := gitGetVersions()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	latest := &gphr.Version{Prefix: "v"}
+	if len(versions) > 0 {
+		latest = versions[len(versions)-1]
+	}
+
+	next, err := latest.Bump(part, *flags.tag.preid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(versions) == 0 {
+		return nil, next, nil
+	}
+	return latest, next, nil
+}
+
+// getTagMessage returns a message for the annotated tag <next>, listing
+// the commits since <latest> (in the same way as the release notes).
+func getTagMessage(latest, next *gphr.Version) (string, error) {
+	notes := &_notes{
+		Tag: next.Tag,
+	}
+	if latest != nil {
+		notes.Previous = latest.Tag
+	}
+
+	subjects, err := gitGetSubjects(notes.Previous, "HEAD")
+	if err != nil {
+		return "", err
+	}
+	for _, subject := range subjects {
+		notes.Commits = append(notes.Commits, newNotesCommit(subject))
+	}
+	notes.Groups = groupNotesCommits(notes.Commits)
+
+	message, err := renderNotes(notesTemplate, notes)
+	if err != nil {
+		return "", err
+	}
+	if message == "" {
+		return next.Tag, nil
+	}
+	return message, nil
+}
+
+// tag creates (and pushes) the next <part> tag for HEAD.
+func tag(part string) error {
+	if useNativeGit() {
+		return lg.error("cannot tag without git (-git=native): tag runs git tag and git push")
+	}
+
+	// 1. Make sure the working tree is clean.
+	changes, err := gitGetChanges()
+	if err != nil {
+		return err
+	}
+	if len(changes) > 0 {
+		for _, change := range changes {
+			lg.err("%s", change)
+		}
+		return lg.error("the working tree has uncommitted changes")
+	}
+
+	// 2. Make sure HEAD has been pushed.
+	remote := *flags.tag.remote
+	if remote == "" {
+		remote, _, _, _, err = gitGetGitHubRemote()
+		if err != nil {
+			return err
+		}
+		if remote == "" {
+			return lg.error("cannot determine GitHub remote from: git config --get remote.origin.url")
+		}
+	}
+	lg.dbg("remote = %s", remote)
+
+	head, err := gitGetTagCommit("HEAD")
+	if err != nil {
+		return err
+	}
+
+	pushed, unknown, err := gitIsPushed(remote, head)
+	if err != nil {
+		return err
+	}
+	if len(unknown) > 0 {
+		return lg.error("cannot verify that HEAD (%s) has been pushed to %s (%s not fetched: git fetch %s)", head, remote, strings.Join(unknown, ", "), remote)
+	}
+	if !pushed {
+		return lg.error("HEAD (%s) has not been pushed to %s", head, remote)
+	}
+
+	existing, err := gitGetTag()
+	if err != nil {
+		return err
+	}
+	if existing != "" {
+		return lg.error("HEAD (%s) is already tagged: %s", head, existing)
+	}
+
+	// 3. Determine the next version.
+	latest, next, err := getNextVersion(part)
+	if err != nil {
+		return err
+	}
+
+	message := *flags.tag.message
+	if message == "" {
+		message, err = getTagMessage(latest, next)
+		if err != nil {
+			return err
+		}
+	}
+
+	lg.dbg("create tag => %s (%s)", next.Tag, head)
+
+	if *flags.main.dryRun {
+		log("%s", next.Tag)
+		return nil
+	}
+
+	// 4. Create the (annotated) tag and push it.
+	err = gitCreateTag(next.Tag, message, *flags.tag.sign)
+	if err != nil {
+		return err
+	}
+
+	err = gitPushTag(remote, next.Tag)
+	if err != nil {
+		return err
+	}
+
+	log("%s", next.Tag)
+
+	return nil
+}
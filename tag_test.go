@@ -0,0 +1,108 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"./gphr/terst"
+)
+
+func TestTag(t *testing.T) {
+	test := newEndToEnd(t)
+	defer test.close()
+
+	// gphr tag runs git tag (which needs to know who is tagging)
+	os.Setenv("GIT_COMMITTER_NAME", "Alice")
+	os.Setenv("GIT_COMMITTER_EMAIL", "alice@example.com")
+
+	terst.Terst(t, func() {
+		repository := test.repository()
+		commit := func(subject string) string {
+			test.git(repository, "commit", "-q", "--allow-empty", "-m", subject)
+			return test.git(repository, "rev-parse", "HEAD")
+		}
+		push := func() {
+			test.git(repository, "push", "-q", "origin", "HEAD:refs/heads/master")
+			test.git(repository, "fetch", "-q", "origin")
+		}
+
+		// The next version (with no tag yet, after v0.0.0)
+		commit("feat: Initial")
+		flags = newFlags()
+		latest, next, err := getNextVersion("minor")
+		is(err, nil)
+		is(latest == nil, true)
+		is(next.Tag, "v0.1.0")
+		message, err := getTagMessage(latest, next)
+		is(err, nil)
+		is(message, "### Features\n\n* Initial")
+
+		// Not pushed
+		head := commit("fix: Trailing slash")
+		_, err = test.run("tag", "minor")
+		is(err, "HEAD ("+head+") has not been pushed to origin")
+		push()
+
+		// Uncommitted changes
+		path := filepath.Join(repository, "main.go")
+		is(ioutil.WriteFile(path, []byte("package main\n"), 0644), nil)
+		test.git(repository, "add", "main.go")
+		_, err = test.run("tag", "minor")
+		is(err, "the working tree has uncommitted changes")
+		test.git(repository, "commit", "-q", "-m", "feat: main.go")
+		push()
+
+		// -dry-run (nothing is tagged)
+		output, err := test.run("-dry-run", "tag", "minor")
+		is(err, nil)
+		is(output, "v0.1.0\n")
+		is(test.git(repository, "tag", "--list"), "")
+
+		_, err = test.run("tag", "micro")
+		is(err, "invalid version part: micro (not major, minor, patch, or prerelease)")
+
+		// Tagged (annotated, with the commits since the previous tag) and pushed
+		output, err = test.run("tag", "minor")
+		is(err, nil)
+		is(output, "v0.1.0\n")
+		is(test.git(repository, "for-each-ref", "--format=%(objecttype) %(contents)", "refs/tags/v0.1.0"), "tag ### Features\n\n* main.go\n* Initial\n\n### Bug Fixes\n\n* Trailing slash")
+		is(test.git(repository, "ls-remote", "--tags", "origin", "refs/tags/v0.1.0") != "", true)
+
+		// Already tagged
+		_, err = test.run("tag", "patch")
+		is(err, "HEAD ("+test.git(repository, "rev-parse", "HEAD")+") is already tagged: v0.1.0")
+
+		// A prerelease, continued, then switched to another identifier
+		commit("feat: More")
+		push()
+		for _, tmp := range []struct {
+			arguments []string
+			tag       string
+		}{
+			{[]string{"tag", "-preid=beta", "prerelease"}, "v0.1.1-beta.1"},
+			{[]string{"tag", "-message=Again", "prerelease"}, "v0.1.1-beta.2"},
+			{[]string{"tag", "-preid=rc", "prerelease"}, "v0.1.1-rc.1"},
+		} {
+			output, err = test.run(tmp.arguments...)
+			is(err, nil)
+			is(output, tmp.tag+"\n")
+			commit("chore: Next")
+			push()
+		}
+		is(test.git(repository, "for-each-ref", "--format=%(contents)", "refs/tags/v0.1.1-beta.2"), "Again")
+
+		_, err = test.run("tag", "-preid=alpha", "prerelease")
+		is(err, "invalid prerelease identifier: alpha (v0.1.1-alpha.1 would not come after v0.1.1-rc.1)")
+
+		flags = newFlags()
+		latest, next, err = getNextVersion("minor")
+		is(err, nil)
+		is(latest.Tag, "v0.1.1-rc.1")
+		is(next.Tag, "v0.2.0")
+		message, err = getTagMessage(latest, next)
+		is(err, nil)
+		is(message, "### Chores\n\n* Next\n\nv0.1.1-rc.1...v0.2.0")
+	})
+}
@@ -20,17 +20,29 @@ If you're on system without a Go environment, you can download a gphr executable
 
     curl -JLO https://gphr-io.appspot.com/
 
+To serve your own programs the same way, see gphr serve (below).
+
 Usage
 
-    gphr [-token=""] [-debug=false] [-dry-run=false] <command> ...
+    gphr [-token=""] [-debug=false] [-dry-run=false] [-git="auto"] [-api-url=""] [-upload-url=""] [-provider=""] [-store=""] [-app-id=""] [-app-key=""] [-output="text"] <command> ...
 
         -token=""
             The token to use when accessing GitHub:
             https://github.com/blog/1509-personal-api-tokens
 
             If <token> starts with a "!", then this is a command that outputs the
-            token instead. You can also specify a token via the GPHR_TOKEN
-            environment variable.
+            token instead (run with /bin/sh -c, or cmd /C on Windows). You can also
+            specify a token via the GPHR_TOKEN environment variable.
+
+            Otherwise, the token is the first of:
+
+                GH_TOKEN or GITHUB_TOKEN (GH_ENTERPRISE_TOKEN or GITHUB_ENTERPRISE_TOKEN
+                for any other GitHub host, GITLAB_TOKEN for GitLab, GITEA_TOKEN for Gitea)
+                git credential fill (for https://<host>, from a credential helper)
+                ~/.netrc ($NETRC, or ~/_netrc on Windows)
+                The hosts.yml of the gh CLI (gh auth login)
+
+            Use "gphr auth status" to see which token is used.
 
          -debug=false
             Print out debugging information.
@@ -38,10 +50,61 @@ Usage
          -dry-run=false
             Do not actually modify the remote repository, just show what would be done instead.
 
-    gphr release [-repository=""] [-force=false] [-keep=false] <assets>
+         -git="auto"
+            How to read the local git repository: "exec" (run the git binary),
+            "native" (read .git directly, without git), or "auto" (exec if git
+            is installed, otherwise native). Natively, release does not check the
+            working tree or the remote (for uncommitted changes, untracked files,
+            or an unpushed HEAD or tag), and tag is not available.
+
+         -api-url=""
+            The URL of the GitHub API (e.g. https://github.example.com/api/v3/). You
+            can also specify this via the GPHR_API_URL environment variable. By default,
+            this is https://api.github.com/ for github.com, and https://<host>/api/v3/
+            for any other (GitHub Enterprise) host.
+
+         -upload-url=""
+            The URL of the GitHub upload API (e.g. https://github.example.com/api/uploads/).
+            You can also specify this via the GPHR_UPLOAD_URL environment variable. By
+            default, this is the same as -api-url (if given), or https://uploads.github.com/
+            for github.com, and https://<host>/api/uploads/ for any other host.
+
+         -provider=""
+            Where the releases are kept: "github" (GitHub or GitHub Enterprise),
+            "gitlab" (GitLab Releases, with assets in the Generic Package Registry),
+            or "gitea" (Gitea or Forgejo). By default, this is determined by the host:
+            gitlab.com and gitlab.* are gitlab, codeberg.org, gitea.*, and forgejo.*
+            are gitea, and anything else is github.
+
+         -store=""
+            Keep releases in a store instead: a directory (/srv/releases) or an
+            S3-compatible bucket (s3://bucket/prefix), as <owner>/<repository>/<tag>/<asset>
+            along with an index (<owner>/<repository>/releases.json). You can also
+            specify this via the GPHR_STORE environment variable. A bucket is accessed
+            with AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_REGION, and (for MinIO,
+            etc.) AWS_ENDPOINT_URL. No token is needed for a store.
+
+         -app-id=""
+         -app-key=""
+            Authenticate as a GitHub App instead of with a token: the App ID (or
+            Client ID), and the path to the private key (.pem) of the App. You can
+            also specify these via the GPHR_APP_ID and GPHR_APP_KEY (the path, or
+            the key itself) environment variables. gphr uses an installation access
+            token for just the repository, from the installation of the App for the
+            repository (or GPHR_APP_INSTALLATION_ID), and renews it as needed.
+
+         -output="text"
+            What to output: "text" (for a person), "table" (a table, with a
+            header), "csv" (the table, as CSV), or "json" (for a pipeline). With
+            json, release outputs the assets it uploaded and deleted (with their
+            URL, size, and digest), list the releases (with their assets), and get
+            the version, asset, and path it downloaded. Progress (Uploading ...,
+            Downloading ...) goes to stderr, unless the output is text.
+
+    gphr release [-repository=""] [-force=false] [-keep=false] [-notes=""] [-notes-template=""] [-edit-notes=false] [-allow-dirty=false] [-install-script=false] [-homebrew=""] [-scoop=""] [-package=""] [-maintainer=""] [-description=""] [-license=""] [-partial=false] [-require=""] <assets>
 
         -repository=""
-            The repository (e.g. github.com/alice/example).
+            The repository (e.g. github.com/alice/example or github.example.com/alice/example).
 
         -force=false
             Overwrite assets if they already exist.
@@ -49,12 +112,75 @@ Usage
         -keep=false
             Do NOT delete assets of same kind in other releases.
 
+        -notes=""
+            A file containing the release notes (the body of the release).
+
+        -notes-template=""
+            A file containing a text/template for the release notes. The template
+            is given .Tag, .Previous, .Message, .Commits, and .Groups (the commits
+            grouped by Conventional Commit type: feat, fix, ...).
+
+        -edit-notes=false
+            Update the name and notes of the release, even if it already exists.
+
+        -allow-dirty=false
+            Release even if the local repository has uncommitted changes, untracked
+            files next to the assets, or a HEAD/tag that does not match the remote.
+
+        -install-script=false
+            Also upload install.sh and install.ps1, scripts that download and install
+            the asset (of every program of the release) for the platform they run on,
+            verifying its sha256 (see Install scripts, below).
+
+        -homebrew=""
+            Also write a Homebrew formula (<program>.rb, for the darwin and linux assets
+            of the release) for every program: into a directory, or, given a repository
+            (a tap, e.g. github.com/alice/homebrew-tap), committed to Formula/<program>.rb.
+
+        -scoop=""
+            Also write a Scoop manifest (<program>.json, for the windows assets of the
+            release) for every program: into a directory, or, given a repository (a
+            bucket, e.g. github.com/alice/scoop-bucket), committed to bucket/<program>.json.
+
+        -package=""
+            Also build and upload a package of every linux binary, in each format
+            given: deb, rpm, and/or apk (a comma-separated list, see Packages, below).
+
+        -maintainer=""
+            The maintainer of the packages (e.g. "Alice <alice@example.com>"), by
+            default the owner of the repository.
+
+        -description=""
+            The (one-line) description of the packages.
+
+        -license=""
+            The license of the packages (e.g. MIT).
+
+        -partial=false
+            Release even if a program is missing a platform (of .gphr, or -require),
+            neither uploaded nor already in the release, with a warning rather than
+            a failure (see Configuration, below).
+
+        -require=""
+            The platforms every program of the release must have (e.g.
+            -require=linux/amd64,darwin/arm64,windows/amd64), uploaded or already
+            in the release.
+
         Create a release (if none already exists) and upload one or more assets to it.
         If no <repository> is given, default to the current GitHub remote (gphr
         will look at the "origin" remote first, then at the "github" remote, if nothing
         GitHub-ish is found).
 
-        This command should be run from within a git repository.
+        The release is named after the tag. The release notes are the message of the
+        annotated tag, if any, or a changelog of the commit subjects since the
+        previous tag.
+
+        This command should be run from within a git repository. Before anything is
+        released, gphr checks that the working tree is clean, that HEAD and the tag
+        have been pushed, and that the remote tag is the same as the local tag (asking
+        the remote, with git ls-remote, rather than trusting origin/master, ...).
+        Nothing is fetched: if the remote has commits that are not here, git fetch
+        first.
 
         gphr will make sure that the tag/commit pair at <repository> matches the local
         tag/commit pair.
@@ -63,6 +189,247 @@ Usage
 
             gphr release --force example_linux_amd64
 
+    gphr list [-program=""] [-os=""] [-arch=""] [-tag=""] [-prerelease="include"] [-columns=""] [-group=""] [<repository> ...]
+
+        -program=""
+        -os=""
+        -arch=""
+            List only the assets of these programs, for these operating systems
+            ($GOOS) and architectures ($GOARCH), each a comma-separated list (e.g.
+            -os=linux,darwin).
+
+        -tag=""
+            List only the assets of the release for a tag (v1.2.0), or for a range of
+            (semver) tags: v1.0.0..v1.2.0 (inclusive), v1.0.0.. (and after), or ..v1.2.0.
+
+        -prerelease="include"
+            Whether to list the assets of prereleases: "include", "exclude", or "only".
+
+        -columns=""
+            What to list about each asset, a comma-separated list of: name, tag,
+            program, platform, size, downloads, created, uploader, and url. By
+            default, name,tag (and, with -output=table, name,tag,size,url).
+
+        -group=""
+            Group the assets by "release" or "platform" ($GOOS/$GOARCH).
+
+        List the gphr assets (example_linux_386, ...) of <repository>, newest first.
+        If a platform is in the previous release, but not the newest, gphr says so.
+        If no <repository> is given, default to the local repository (as release does).
+
+        Given more than one <repository>, or an owner (a user or organization, e.g.
+        github.com/alice) for every repository of the owner, gphr lists each (by
+        repository), querying up to 4 at a time.
+
+            gphr list -os=linux -columns=name,tag,size,downloads github.com/alice/example
+
+            gphr list -output=table github.com/alice github.com/bob/example
+
+    gphr stats [-by="version,platform,program"] [-since=0] [-save=true] [<repository> ...]
+
+        -by="version,platform,program"
+            What to aggregate the downloads by: version (release), platform
+            ($GOOS/$GOARCH), and/or program.
+
+        -since=0
+            Compare with the last snapshot from at least this long ago (e.g. 168h,
+            for the growth over a week), rather than the last snapshot.
+
+        -save=true
+            Save a snapshot of the downloads (of every asset), to compare with later.
+
+        Report the downloads of the gphr assets of <repository> (or of the local
+        repository, more than one, or every repository of an owner, as list does).
+        Every run saves a snapshot of the downloads in GPHR_STATS (by default, gphr/stats
+        in $XDG_DATA_HOME, or ~/.local/share), so that the growth since a previous run
+        can be shown. The downloads of a deleted asset (e.g. of an older release) do
+        not count against the growth.
+
+            gphr stats -since=720h -output=csv github.com/alice/example
+
+    gphr serve [-listen=":8080"] [-host="github.com"] -owner=<owner> [-proxy=false] [-ttl=5m]
+
+        -listen=":8080"
+            The address to listen on.
+
+        -host="github.com"
+            The host of the repositories served.
+
+        -owner=<owner>
+            Serve only the repositories of these owners (a comma-separated list). Required.
+
+        -proxy=false
+            Serve the asset itself (with the token), rather than redirect to it.
+
+        -ttl=5m
+            How long to cache the releases of a repository (or a failure to get them).
+
+        Serve the asset of a program for the platform of the client, at:
+
+            /<owner>/<repository>[/<program>][@<version>]
+
+        The program is, by default, the repository, and the version the newest
+        release (with an asset for the platform). The platform is given with ?os=
+        and ?arch= (e.g. ?os=linux&arch=amd64), or otherwise guessed from the
+        User-Agent (of PowerShell, a browser, ...).
+
+            gphr serve -owner=alice
+
+            curl -JLO "http://localhost:8080/alice/example@v1.2.0?os=linux&arch=amd64"
+
+    gphr check [-repository=""] [-require=""] [<tag>]
+
+        -repository=""
+            The repository (e.g. github.com/alice/example or github.example.com/alice/example).
+
+        -require=""
+            The platforms every program of the release must have (as for release).
+
+        Check the release for <tag> (by default, the tag of HEAD) as release would:
+        every program (of .gphr, or of the assets, or else the repository) must
+        have every platform (of .gphr, or -require), and every asset must be of a
+        program (and a platform) of .gphr. What is missing (or unexpected) is
+        reported, with exit status 10 (or 4, if there is no release for <tag>).
+
+            gphr check -require=linux/amd64,darwin/arm64,windows/amd64 v1.2.0
+
+    gphr changelog [-repository=""] [-file="CHANGELOG.md"]
+
+        -repository=""
+            The repository (e.g. github.com/alice/example or github.example.com/alice/example).
+
+        -file="CHANGELOG.md"
+            The changelog to write (or update).
+
+        Write a Keep a Changelog (http://keepachangelog.com/) style changelog for
+        the semver tags (v1.2.3, 1.2.3, ...) of the local repository, linking each
+        version to its release (unless the releases are in a store, which has no
+        web pages). Sections for versions already in the changelog are
+        left as they are, the rest are generated from the commit subjects between
+        tags. Any tag without a release is reported.
+
+        With -dry-run, the changelog is printed instead of written.
+
+    gphr tag [-remote=""] [-message=""] [-preid=""] [-sign=false] [major|minor|patch|prerelease]
+
+        -remote=""
+            The remote to push the tag to (by default, the GitHub remote).
+
+        -message=""
+            The message of the tag (by default, the commit subjects since the previous tag).
+
+        -preid=""
+            The identifier of a prerelease: v1.2.4-beta.1 (with -preid=beta), or
+            v1.3.0-beta.1 after v1.3.0-alpha.2. By default, the prerelease continues
+            (v1.3.0-alpha.3 after v1.3.0-alpha.2), or a new one is an rc (v1.2.4-rc.1).
+
+        -sign=false
+            Make a GPG-signed tag (git tag --sign).
+
+        Create an annotated tag for HEAD with the next semver version (patch, by
+        default), and push it. The working tree must be clean and HEAD must already
+        be pushed to the remote.
+
+            gphr tag minor && gphr release example_linux_386 example_darwin_386
+
+        With -dry-run, the next version is printed, but nothing is tagged.
+
+    gphr auth status [<host>]
+
+        Report the token for <host> (by default, the host of the local repository,
+        or github.com): where it came from (-token, GITHUB_TOKEN, ...), and, for
+        GitHub, the user and scopes of the token.
+
+    gphr cache clean
+
+        Empty the HTTP cache. Responses from the API are cached on disk (in gphr
+        in the user cache directory, or GPHR_CACHE), and revalidated with their
+        ETag, which does not count against the rate limit of GitHub. The cache is
+        kept to GPHR_CACHE_SIZE megabytes (64, by default). Use GPHR_CACHE=off to
+        not cache anything (beyond a single run).
+
+Exit status
+
+    0   Success
+    1   Failure (of any other kind)
+    2   Invalid usage (an unknown command or flag)
+    3   HEAD is not tagged
+    4   The tag does not exist in the remote repository
+    5   The tag does not match (a different commit) in the remote repository
+    6   An asset of the same kind already exists (without -force)
+    7   No token, or the token is invalid (unauthorized)
+    8   The API rate limit is exhausted
+    9   get found nothing (no matching asset)
+    10  A program is missing a platform (of .gphr, or -require)
+    130 Interrupted
+
+Install scripts
+
+With -install-script, every release gets an install.sh (POSIX sh) and install.ps1
+(PowerShell), so that a program can be installed without gphr (or Go):
+
+    curl -sSfL https://github.com/alice/example/releases/latest/download/install.sh | sh
+
+    curl -sSfL https://github.com/alice/example/releases/latest/download/install.sh | sh -s -- -p ~/.local example
+
+    irm https://github.com/alice/example/releases/latest/download/install.ps1 | iex
+
+The scripts detect the platform (uname, or $env:PROCESSOR_ARCHITECTURE), pick the
+asset <program>_$GOOS_$GOARCH (or <program>-$GOOS-$GOARCH, with .exe for windows),
+check it against the sha256 in the script, and install it into <prefix>/bin (-p,
+or PREFIX, by default /usr/local, or %LOCALAPPDATA%\Programs\<repository>).
+
+Packages
+
+With -package=deb,rpm,apk, every linux binary of the release is also packaged (by
+gphr, without dpkg, rpmbuild, or abuild) to install as /usr/bin/<program>, and
+uploaded with it:
+
+    example_1.2.0_amd64.deb        dpkg -i example_1.2.0_amd64.deb
+    example-1.2.0-1.x86_64.rpm     rpm -i example-1.2.0-1.x86_64.rpm
+    example-1.2.0-r0.x86_64.apk    apk add --allow-untrusted example-1.2.0-r0.x86_64.apk
+
+Each format has its own name for an architecture: amd64 is amd64 (deb) or x86_64
+(rpm, apk), 386 is i386 (deb, rpm) or x86 (apk), arm is armhf (deb, apk) or
+armv7hl (rpm), and arm64 is arm64 (deb) or aarch64 (rpm, apk). A prerelease
+(v1.2.0-rc.1) is ordered before its release, as 1.2.0~rc.1 (deb, rpm) or
+1.2.0_rc1 (apk). The packages are not signed.
+
+Configuration
+
+The flags of release can also be given in .gphr, at the top of the repository
+(committed with it), in the syntax of git config:
+
+    [package]
+        formats = deb,rpm,apk
+        maintainer = Alice <alice@example.com>
+        description = An example program
+        license = MIT
+        homepage = https://example.com
+
+A flag (given) overrides the configuration.
+
+The programs of a release (of a repository with more than one), and the platforms
+of each, can be declared as well:
+
+    [program "server"]
+        platforms = linux/amd64, linux/arm
+    [program "cli"]
+        platforms = linux/amd64, darwin/amd64, windows/amd64
+
+As can the platforms required of every program (as -require), and whether to
+release anyway (with a warning) if any is missing (as -partial):
+
+    [release]
+        require = linux/amd64, darwin/arm64, windows/amd64
+        partial = true
+
+Before uploading anything, release then reports every binary that is unexpected
+(of another program, or platform) and every platform that is missing (neither
+uploaded nor already in the release), and stops (unless only missing, with
+-partial). Its output is grouped by program. gphr check does the same for a
+release already done.
+
 Workflow
 
 The workflow for a release:
@@ -76,19 +443,38 @@ The workflow for a release:
 
         git describe --tags --exact-match
 
-    3. Determine the commit for the target tag.
+    3. Check the local repository (unless -allow-dirty is given).
+
+        git status --porcelain
+        git ls-remote --heads <remote>
+        git merge-base --is-ancestor HEAD <branch> # For each branch of the remote (nothing is fetched)
+        git ls-remote --tags <remote> refs/tags/<tag>
+
+    4. Determine the commit for the target tag.
 
         git rev-list <tag>
 
-    4. Find the release that matches the target tag. If found, then make sure the tag commit
+    5. Find the release that matches the target tag. If found, then make sure the tag commit
     is the same in both the local and remote repositories. This is the target release.
 
-    5. If no release was found, then create a release for the target tag. Again, make sure the
+    5b. With programs in .gphr (or -require), check the binaries (and the assets of
+    the target release) against them: none unexpected, and none missing (unless -partial).
+
+    6. If no release was found, then create a release for the target tag. Again, make sure the
     tag commit is the same in both the local and remote repositories. This is the target release.
 
-    6. Upload assets to the target release.
+    7. Upload assets to the target release.
+
+    7a. With -package, build a package (.deb, .rpm, .apk) of every linux binary, and
+    upload it to the target release (replacing any already there).
 
-    7. Delete matching assets from other, older releases (if any).
+    7b. With -install-script, generate install.sh and install.ps1 for every asset
+    of the target release, and upload them (replacing any already there).
+
+    7c. With -homebrew or -scoop, generate a Homebrew formula or Scoop manifest for
+    every program of the target release, and write (or commit) it.
+
+    8. Delete matching assets from other, older releases (if any).
 
 */
 package main
@@ -98,6 +484,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 )
 
 type _flags struct {
@@ -109,29 +496,124 @@ type _flags struct {
 
 	get_ *flag.FlagSet
 	get  _getFlags
+
+	list_ *flag.FlagSet
+	list  _listFlags
+
+	stats_ *flag.FlagSet
+	stats  _statsFlags
+
+	serve_ *flag.FlagSet
+	serve  _serveFlags
+
+	check_ *flag.FlagSet
+	check  _checkFlags
+
+	changelog_ *flag.FlagSet
+	changelog  _changelogFlags
+
+	tag_ *flag.FlagSet
+	tag  _tagFlags
+
+	auth_ *flag.FlagSet
+
+	cache_ *flag.FlagSet
 }
 
 type _mainFlags struct {
-	debug  *bool
-	dryRun *bool
-	token  *string
+	debug     *bool
+	dryRun    *bool
+	token     *string
+	git       *string
+	apiURL    *string
+	uploadURL *string
+	provider  *string
+	store     *string
+	appID     *string
+	appKey    *string
+	output    *string
 }
 
 type _releaseFlags struct {
-	repository *string
-	force      *bool
-	keep       *bool
+	repository    *string
+	force         *bool
+	keep          *bool
+	notes         *string
+	notesTemplate *string
+	editNotes     *bool
+	allowDirty    *bool
+	installScript *bool
+	homebrew      *string
+	scoop         *string
+	packages      *string
+	maintainer    *string
+	description   *string
+	license       *string
+	partial       *bool
+	require       *string
 }
 
 type _getFlags struct {
 	preserve *bool
 }
 
-var flags = func() (flags *_flags) {
+type _checkFlags struct {
+	repository *string
+	require    *string
+}
+
+type _changelogFlags struct {
+	repository *string
+	file       *string
+}
+
+type _listFlags struct {
+	program    *string
+	os         *string
+	arch       *string
+	tag        *string
+	prerelease *string
+	columns    *string
+	group      *string
+}
+
+type _statsFlags struct {
+	by    *string
+	since *time.Duration
+	save  *bool
+}
+
+type _serveFlags struct {
+	listen *string
+	host   *string
+	owner  *string
+	proxy  *bool
+	ttl    *time.Duration
+}
+
+type _tagFlags struct {
+	remote  *string
+	message *string
+	preid   *string
+	sign    *bool
+}
+
+var flags = newFlags()
+
+func newFlags() (flags *_flags) {
 	flags = &_flags{
 		main_:    flag.NewFlagSet(os.Args[0], flag.ExitOnError),
 		release_: flag.NewFlagSet(os.Args[0]+" release", flag.ExitOnError),
 		get_:     flag.NewFlagSet(os.Args[0]+" get", flag.ExitOnError),
+		list_:    flag.NewFlagSet(os.Args[0]+" list", flag.ExitOnError),
+		stats_:   flag.NewFlagSet(os.Args[0]+" stats", flag.ExitOnError),
+		serve_:   flag.NewFlagSet(os.Args[0]+" serve", flag.ExitOnError),
+
+		check_:     flag.NewFlagSet(os.Args[0]+" check", flag.ExitOnError),
+		changelog_: flag.NewFlagSet(os.Args[0]+" changelog", flag.ExitOnError),
+		tag_:       flag.NewFlagSet(os.Args[0]+" tag", flag.ExitOnError),
+		auth_:      flag.NewFlagSet(os.Args[0]+" auth", flag.ExitOnError),
+		cache_:     flag.NewFlagSet(os.Args[0]+" cache", flag.ExitOnError),
 	}
 
 	var flag *flag.FlagSet
@@ -141,33 +623,111 @@ var flags = func() (flags *_flags) {
 	flags.main.debug = flag.Bool("debug", false, "")
 	flags.main.dryRun = flag.Bool("dry-run", false, "")
 	flags.main.token = flag.String("token", "", "")
+	flags.main.git = flag.String("git", "auto", "")
+	flags.main.apiURL = flag.String("api-url", "", "")
+	flags.main.uploadURL = flag.String("upload-url", "", "")
+	flags.main.provider = flag.String("provider", "", "")
+	flags.main.store = flag.String("store", "", "")
+	flags.main.appID = flag.String("app-id", "", "")
+	flags.main.appKey = flag.String("app-key", "", "")
+	flags.main.output = flag.String("output", "text", "")
 
 	flag = flags.release_
 	flag.Usage = usage
 	flags.release.repository = flag.String("repository", "", "")
 	flags.release.force = flag.Bool("force", false, "")
 	flags.release.keep = flag.Bool("keep", false, "")
+	flags.release.notes = flag.String("notes", "", "")
+	flags.release.notesTemplate = flag.String("notes-template", "", "")
+	flags.release.editNotes = flag.Bool("edit-notes", false, "")
+	flags.release.allowDirty = flag.Bool("allow-dirty", false, "")
+	flags.release.installScript = flag.Bool("install-script", false, "")
+	flags.release.homebrew = flag.String("homebrew", "", "")
+	flags.release.scoop = flag.String("scoop", "", "")
+	flags.release.packages = flag.String("package", "", "")
+	flags.release.maintainer = flag.String("maintainer", "", "")
+	flags.release.description = flag.String("description", "", "")
+	flags.release.license = flag.String("license", "", "")
+	flags.release.partial = flag.Bool("partial", false, "")
+	flags.release.require = flag.String("require", "", "")
 
 	flag = flags.get_
 	flag.Usage = usage
 	flags.get.preserve = flag.Bool("preserve", false, "")
 
+	flag = flags.list_
+	flag.Usage = usage
+	flags.list.program = flag.String("program", "", "")
+	flags.list.os = flag.String("os", "", "")
+	flags.list.arch = flag.String("arch", "", "")
+	flags.list.tag = flag.String("tag", "", "")
+	flags.list.prerelease = flag.String("prerelease", "include", "")
+	flags.list.columns = flag.String("columns", "", "")
+	flags.list.group = flag.String("group", "", "")
+
+	flag = flags.stats_
+	flag.Usage = usage
+	flags.stats.by = flag.String("by", "version,platform,program", "")
+	flags.stats.since = flag.Duration("since", 0, "")
+	flags.stats.save = flag.Bool("save", true, "")
+
+	flag = flags.serve_
+	flag.Usage = usage
+	flags.serve.listen = flag.String("listen", ":8080", "")
+	flags.serve.host = flag.String("host", "github.com", "")
+	flags.serve.owner = flag.String("owner", "", "")
+	flags.serve.proxy = flag.Bool("proxy", false, "")
+	flags.serve.ttl = flag.Duration("ttl", 5*time.Minute, "")
+
+	flag = flags.check_
+	flag.Usage = usage
+	flags.check.repository = flag.String("repository", "", "")
+	flags.check.require = flag.String("require", "", "")
+
+	flag = flags.changelog_
+	flag.Usage = usage
+	flags.changelog.repository = flag.String("repository", "", "")
+	flags.changelog.file = flag.String("file", "CHANGELOG.md", "")
+
+	flag = flags.tag_
+	flag.Usage = usage
+	flags.tag.remote = flag.String("remote", "", "")
+	flags.tag.message = flag.String("message", "", "")
+	flags.tag.preid = flag.String("preid", "", "")
+	flags.tag.sign = flag.Bool("sign", false, "")
+
+	flag = flags.auth_
+	flag.Usage = usage
+
+	flag = flags.cache_
+	flag.Usage = usage
+
 	return
-}()
+}
 
 func usage() {
 	fmt.Fprintf(os.Stderr, strings.TrimSpace(`
 Usage of %s:
 
-    gphr [-token=""] [-debug=false] [-dry-run=false] <command> ...
+    gphr [-token=""] [-debug=false] [-dry-run=false] [-git="auto"] [-api-url=""] [-upload-url=""] [-provider=""] [-store=""] [-app-id=""] [-app-key=""] [-output="text"] <command> ...
 
         -token=""
             The token to use when accessing GitHub:
             https://github.com/blog/1509-personal-api-tokens
 
             If <token> starts with a "!", then this is a command that outputs the
-            token instead. You can also specify a token via the GPHR_TOKEN
-            environment variable.
+            token instead (run with /bin/sh -c, or cmd /C on Windows). You can also
+            specify a token via the GPHR_TOKEN environment variable.
+
+            Otherwise, the token is the first of:
+
+                GH_TOKEN or GITHUB_TOKEN (GH_ENTERPRISE_TOKEN or GITHUB_ENTERPRISE_TOKEN
+                for any other GitHub host, GITLAB_TOKEN for GitLab, GITEA_TOKEN for Gitea)
+                git credential fill (for https://<host>, from a credential helper)
+                ~/.netrc ($NETRC, or ~/_netrc on Windows)
+                The hosts.yml of the gh CLI (gh auth login)
+
+            Use "gphr auth status" to see which token is used.
 
          -debug=false
             Print out debugging information.
@@ -175,10 +735,61 @@ Usage of %s:
          -dry-run=false
             Do not actually modify the remote repository, just show what would be done instead.
 
-    gphr release [-repository=""] [-force=false] [-keep=false] <assets>
+         -git="auto"
+            How to read the local git repository: "exec" (run the git binary),
+            "native" (read .git directly, without git), or "auto" (exec if git
+            is installed, otherwise native). Natively, release does not check the
+            working tree or the remote (for uncommitted changes, untracked files,
+            or an unpushed HEAD or tag), and tag is not available.
+
+         -api-url=""
+            The URL of the GitHub API (e.g. https://github.example.com/api/v3/). You
+            can also specify this via the GPHR_API_URL environment variable. By default,
+            this is https://api.github.com/ for github.com, and https://<host>/api/v3/
+            for any other (GitHub Enterprise) host.
+
+         -upload-url=""
+            The URL of the GitHub upload API (e.g. https://github.example.com/api/uploads/).
+            You can also specify this via the GPHR_UPLOAD_URL environment variable. By
+            default, this is the same as -api-url (if given), or https://uploads.github.com/
+            for github.com, and https://<host>/api/uploads/ for any other host.
+
+         -provider=""
+            Where the releases are kept: "github" (GitHub or GitHub Enterprise),
+            "gitlab" (GitLab Releases, with assets in the Generic Package Registry),
+            or "gitea" (Gitea or Forgejo). By default, this is determined by the host:
+            gitlab.com and gitlab.* are gitlab, codeberg.org, gitea.*, and forgejo.*
+            are gitea, and anything else is github.
+
+         -store=""
+            Keep releases in a store instead: a directory (/srv/releases) or an
+            S3-compatible bucket (s3://bucket/prefix), as <owner>/<repository>/<tag>/<asset>
+            along with an index (<owner>/<repository>/releases.json). You can also
+            specify this via the GPHR_STORE environment variable. A bucket is accessed
+            with AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_REGION, and (for MinIO,
+            etc.) AWS_ENDPOINT_URL. No token is needed for a store.
+
+         -app-id=""
+         -app-key=""
+            Authenticate as a GitHub App instead of with a token: the App ID (or
+            Client ID), and the path to the private key (.pem) of the App. You can
+            also specify these via the GPHR_APP_ID and GPHR_APP_KEY (the path, or
+            the key itself) environment variables. gphr uses an installation access
+            token for just the repository, from the installation of the App for the
+            repository (or GPHR_APP_INSTALLATION_ID), and renews it as needed.
+
+         -output="text"
+            What to output: "text" (for a person), "table" (a table, with a
+            header), "csv" (the table, as CSV), or "json" (for a pipeline). With
+            json, release outputs the assets it uploaded and deleted (with their
+            URL, size, and digest), list the releases (with their assets), and get
+            the version, asset, and path it downloaded. Progress (Uploading ...,
+            Downloading ...) goes to stderr, unless the output is text.
+
+    gphr release [-repository=""] [-force=false] [-keep=false] [-notes=""] [-notes-template=""] [-edit-notes=false] [-allow-dirty=false] [-install-script=false] [-homebrew=""] [-scoop=""] [-package=""] [-maintainer=""] [-description=""] [-license=""] [-partial=false] [-require=""] <assets>
 
         -repository=""
-            The repository (e.g. github.com/alice/example).
+            The repository (e.g. github.com/alice/example or github.example.com/alice/example).
 
         -force=false
             Overwrite assets if they already exist.
@@ -186,12 +797,75 @@ Usage of %s:
         -keep=false
             Do NOT delete assets of same kind in other releases.
 
+        -notes=""
+            A file containing the release notes (the body of the release).
+
+        -notes-template=""
+            A file containing a text/template for the release notes. The template
+            is given .Tag, .Previous, .Message, .Commits, and .Groups (the commits
+            grouped by Conventional Commit type: feat, fix, ...).
+
+        -edit-notes=false
+            Update the name and notes of the release, even if it already exists.
+
+        -allow-dirty=false
+            Release even if the local repository has uncommitted changes, untracked
+            files next to the assets, or a HEAD/tag that does not match the remote.
+
+        -install-script=false
+            Also upload install.sh and install.ps1, scripts that download and install
+            the asset (of every program of the release) for the platform they run on,
+            verifying its sha256 (see Install scripts, below).
+
+        -homebrew=""
+            Also write a Homebrew formula (<program>.rb, for the darwin and linux assets
+            of the release) for every program: into a directory, or, given a repository
+            (a tap, e.g. github.com/alice/homebrew-tap), committed to Formula/<program>.rb.
+
+        -scoop=""
+            Also write a Scoop manifest (<program>.json, for the windows assets of the
+            release) for every program: into a directory, or, given a repository (a
+            bucket, e.g. github.com/alice/scoop-bucket), committed to bucket/<program>.json.
+
+        -package=""
+            Also build and upload a package of every linux binary, in each format
+            given: deb, rpm, and/or apk (a comma-separated list, see Packages, below).
+
+        -maintainer=""
+            The maintainer of the packages (e.g. "Alice <alice@example.com>"), by
+            default the owner of the repository.
+
+        -description=""
+            The (one-line) description of the packages.
+
+        -license=""
+            The license of the packages (e.g. MIT).
+
+        -partial=false
+            Release even if a program is missing a platform (of .gphr, or -require),
+            neither uploaded nor already in the release, with a warning rather than
+            a failure (see Configuration, below).
+
+        -require=""
+            The platforms every program of the release must have (e.g.
+            -require=linux/amd64,darwin/arm64,windows/amd64), uploaded or already
+            in the release.
+
         Create a release (if none already exists) and upload one or more assets to it.
         If no <repository> is given, default to the current GitHub remote (gphr
         will look at the "origin" remote first, then at the "github" remote, if nothing
         GitHub-ish is found).
 
-        This command should be run from within a git repository.
+        The release is named after the tag. The release notes are the message of the
+        annotated tag, if any, or a changelog of the commit subjects since the
+        previous tag.
+
+        This command should be run from within a git repository. Before anything is
+        released, gphr checks that the working tree is clean, that HEAD and the tag
+        have been pushed, and that the remote tag is the same as the local tag (asking
+        the remote, with git ls-remote, rather than trusting origin/master, ...).
+        Nothing is fetched: if the remote has commits that are not here, git fetch
+        first.
 
         gphr will make sure that the tag/commit pair at <repository> matches the local
         tag/commit pair.
@@ -200,6 +874,180 @@ Usage of %s:
 
             gphr release --force example_linux_amd64
 
+    gphr list [-program=""] [-os=""] [-arch=""] [-tag=""] [-prerelease="include"] [-columns=""] [-group=""] [<repository> ...]
+
+        -program=""
+        -os=""
+        -arch=""
+            List only the assets of these programs, for these operating systems
+            ($GOOS) and architectures ($GOARCH), each a comma-separated list (e.g.
+            -os=linux,darwin).
+
+        -tag=""
+            List only the assets of the release for a tag (v1.2.0), or for a range of
+            (semver) tags: v1.0.0..v1.2.0 (inclusive), v1.0.0.. (and after), or ..v1.2.0.
+
+        -prerelease="include"
+            Whether to list the assets of prereleases: "include", "exclude", or "only".
+
+        -columns=""
+            What to list about each asset, a comma-separated list of: name, tag,
+            program, platform, size, downloads, created, uploader, and url. By
+            default, name,tag (and, with -output=table, name,tag,size,url).
+
+        -group=""
+            Group the assets by "release" or "platform" ($GOOS/$GOARCH).
+
+        List the gphr assets (example_linux_386, ...) of <repository>, newest first.
+        If a platform is in the previous release, but not the newest, gphr says so.
+        If no <repository> is given, default to the local repository (as release does).
+
+        Given more than one <repository>, or an owner (a user or organization, e.g.
+        github.com/alice) for every repository of the owner, gphr lists each (by
+        repository), querying up to 4 at a time.
+
+            gphr list -os=linux -columns=name,tag,size,downloads github.com/alice/example
+
+            gphr list -output=table github.com/alice github.com/bob/example
+
+    gphr stats [-by="version,platform,program"] [-since=0] [-save=true] [<repository> ...]
+
+        -by="version,platform,program"
+            What to aggregate the downloads by: version (release), platform
+            ($GOOS/$GOARCH), and/or program.
+
+        -since=0
+            Compare with the last snapshot from at least this long ago (e.g. 168h,
+            for the growth over a week), rather than the last snapshot.
+
+        -save=true
+            Save a snapshot of the downloads (of every asset), to compare with later.
+
+        Report the downloads of the gphr assets of <repository> (or of the local
+        repository, more than one, or every repository of an owner, as list does).
+        Every run saves a snapshot of the downloads in GPHR_STATS (by default, gphr/stats
+        in $XDG_DATA_HOME, or ~/.local/share), so that the growth since a previous run
+        can be shown. The downloads of a deleted asset (e.g. of an older release) do
+        not count against the growth.
+
+            gphr stats -since=720h -output=csv github.com/alice/example
+
+    gphr serve [-listen=":8080"] [-host="github.com"] -owner=<owner> [-proxy=false] [-ttl=5m]
+
+        -listen=":8080"
+            The address to listen on.
+
+        -host="github.com"
+            The host of the repositories served.
+
+        -owner=<owner>
+            Serve only the repositories of these owners (a comma-separated list). Required.
+
+        -proxy=false
+            Serve the asset itself (with the token), rather than redirect to it.
+
+        -ttl=5m
+            How long to cache the releases of a repository (or a failure to get them).
+
+        Serve the asset of a program for the platform of the client, at:
+
+            /<owner>/<repository>[/<program>][@<version>]
+
+        The program is, by default, the repository, and the version the newest
+        release (with an asset for the platform). The platform is given with ?os=
+        and ?arch= (e.g. ?os=linux&arch=amd64), or otherwise guessed from the
+        User-Agent (of PowerShell, a browser, ...).
+
+            gphr serve -owner=alice
+
+            curl -JLO "http://localhost:8080/alice/example@v1.2.0?os=linux&arch=amd64"
+
+    gphr check [-repository=""] [-require=""] [<tag>]
+
+        -repository=""
+            The repository (e.g. github.com/alice/example or github.example.com/alice/example).
+
+        -require=""
+            The platforms every program of the release must have (as for release).
+
+        Check the release for <tag> (by default, the tag of HEAD) as release would:
+        every program (of .gphr, or of the assets, or else the repository) must
+        have every platform (of .gphr, or -require), and every asset must be of a
+        program (and a platform) of .gphr. What is missing (or unexpected) is
+        reported, with exit status 10 (or 4, if there is no release for <tag>).
+
+            gphr check -require=linux/amd64,darwin/arm64,windows/amd64 v1.2.0
+
+    gphr changelog [-repository=""] [-file="CHANGELOG.md"]
+
+        -repository=""
+            The repository (e.g. github.com/alice/example or github.example.com/alice/example).
+
+        -file="CHANGELOG.md"
+            The changelog to write (or update).
+
+        Write a Keep a Changelog (http://keepachangelog.com/) style changelog for
+        the semver tags (v1.2.3, 1.2.3, ...) of the local repository, linking each
+        version to its release (unless the releases are in a store, which has no
+        web pages). Sections for versions already in the changelog are
+        left as they are, the rest are generated from the commit subjects between
+        tags. Any tag without a release is reported.
+
+        With -dry-run, the changelog is printed instead of written.
+
+    gphr tag [-remote=""] [-message=""] [-preid=""] [-sign=false] [major|minor|patch|prerelease]
+
+        -remote=""
+            The remote to push the tag to (by default, the GitHub remote).
+
+        -message=""
+            The message of the tag (by default, the commit subjects since the previous tag).
+
+        -preid=""
+            The identifier of a prerelease: v1.2.4-beta.1 (with -preid=beta), or
+            v1.3.0-beta.1 after v1.3.0-alpha.2. By default, the prerelease continues
+            (v1.3.0-alpha.3 after v1.3.0-alpha.2), or a new one is an rc (v1.2.4-rc.1).
+
+        -sign=false
+            Make a GPG-signed tag (git tag --sign).
+
+        Create an annotated tag for HEAD with the next semver version (patch, by
+        default), and push it. The working tree must be clean and HEAD must already
+        be pushed to the remote.
+
+            gphr tag minor && gphr release example_linux_386 example_darwin_386
+
+        With -dry-run, the next version is printed, but nothing is tagged.
+
+    gphr auth status [<host>]
+
+        Report the token for <host> (by default, the host of the local repository,
+        or github.com): where it came from (-token, GITHUB_TOKEN, ...), and, for
+        GitHub, the user and scopes of the token.
+
+    gphr cache clean
+
+        Empty the HTTP cache. Responses from the API are cached on disk (in gphr
+        in the user cache directory, or GPHR_CACHE), and revalidated with their
+        ETag, which does not count against the rate limit of GitHub. The cache is
+        kept to GPHR_CACHE_SIZE megabytes (64, by default). Use GPHR_CACHE=off to
+        not cache anything (beyond a single run).
+
+Exit status:
+
+    0   Success
+    1   Failure (of any other kind)
+    2   Invalid usage (an unknown command or flag)
+    3   HEAD is not tagged
+    4   The tag does not exist in the remote repository
+    5   The tag does not match (a different commit) in the remote repository
+    6   An asset of the same kind already exists (without -force)
+    7   No token, or the token is invalid (unauthorized)
+    8   The API rate limit is exhausted
+    9   get found nothing (no matching asset)
+    10  A program is missing a platform (of .gphr, or -require)
+    130 Interrupted
+
     `), os.Args[0])
 	fmt.Fprintln(os.Stderr, "\n")
 }